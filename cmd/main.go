@@ -2,17 +2,34 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/beatyman/scan-miners/config"
+	"github.com/beatyman/scan-miners/internal/delivery/cron"
 	"github.com/beatyman/scan-miners/internal/domain/model"
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+	"github.com/beatyman/scan-miners/internal/repository/antminer"
+	"github.com/beatyman/scan-miners/internal/repository/antpool"
+	"github.com/beatyman/scan-miners/internal/repository/avalon"
+	"github.com/beatyman/scan-miners/internal/repository/braiins"
+	"github.com/beatyman/scan-miners/internal/repository/cgminer"
 	"github.com/beatyman/scan-miners/internal/repository/mysql"
+	"github.com/beatyman/scan-miners/internal/repository/rawarchive"
+	"github.com/beatyman/scan-miners/internal/repository/vnish"
+	"github.com/beatyman/scan-miners/internal/repository/whatsminer"
 	"github.com/beatyman/scan-miners/internal/usecase"
 	"github.com/beatyman/scan-miners/pkg/database"
+	"github.com/beatyman/scan-miners/pkg/ipmap"
 	"github.com/beatyman/scan-miners/pkg/logger"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 func main() {
@@ -20,13 +37,46 @@ func main() {
 	logger.Init()
 	defer logger.Log.Sync()
 
-	// 2. Parse Subcommands
+	// 2. Parse global flags, then subcommands
+	globalFlags := flag.NewFlagSet("sacn-miners", flag.ExitOnError)
+	configPath := globalFlags.String("config", "", "Path to YAML config file (defaults to $"+config.EnvConfigPath+")")
+	globalFlags.Usage = printUsage
+	globalFlags.Parse(os.Args[1:])
+	args := globalFlags.Args()
+
 	fetchWorkersCmd := flag.NewFlagSet("fetch-workers", flag.ExitOnError)
+	fetchAccount := fetchWorkersCmd.String("account", "", "Only fetch the configured account with this observer user ID")
+	fetchResume := fetchWorkersCmd.Bool("resume", false, "Continue each account from the page recorded by an interrupted run")
 	scanMinersCmd := flag.NewFlagSet("scan-miners", flag.ExitOnError)
 	exportAnalysisCmd := flag.NewFlagSet("export-analysis", flag.ExitOnError)
 	exportUnderperformingCmd := flag.NewFlagSet("export-underperforming", flag.ExitOnError)
+	exportWorkerHistoryCmd := flag.NewFlagSet("export-worker-history", flag.ExitOnError)
+	historyWorker := exportWorkerHistoryCmd.String("worker", "", "Worker ID to export (required)")
+	historyAccount := exportWorkerHistoryCmd.String("account", "", "Observer user ID of the worker's account (required with several accounts)")
+	historyCoin := exportWorkerHistoryCmd.String("coin", "", "Coin type of the worker's account (required when the account mines several coins)")
+	historySince := exportWorkerHistoryCmd.Duration("since", 7*24*time.Hour, "How far back to export")
+	resolveIPsCmd := flag.NewFlagSet("resolve-ips", flag.ExitOnError)
+	resolveApply := resolveIPsCmd.Bool("apply", false, "Save IPs that changed under the current mapping rules")
+	discoverCmd := flag.NewFlagSet("discover", flag.ExitOnError)
+	exportHottestChainsCmd := flag.NewFlagSet("export-hottest-chains", flag.ExitOnError)
+	hottestLimit := exportHottestChainsCmd.Int("limit", 50, "Number of chains to export")
+	hottestSince := exportHottestChainsCmd.Duration("since", 24*time.Hour, "Ignore miners not scanned within this window")
+	asicHealthCmd := flag.NewFlagSet("asic-health", flag.ExitOnError)
+	asicHealthSince := asicHealthCmd.Duration("since", 24*time.Hour, "Ignore miners not scanned within this window")
+	hashboardMovesCmd := flag.NewFlagSet("hashboard-moves", flag.ExitOnError)
+	movesSince := hashboardMovesCmd.Duration("since", 7*24*time.Hour, "Report moves detected within this window")
+	fanAlertsCmd := flag.NewFlagSet("fan-alerts", flag.ExitOnError)
+	fanAlertsSince := fanAlertsCmd.Duration("since", 24*time.Hour, "Ignore miners not scanned within this window")
+	authFailuresCmd := flag.NewFlagSet("auth-failures", flag.ExitOnError)
+	reparseCmd := flag.NewFlagSet("reparse", flag.ExitOnError)
+	reparseSince := reparseCmd.Duration("since", 30*24*time.Hour, "Reparse snapshots taken within this window")
+	reparseIP := reparseCmd.String("ip", "", "Only reparse snapshots of this miner")
+	serveCmd := flag.NewFlagSet("serve", flag.ExitOnError)
+	chipMapCmd := flag.NewFlagSet("chip-map", flag.ExitOnError)
+	chipMapIP := chipMapCmd.String("ip", "", "IP of the miner to map (required)")
+	chipMapFormat := chipMapCmd.String("format", usecase.ChipMapText, "Output format: text, svg or html")
 
-	if len(os.Args) < 2 {
+	if len(args) < 1 {
 		printUsage()
 		os.Exit(1)
 	}
@@ -34,7 +84,10 @@ func main() {
 	// 3. Common Setup (Config, DB, Repos)
 	// We do this before switching commands because both need DB
 	logger.Log.Info("Initializing Application...")
-	cfg := config.Load()
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		logger.Log.Fatal("Invalid configuration", zap.Error(err))
+	}
 
 	db, err := database.NewMySQLConnection(cfg)
 	if err != nil {
@@ -42,45 +95,195 @@ func main() {
 	}
 
 	logger.Log.Info("Running database migrations...")
-	if err := db.AutoMigrate(&model.Worker{}, &model.MinerStats{}, &model.MinerChain{}); err != nil {
+	if err := migrate(db); err != nil {
 		logger.Log.Fatal("Migration failed", zap.Error(err))
 	}
 
 	workerRepo := mysql.NewWorkerRepository(db)
 	minerStatsRepo := mysql.NewMinerStatsRepository(db)
+	syncRunRepo := mysql.NewWorkerSyncRunRepository(db)
+	snapshotRepo := mysql.NewWorkerHashrateSnapshotRepository(db)
+	deviceRepo := mysql.NewDiscoveredDeviceRepository(db)
+	hashboardRepo := mysql.NewHashboardRepository(db)
+	chipLayoutRepo := mysql.NewChipLayoutRepository(db)
+	scanRunRepo := mysql.NewScanRunRepository(db)
+
+	var rawArchive repository.RawArchive
+	if cfg.App.RawArchiveDir != "" {
+		rawArchive = rawarchive.NewFileArchive(cfg.App.RawArchiveDir, cfg.App.RawArchiveGzip)
+	}
+
+	poolClient := antpool.NewClient(cfg)
+	minerClient := antminer.NewClient(cfg.App.MinerUser, cfg.App.MinerPassword, cfg.App.MinerConnectTimeout, cfg.App.MinerTimeout)
+	cgminerClient := cgminer.NewClient(cfg.App.CGMinerPort, cfg.App.MinerConnectTimeout, cfg.App.MinerTimeout)
+	// Detection order: firmwares that look like generic cgminer must come before it
+	minerDrivers := []repository.MinerDriver{
+		vnish.NewDriver(cgminerClient),
+		braiins.NewDriver(cgminerClient),
+		whatsminer.NewDriver(cgminerClient),
+		avalon.NewDriver(cgminerClient),
+		antminer.NewDriver(minerClient),
+		cgminer.NewDriver(cgminerClient),
+	}
+	ipMapper, err := newIPMapper(cfg)
+	if err != nil {
+		logger.Log.Fatal("Invalid IP mapping", zap.Error(err))
+	}
 
-	scanWorkersUC := usecase.NewScanWorkersUseCase(cfg, workerRepo)
-	scanMinersUC := usecase.NewScanMinersUseCase(cfg, workerRepo, minerStatsRepo)
+	scanWorkersUC := usecase.NewScanWorkersUseCase(cfg, workerRepo, syncRunRepo, snapshotRepo, poolClient, ipMapper)
+	scanMinersUC := usecase.NewScanMinersUseCase(cfg, workerRepo, minerStatsRepo, hashboardRepo, chipLayoutRepo, scanRunRepo, rawArchive, minerDrivers)
 	exportAnalysisUC := usecase.NewExportHashrateAnalysisUseCase(workerRepo, minerStatsRepo)
 	exportUnderperformingUC := usecase.NewExportUnderperformingMinersUseCase(workerRepo, minerStatsRepo)
+	exportWorkerHistoryUC := usecase.NewExportWorkerHistoryUseCase(cfg, snapshotRepo)
+	resolveIPsUC := usecase.NewResolveIPsUseCase(cfg, workerRepo, ipMapper)
+	discoverUC := usecase.NewDiscoverMinersUseCase(cfg, workerRepo, deviceRepo, minerDrivers)
+	exportHottestChainsUC := usecase.NewExportHottestChainsUseCase(minerStatsRepo)
+	asicHealthUC := usecase.NewExportAsicHealthUseCase(minerStatsRepo)
+	hashboardMovesUC := usecase.NewHashboardMovesUseCase(hashboardRepo, minerStatsRepo)
+	fanAlertsUC := usecase.NewExportFanAlertsUseCase(minerStatsRepo)
+	authFailuresUC := usecase.NewExportAuthFailuresUseCase(scanRunRepo)
+	reparseUC := usecase.NewReparseUseCase(cfg, minerStatsRepo, rawArchive, minerDrivers)
+	chipMapUC := usecase.NewChipMapUseCase(minerStatsRepo, chipLayoutRepo)
 	ctx := context.Background()
 
+	// Jobs serve can schedule (config.ScheduleJobs). Subcommand flags are not
+	// parsed under serve, so they hold their command line defaults.
+	jobs := map[string]cron.JobFunc{
+		"fetch-workers": func(ctx context.Context) error {
+			err := scanWorkersUC.Execute(ctx, *fetchAccount, *fetchResume)
+			if err != nil {
+				_, hint := poolErrorExit(err)
+				logger.Log.Warn(hint)
+			}
+			return err
+		},
+		"scan-miners":            scanMinersUC.Execute,
+		"discover":               discoverUC.Execute,
+		"export-analysis":        exportAnalysisUC.Execute,
+		"export-underperforming": exportUnderperformingUC.Execute,
+		"export-hottest-chains": func(ctx context.Context) error {
+			return exportHottestChainsUC.Execute(ctx, time.Now().Add(-*hottestSince), *hottestLimit)
+		},
+		"asic-health": func(ctx context.Context) error {
+			return asicHealthUC.Execute(ctx, time.Now().Add(-*asicHealthSince))
+		},
+		"fan-alerts": func(ctx context.Context) error {
+			return fanAlertsUC.Execute(ctx, time.Now().Add(-*fanAlertsSince))
+		},
+		"auth-failures": authFailuresUC.Execute,
+		"hashboard-moves": func(ctx context.Context) error {
+			return hashboardMovesUC.Execute(ctx, time.Now().Add(-*movesSince))
+		},
+	}
+
 	// 4. Execute Logic based on Subcommand
-	switch os.Args[1] {
+	switch args[0] {
 	case "fetch-workers":
-		fetchWorkersCmd.Parse(os.Args[2:])
+		fetchWorkersCmd.Parse(args[1:])
 		logger.Log.Info(">>> Executing: Fetch Workers from Antpool <<<")
-		if err := scanWorkersUC.Execute(ctx); err != nil {
-			logger.Log.Fatal("Fetch workers failed", zap.Error(err))
+		if err := scanWorkersUC.Execute(ctx, *fetchAccount, *fetchResume); err != nil {
+			code, hint := poolErrorExit(err)
+			logger.Log.Error("Fetch workers failed", zap.Error(err), zap.Int("exitCode", code))
+			fmt.Fprintln(os.Stderr, hint)
+			os.Exit(code)
 		}
 	case "scan-miners":
-		scanMinersCmd.Parse(os.Args[2:])
+		scanMinersCmd.Parse(args[1:])
 		logger.Log.Info(">>> Executing: Scan Miner Stats <<<")
 		if err := scanMinersUC.Execute(ctx); err != nil {
+			if errors.Is(err, usecase.ErrScanFailureRate) {
+				logger.Log.Error("Scan miners failed", zap.Error(err), zap.Int("exitCode", exitScanFailureRate))
+				os.Exit(exitScanFailureRate)
+			}
 			logger.Log.Fatal("Scan miners failed", zap.Error(err))
 		}
 	case "export-analysis":
-		exportAnalysisCmd.Parse(os.Args[2:])
+		exportAnalysisCmd.Parse(args[1:])
 		logger.Log.Info(">>> Executing: Export Hashrate Analysis <<<")
 		if err := exportAnalysisUC.Execute(ctx); err != nil {
 			logger.Log.Fatal("Export analysis failed", zap.Error(err))
 		}
 	case "export-underperforming":
-		exportUnderperformingCmd.Parse(os.Args[2:])
+		exportUnderperformingCmd.Parse(args[1:])
 		logger.Log.Info(">>> Executing: Export Underperforming Miners <<<")
 		if err := exportUnderperformingUC.Execute(ctx); err != nil {
 			logger.Log.Fatal("Export underperforming failed", zap.Error(err))
 		}
+	case "export-worker-history":
+		exportWorkerHistoryCmd.Parse(args[1:])
+		if *historyWorker == "" {
+			exportWorkerHistoryCmd.Usage()
+			os.Exit(1)
+		}
+		logger.Log.Info(">>> Executing: Export Worker Hashrate History <<<")
+		now := time.Now()
+		if err := exportWorkerHistoryUC.Execute(ctx, *historyAccount, *historyCoin, *historyWorker, now.Add(-*historySince), now); err != nil {
+			logger.Log.Fatal("Export worker history failed", zap.Error(err))
+		}
+	case "resolve-ips":
+		resolveIPsCmd.Parse(args[1:])
+		logger.Log.Info(">>> Executing: Resolve Worker IPs <<<")
+		if err := resolveIPsUC.Execute(ctx, *resolveApply); err != nil {
+			logger.Log.Fatal("Resolve IPs failed", zap.Error(err))
+		}
+	case "export-hottest-chains":
+		exportHottestChainsCmd.Parse(args[1:])
+		logger.Log.Info(">>> Executing: Export Hottest Chains <<<")
+		if err := exportHottestChainsUC.Execute(ctx, time.Now().Add(-*hottestSince), *hottestLimit); err != nil {
+			logger.Log.Fatal("Export hottest chains failed", zap.Error(err))
+		}
+	case "asic-health":
+		asicHealthCmd.Parse(args[1:])
+		logger.Log.Info(">>> Executing: Export Asic Health <<<")
+		if err := asicHealthUC.Execute(ctx, time.Now().Add(-*asicHealthSince)); err != nil {
+			logger.Log.Fatal("Export asic health failed", zap.Error(err))
+		}
+	case "fan-alerts":
+		fanAlertsCmd.Parse(args[1:])
+		logger.Log.Info(">>> Executing: Export Fan Alerts <<<")
+		if err := fanAlertsUC.Execute(ctx, time.Now().Add(-*fanAlertsSince)); err != nil {
+			logger.Log.Fatal("Export fan alerts failed", zap.Error(err))
+		}
+	case "auth-failures":
+		authFailuresCmd.Parse(args[1:])
+		logger.Log.Info(">>> Executing: Export Auth Failures <<<")
+		if err := authFailuresUC.Execute(ctx); err != nil {
+			logger.Log.Fatal("Export auth failures failed", zap.Error(err))
+		}
+	case "hashboard-moves":
+		hashboardMovesCmd.Parse(args[1:])
+		logger.Log.Info(">>> Executing: Hashboard Moves Report <<<")
+		if err := hashboardMovesUC.Execute(ctx, time.Now().Add(-*movesSince)); err != nil {
+			logger.Log.Fatal("Hashboard moves report failed", zap.Error(err))
+		}
+	case "reparse":
+		reparseCmd.Parse(args[1:])
+		logger.Log.Info(">>> Executing: Reparse Archived Miner Responses <<<")
+		if err := reparseUC.Execute(ctx, time.Now().Add(-*reparseSince), *reparseIP); err != nil {
+			logger.Log.Fatal("Reparse failed", zap.Error(err))
+		}
+	case "chip-map":
+		chipMapCmd.Parse(args[1:])
+		if *chipMapIP == "" {
+			chipMapCmd.Usage()
+			os.Exit(1)
+		}
+		logger.Log.Info(">>> Executing: Chip Map <<<", zap.String("ip", *chipMapIP))
+		if err := chipMapUC.Execute(ctx, *chipMapIP, *chipMapFormat); err != nil {
+			logger.Log.Fatal("Chip map failed", zap.Error(err))
+		}
+	case "serve":
+		serveCmd.Parse(args[1:])
+		logger.Log.Info(">>> Executing: Serve Scheduled Jobs <<<")
+		if err := serve(ctx, cfg, jobs); err != nil {
+			logger.Log.Fatal("Serve failed", zap.Error(err))
+		}
+	case "discover":
+		discoverCmd.Parse(args[1:])
+		logger.Log.Info(">>> Executing: Discover Miners on LAN <<<")
+		if err := discoverUC.Execute(ctx); err != nil {
+			logger.Log.Fatal("Discover failed", zap.Error(err))
+		}
 	default:
 		printUsage()
 		os.Exit(1)
@@ -89,12 +292,113 @@ func main() {
 	logger.Log.Info("Task completed successfully.")
 }
 
+// Exit codes for fetch-workers and scan-miners, so a cron wrapper can tell credential problems from transient ones
+const (
+	exitFailure           = 1
+	exitPoolAuthExpired   = 3
+	exitPoolRateLimited   = 4
+	exitPoolMaintenance   = 5
+	exitPoolSchemaChanged = 6
+	exitScanFailureRate   = 7
+)
+
+// poolErrorExit maps a fetch-workers error to its exit code and an actionable message
+func poolErrorExit(err error) (int, string) {
+	switch {
+	case errors.Is(err, repository.ErrPoolAuthExpired):
+		return exitPoolAuthExpired, fmt.Sprintf("Antpool rejected the credentials: refresh app.antpool_cookie (%s) or, with app.antpool_auth=api, check api_key/api_secret.", config.EnvName("app.antpool_cookie"))
+	case errors.Is(err, repository.ErrPoolRateLimited):
+		return exitPoolRateLimited, "Antpool rate limit hit: retry later with -resume or lower app.antpool_rps."
+	case errors.Is(err, repository.ErrPoolMaintenance):
+		return exitPoolMaintenance, "Antpool is under maintenance: retry later with -resume."
+	case errors.Is(err, repository.ErrPoolSchemaChanged):
+		return exitPoolSchemaChanged, "Antpool response format changed: the client needs to be updated."
+	}
+	return exitFailure, "fetch-workers failed (rerun with -resume to continue where it stopped): " + err.Error()
+}
+
+// serve runs the configured jobs until SIGINT or SIGTERM, then cancels them
+// and waits up to schedule.shutdown_timeout for them to return
+func serve(ctx context.Context, cfg *config.Config, jobs map[string]cron.JobFunc) error {
+	if len(cfg.Schedule.Jobs) == 0 {
+		return errors.New("schedule.jobs is empty, nothing to serve")
+	}
+
+	scheduler := cron.NewScheduler()
+	for _, j := range cfg.Schedule.Jobs {
+		run, ok := jobs[j.Job]
+		if !ok {
+			return fmt.Errorf("job %s cannot be scheduled", j.Job)
+		}
+		if err := scheduler.Add(j.Job, j.Schedule, run); err != nil {
+			return err
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	return scheduler.Run(ctx, cfg.Schedule.ShutdownTimeout)
+}
+
+// newIPMapper builds the Mapper from the ip_mapping section, loading the override CSV if configured
+func newIPMapper(cfg *config.Config) (*ipmap.Mapper, error) {
+	rules := make([]ipmap.Rule, 0, len(cfg.IPMapping.Rules))
+	for _, r := range cfg.IPMapping.Rules {
+		rules = append(rules, ipmap.Rule{Account: r.Account, Site: r.Site, Pattern: r.Pattern, Template: r.Template})
+	}
+
+	var overrides []ipmap.Override
+	if cfg.IPMapping.OverridesCSV != "" {
+		var err error
+		overrides, err = ipmap.LoadOverridesCSV(cfg.IPMapping.OverridesCSV)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ipmap.New(rules, overrides)
+}
+
+func migrate(db *gorm.DB) error {
+	// workers.worker_id used to be unique on its own; it is now unique per observer account and coin
+	if db.Migrator().HasIndex(&model.Worker{}, "idx_workers_worker_id") {
+		if err := db.Migrator().DropIndex(&model.Worker{}, "idx_workers_worker_id"); err != nil {
+			return err
+		}
+	}
+	return db.AutoMigrate(
+		&model.Worker{}, &model.WorkerSyncRun{}, &model.WorkerHashrateSnapshot{},
+		&model.MinerStats{}, &model.MinerChain{}, &model.MinerChainTemp{},
+		&model.Hashboard{}, &model.HashboardMove{}, &model.ChipLayout{},
+		&model.DiscoveredDevice{},
+		&model.ScanRun{}, &model.ScanOutcome{},
+	)
+}
+
 func printUsage() {
-	fmt.Println("Usage: sacn-miners <subcommand> [options]")
+	fmt.Println("Usage: sacn-miners [--config path] <subcommand> [options]")
 	fmt.Println("\nSubcommands:")
 	fmt.Println("  fetch-workers    Fetch worker list from Antpool and save to DB")
 	fmt.Println("  scan-miners      Scan miner stats using IPs from DB")
 	fmt.Println("  export-analysis  Export hashrate analysis to CSV")
 	fmt.Println("  export-underperforming  Export miners with hashrate below rated value")
+	fmt.Println("  export-worker-history   Export a worker's pool hashrate history (-worker ID -account ID -coin BTC -since 168h)")
+	fmt.Println("  export-hottest-chains   Export the hottest chains by chip temperature (-limit 50 -since 24h)")
+	fmt.Println("  asic-health      Export chains with failed chips and their positions (-since 24h)")
+	fmt.Println("  fan-alerts       Export miners with a missing fan or a fan below app.fan_min_rpm (-since 24h)")
+	fmt.Println("  auth-failures    Export miners that rejected every configured credential in the latest scan run")
+	fmt.Println("  hashboard-moves  Report hashboards that changed miner or slot, or fail to load EEPROM (-since 168h)")
+	fmt.Println("  reparse          Rebuild stored snapshots from archived raw responses (-since 720h -ip IP)")
+	fmt.Println("  chip-map         Draw the chips of each chain of a miner, failed ones marked (-ip IP -format text|svg|html)")
+	fmt.Println("  resolve-ips      Re-apply IP mapping rules, report unmapped workers (-apply to save changes)")
+	fmt.Println("  serve            Run the jobs in schedule.jobs on their cron schedules until SIGINT/SIGTERM")
+	fmt.Println("  discover         Sweep discovery.cidrs for miners, report devices and pool workers that don't match")
+	fmt.Println("\nExit codes:")
+	fmt.Println("  fetch-workers: 3 credentials expired, 4 rate limited, 5 pool maintenance, 6 response format changed, 1 other errors")
+	fmt.Println("  scan-miners:   7 failure rate above app.scan_max_failure_rate, 1 other errors")
+	fmt.Println("\nConfiguration:")
+	fmt.Println("  --config path    YAML config file; keys can be overridden with SCAN_MINERS_<SECTION>_<KEY>,")
+	fmt.Println("                   e.g. SCAN_MINERS_MYSQL_PASSWORD or SCAN_MINERS_APP_ANTPOOL_COOKIE")
+	fmt.Println("                   except " + strings.Join(config.FileOnlyKeys, ", ") + ",")
+	fmt.Println("                   which are read from the file only")
 	fmt.Println("")
 }
@@ -1,48 +1,694 @@
 package config
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
+// EnvPrefix is prepended to every environment variable that overrides a config key.
+// The key "mysql.host" is read from SCAN_MINERS_MYSQL_HOST, and so on.
+const EnvPrefix = "SCAN_MINERS_"
+
+// EnvConfigPath names the config file when --config is not given.
+const EnvConfigPath = EnvPrefix + "CONFIG"
+
 type Config struct {
-	MySQL MySQLConfig
-	App   AppConfig
+	MySQL     MySQLConfig     `yaml:"mysql"`
+	App       AppConfig       `yaml:"app"`
+	IPMapping IPMappingConfig `yaml:"ip_mapping"`
+	Discovery DiscoveryConfig `yaml:"discovery"`
+	Schedule  ScheduleConfig  `yaml:"schedule"`
 }
 
 type MySQLConfig struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	Database string
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Database string `yaml:"database"`
+}
+
+// Antpool authentication modes
+const (
+	// AntpoolAuthCookie uses the observer web endpoints with a pasted browser cookie
+	AntpoolAuthCookie = "cookie"
+	// AntpoolAuthAPI uses the official API signed with each account's key and secret
+	AntpoolAuthAPI = "api"
+)
+
+// MinerDriverAuto lets scan-miners detect each miner's driver and cache it on the worker
+const MinerDriverAuto = "auto"
+
+// MinerDrivers lists the driver names accepted by app.miner_driver; they are
+// the names the drivers report (model.Driver*)
+var MinerDrivers = []string{
+	"vnish",
+	"braiins",
+	"whatsminer",
+	"avalon",
+	"antminer",
+	"cgminer",
 }
 
 type AppConfig struct {
-	AntpoolCookie  string
-	RequestTimeout time.Duration
-	MinerUser      string
-	MinerPassword  string
+	// AntpoolAuth selects how fetch-workers authenticates: "cookie" or "api"
+	AntpoolAuth     string           `yaml:"antpool_auth"`
+	AntpoolCookie   string           `yaml:"antpool_cookie"`
+	AntpoolAPIURL   string           `yaml:"antpool_api_url"`
+	AntpoolAccounts []AntpoolAccount `yaml:"antpool_accounts"`
+	RequestTimeout  time.Duration    `yaml:"request_timeout"`
+	MinerUser       string           `yaml:"miner_user"`
+	MinerPassword   string           `yaml:"miner_password"`
+	// MinerCredentials are tried in order when a miner rejects a credential:
+	// first the sets naming its worker, then those covering its IP, then
+	// MinerUser/MinerPassword
+	MinerCredentials []MinerCredentialSet `yaml:"miner_credentials"`
+
+	// MinerConnectTimeout bounds establishing a connection to a miner;
+	// MinerTimeout bounds reading a response once connected
+	MinerConnectTimeout time.Duration `yaml:"miner_connect_timeout"`
+	MinerTimeout        time.Duration `yaml:"miner_timeout"`
+	// MinerDriver is "auto" or one of MinerDrivers; MinerDriverOverrides sets
+	// it per miner, keyed by worker ID or IP
+	MinerDriver          string            `yaml:"miner_driver"`
+	MinerDriverOverrides map[string]string `yaml:"miner_driver_overrides"`
+	// MinerReprobeInterval is how long a detected driver and stats endpoint are
+	// trusted before detection runs again, so firmware upgrades are noticed; 0 never re-probes
+	MinerReprobeInterval time.Duration `yaml:"miner_reprobe_interval"`
+	// CGMinerPort is the JSON-over-TCP API port of cgminer/bmminer
+	CGMinerPort int `yaml:"cgminer_port"`
+	// FanMinRPM is the speed below which a running fan is reported as fan_low
+	FanMinRPM int `yaml:"fan_min_rpm"`
+	// ScanConcurrency is the number of miners scanned in parallel at the start
+	// of a pass; it is then tuned between ScanConcurrencyMin and
+	// ScanConcurrencyMax, backing off when reachable miners take longer than
+	// ScanTargetLatency (p95) or start failing
+	ScanConcurrency    int           `yaml:"scan_concurrency"`
+	ScanConcurrencyMin int           `yaml:"scan_concurrency_min"`
+	ScanConcurrencyMax int           `yaml:"scan_concurrency_max"`
+	ScanTargetLatency  time.Duration `yaml:"scan_target_latency"`
+	// ScanProbePort is TCP-probed with MinerConnectTimeout before a miner is
+	// read, so dead hosts fail fast; 0 disables the probe. It is the stock
+	// Antminer HTTP port: miners on a cgminer API driver are probed on
+	// CGMinerPort instead, and either port will do while the driver is unknown.
+	ScanProbePort int `yaml:"scan_probe_port"`
+	// ScanMaxFailureRate fails scan-miners (non-zero exit) when a larger share of the scanned miners could not be read
+	ScanMaxFailureRate float64 `yaml:"scan_max_failure_rate"`
+	// PageSize is the number of workers requested per Antpool page
+	PageSize int `yaml:"page_size"`
+	// AntpoolRPS caps Antpool requests per second across all pages and retries
+	AntpoolRPS float64 `yaml:"antpool_rps"`
+	// A failed page is retried up to RetryMaxAttempts times in total, with
+	// jittered exponential backoff starting at RetryBaseDelay, capped at RetryMaxDelay
+	RetryMaxAttempts int           `yaml:"retry_max_attempts"`
+	RetryBaseDelay   time.Duration `yaml:"retry_base_delay"`
+	RetryMaxDelay    time.Duration `yaml:"retry_max_delay"`
+	// CheckpointFile records the next page per account so "fetch-workers -resume" can continue an aborted run
+	CheckpointFile string `yaml:"checkpoint_file"`
+	// RawArchiveDir keeps the raw miner responses of every snapshot, stored
+	// by SHA-256 so "reparse" can rebuild them; empty disables the archive
+	RawArchiveDir  string `yaml:"raw_archive_dir"`
+	RawArchiveGzip bool   `yaml:"raw_archive_gzip"`
+}
+
+// AntpoolAccount is one observer link on Antpool; fetch-workers walks every
+// configured account and tags the stored workers with ObserverUserID and CoinType.
+type AntpoolAccount struct {
+	AccessKey      string `yaml:"access_key"`
+	ObserverUserID string `yaml:"observer_user_id"`
+	CoinType       string `yaml:"coin_type"`
+	// Site groups accounts for ip_mapping rules
+	Site string `yaml:"site"`
+
+	// APIKey and APISecret sign requests when antpool_auth is "api". The
+	// signature's user ID defaults to ObserverUserID when APIUserID is empty.
+	APIKey    string `yaml:"api_key"`
+	APISecret string `yaml:"api_secret"`
+	APIUserID string `yaml:"api_user_id"`
+}
+
+// MinerCredentialSet is an ordered list of digest credentials for the miners
+// of the listed workers or inside the listed CIDRs
+type MinerCredentialSet struct {
+	Workers     []string          `yaml:"workers"`
+	CIDRs       []string          `yaml:"cidrs"`
+	Credentials []MinerCredential `yaml:"credentials"`
+}
+
+type MinerCredential struct {
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+}
+
+// IPMappingConfig turns worker IDs into miner IPs. Overrides from OverridesCSV
+// ("worker_id,ip[,account]") win; otherwise the first matching rule is used.
+type IPMappingConfig struct {
+	Rules        []IPRule `yaml:"rules"`
+	OverridesCSV string   `yaml:"overrides_csv"`
+}
+
+// IPRule maps worker IDs matching Pattern to Template ($1, ${name} expand capture
+// groups). Account (observer user ID) and Site optionally restrict the rule.
+type IPRule struct {
+	Account  string `yaml:"account"`
+	Site     string `yaml:"site"`
+	Pattern  string `yaml:"pattern"`
+	Template string `yaml:"template"`
+}
+
+// DiscoveryConfig drives the "discover" LAN sweep. Every host of every CIDR is
+// TCP-probed on port 80 and App.CGMinerPort within ProbeTimeout; hosts that
+// answer are then run through the miner drivers with the miner credentials.
+type DiscoveryConfig struct {
+	CIDRs        []string      `yaml:"cidrs"`
+	Concurrency  int           `yaml:"concurrency"`
+	ProbeTimeout time.Duration `yaml:"probe_timeout"`
+}
+
+// ScheduleConfig lists the jobs the "serve" daemon runs. Schedule is a
+// 5-field cron expression ("*/10 * * * *") or "@every 10m"; each job may be
+// listed once. On SIGINT/SIGTERM running jobs are cancelled and get
+// ShutdownTimeout to finish; scan-miners lets the miners it is scanning finish
+// for up to half of it.
+type ScheduleConfig struct {
+	Jobs            []ScheduledJob `yaml:"jobs"`
+	ShutdownTimeout time.Duration  `yaml:"shutdown_timeout"`
+}
+
+type ScheduledJob struct {
+	Job      string `yaml:"job"`
+	Schedule string `yaml:"schedule"`
 }
 
-func Load() *Config {
-	// In a real application, you would load this from env or a file (viper)
-	// Based on requirements:
+// ScheduleJobs are the subcommands serve can run; they use the same defaults
+// as on the command line
+var ScheduleJobs = []string{
+	"fetch-workers",
+	"scan-miners",
+	"discover",
+	"export-analysis",
+	"export-underperforming",
+	"export-hottest-chains",
+	"asic-health",
+	"fan-alerts",
+	"auth-failures",
+	"hashboard-moves",
+}
+
+// Default returns the configuration used for every key that is neither in the
+// config file nor in the environment.
+func Default() *Config {
 	return &Config{
 		MySQL: MySQLConfig{
-			Host:     "8.137.94.107",
-			Port:     "3306", // Default port, not specified but assumed
-			User:     "admin",
-			Password: "admin$2026",
-			Database: "myapp_db",
+			Port: "3306",
 		},
 		App: AppConfig{
-			// Cookie from requirements
-			AntpoolCookie:  `_uab_collina=176543110684584189051945; _ga=GA1.1.2019618821.1765431115; _ga_0ZDBQJ5SB7=GS2.1.s1766753979$o1$g1$t1766754049$j58$l0$h0; tfstk=gbu-QC9_8KvoSEYXBaxDKeeE2jADincyZYl1-J2lAxHx1fWuA3l3OXMj3yqBUz0KHYk4q00KTwFIOvRzKQ-mabzURdviJFcraP_fAlp0RBGb-WLET84SabzFgxHq5WGyJD397WaIdrZb_WzCV7wWMoNgOy_7AaGbh-PQRyZ5Powb95S5RgsWMjw4OywIdyOYl-PQRJMQRApfwJf7pwnuCu3CkpAm-2gYwuesMn7CJY_g2RiYHw9sk7BQCbwARww_SeRoMbvR7j4rSAFiEE_-BXGZAo3dhdeEkjgSAjWJdSoIErwu531Yr4lSfkg6LMMTyvEsPoCCyraYdrFj5Bjanqeod4EXsNE3lVq_Pmx2Ek486vgre6QICjcizoupBtwEq5zb92R1vJEC4D0iW_WfIRFhVIdAYMr7g71hLO24UT0UMRAcmMSUmSPYIIdAYMr7gSeMiEjFYoVV.; _c_WBKFRo=w3XSPKSsoD4FjHfWTDyzqITMtrRfNAIcQRD5kE5y; language=zh; informedConsent=1; wwwroute=1769654858.882.1806.809786; JSESSIONID=AF3D4670959027C5F83143C3B67739B0; acw_tc=ac11000117696748984088047e2d4bbf77c97a5515fee3b3a48eb31351eae5; _ga_Y4GT96XRF0=GS2.1.s1769675628$o62$g0$t1769675628$j60$l0$h0`,
-			RequestTimeout: 30 * time.Second,
-			MinerUser:      "root",
-			MinerPassword:  "root",
+			AntpoolAuth:          AntpoolAuthCookie,
+			AntpoolAPIURL:        "https://antpool.com/api",
+			RequestTimeout:       30 * time.Second,
+			MinerUser:            "root",
+			MinerPassword:        "root",
+			MinerConnectTimeout:  time.Second,
+			MinerTimeout:         5 * time.Second,
+			MinerDriver:          MinerDriverAuto,
+			MinerReprobeInterval: 24 * time.Hour,
+			CGMinerPort:          4028,
+			FanMinRPM:            1000,
+			ScanConcurrency:      50,
+			ScanConcurrencyMin:   10,
+			ScanConcurrencyMax:   200,
+			ScanTargetLatency:    3 * time.Second,
+			ScanProbePort:        80,
+			ScanMaxFailureRate:   0.2,
+			PageSize:             100,
+			AntpoolRPS:           2,
+			RetryMaxAttempts:     5,
+			RetryBaseDelay:       time.Second,
+			RetryMaxDelay:        30 * time.Second,
+			CheckpointFile:       "fetch-workers.checkpoint.json",
+			RawArchiveGzip:       true,
+		},
+		IPMapping: IPMappingConfig{
+			// Historical rule: worker "30x182" lives at 172.16.30.182
+			Rules: []IPRule{
+				{Pattern: `^(\d{1,3})x(\d{1,3})$`, Template: "172.16.$1.$2"},
+			},
+		},
+		Discovery: DiscoveryConfig{
+			Concurrency:  256,
+			ProbeTimeout: time.Second,
 		},
+		Schedule: ScheduleConfig{
+			ShutdownTimeout: 2 * time.Minute,
+		},
+	}
+}
+
+// Load builds the configuration from defaults, the YAML file at path (falling
+// back to $SCAN_MINERS_CONFIG, skipped when both are empty) and SCAN_MINERS_*
+// environment variables, in that order of precedence, then fills in derived
+// defaults and validates the result.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path == "" {
+		path = os.Getenv(EnvConfigPath)
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.applyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+
+	cfg.normalize()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: read %s: %w", path, err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true) // Typos in key names should fail loudly instead of being ignored
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config: parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// FileOnlyKeys are the list and map keys that have no environment form and can
+// only be set in the config file.
+var FileOnlyKeys = []string{
+	"app.miner_credentials",
+	"app.miner_driver_overrides",
+	"ip_mapping.rules",
+	"schedule.jobs",
+}
+
+// binding ties a dotted config key to a setter that parses its string form.
+type binding struct {
+	key string
+	set func(string) error
+}
+
+func (c *Config) bindings() []binding {
+	return []binding{
+		{"mysql.host", stringVar(&c.MySQL.Host)},
+		{"mysql.port", stringVar(&c.MySQL.Port)},
+		{"mysql.user", stringVar(&c.MySQL.User)},
+		{"mysql.password", stringVar(&c.MySQL.Password)},
+		{"mysql.database", stringVar(&c.MySQL.Database)},
+		{"app.antpool_auth", stringVar(&c.App.AntpoolAuth)},
+		{"app.antpool_cookie", stringVar(&c.App.AntpoolCookie)},
+		{"app.antpool_api_url", stringVar(&c.App.AntpoolAPIURL)},
+		{"app.antpool_accounts", accountsVar(&c.App.AntpoolAccounts)},
+		{"app.request_timeout", durationVar(&c.App.RequestTimeout)},
+		{"app.miner_user", stringVar(&c.App.MinerUser)},
+		{"app.miner_password", stringVar(&c.App.MinerPassword)},
+		{"app.miner_connect_timeout", durationVar(&c.App.MinerConnectTimeout)},
+		{"app.miner_timeout", durationVar(&c.App.MinerTimeout)},
+		{"app.miner_driver", stringVar(&c.App.MinerDriver)},
+		{"app.miner_reprobe_interval", durationVar(&c.App.MinerReprobeInterval)},
+		{"app.cgminer_port", intVar(&c.App.CGMinerPort)},
+		{"app.fan_min_rpm", intVar(&c.App.FanMinRPM)},
+		{"app.scan_concurrency", intVar(&c.App.ScanConcurrency)},
+		{"app.scan_concurrency_min", intVar(&c.App.ScanConcurrencyMin)},
+		{"app.scan_concurrency_max", intVar(&c.App.ScanConcurrencyMax)},
+		{"app.scan_target_latency", durationVar(&c.App.ScanTargetLatency)},
+		{"app.scan_probe_port", intVar(&c.App.ScanProbePort)},
+		{"app.scan_max_failure_rate", floatVar(&c.App.ScanMaxFailureRate)},
+		{"app.page_size", intVar(&c.App.PageSize)},
+		{"app.antpool_rps", floatVar(&c.App.AntpoolRPS)},
+		{"app.retry_max_attempts", intVar(&c.App.RetryMaxAttempts)},
+		{"app.retry_base_delay", durationVar(&c.App.RetryBaseDelay)},
+		{"app.retry_max_delay", durationVar(&c.App.RetryMaxDelay)},
+		{"app.checkpoint_file", stringVar(&c.App.CheckpointFile)},
+		{"app.raw_archive_dir", stringVar(&c.App.RawArchiveDir)},
+		{"app.raw_archive_gzip", boolVar(&c.App.RawArchiveGzip)},
+		{"ip_mapping.overrides_csv", stringVar(&c.IPMapping.OverridesCSV)},
+		{"discovery.cidrs", listVar(&c.Discovery.CIDRs)},
+		{"discovery.concurrency", intVar(&c.Discovery.Concurrency)},
+		{"discovery.probe_timeout", durationVar(&c.Discovery.ProbeTimeout)},
+		{"schedule.shutdown_timeout", durationVar(&c.Schedule.ShutdownTimeout)},
+	}
+}
+
+// EnvName returns the environment variable that overrides the given config key.
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+func (c *Config) applyEnv(lookup func(string) (string, bool)) error {
+	for _, b := range c.bindings() {
+		name := EnvName(b.key)
+		val, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		if err := b.set(val); err != nil {
+			return fmt.Errorf("config: %s (from %s): %w", b.key, name, err)
+		}
+	}
+	return nil
+}
+
+// normalize trims list entries and fills in the keys whose default depends on
+// other keys. Validate only checks, so it runs after this.
+func (c *Config) normalize() {
+	for i := range c.App.AntpoolAccounts {
+		acc := &c.App.AntpoolAccounts[i]
+		if acc.CoinType == "" {
+			acc.CoinType = "BTC"
+		}
+		if acc.APIUserID == "" {
+			acc.APIUserID = acc.ObserverUserID
+		}
+	}
+	for i := range c.Discovery.CIDRs {
+		c.Discovery.CIDRs[i] = strings.TrimSpace(c.Discovery.CIDRs[i])
+	}
+	for i := range c.Schedule.Jobs {
+		c.Schedule.Jobs[i].Job = strings.TrimSpace(c.Schedule.Jobs[i].Job)
+		c.Schedule.Jobs[i].Schedule = strings.TrimSpace(c.Schedule.Jobs[i].Schedule)
+	}
+}
+
+// Validate reports the first missing or malformed key.
+func (c *Config) Validate() error {
+	required := map[string]string{
+		"mysql.host":     c.MySQL.Host,
+		"mysql.port":     c.MySQL.Port,
+		"mysql.user":     c.MySQL.User,
+		"mysql.database": c.MySQL.Database,
+		"app.miner_user": c.App.MinerUser,
+	}
+	// Iterate in binding order so the reported key is deterministic
+	for _, b := range c.bindings() {
+		if val, ok := required[b.key]; ok && val == "" {
+			return missingError(b.key)
+		}
+	}
+
+	switch c.App.AntpoolAuth {
+	case AntpoolAuthCookie, AntpoolAuthAPI:
+	default:
+		return fmt.Errorf("config: app.antpool_auth: %q is not one of %q, %q", c.App.AntpoolAuth, AntpoolAuthCookie, AntpoolAuthAPI)
+	}
+
+	if !validMinerDriver(c.App.MinerDriver) {
+		return fmt.Errorf("config: app.miner_driver: %q is not %q or one of %q", c.App.MinerDriver, MinerDriverAuto, MinerDrivers)
+	}
+	for key, driver := range c.App.MinerDriverOverrides {
+		if !validMinerDriver(driver) {
+			return fmt.Errorf("config: app.miner_driver_overrides[%s]: %q is not %q or one of %q", key, driver, MinerDriverAuto, MinerDrivers)
+		}
+	}
+	for i, set := range c.App.MinerCredentials {
+		if len(set.Workers) == 0 && len(set.CIDRs) == 0 {
+			return fmt.Errorf("config: app.miner_credentials[%d]: workers or cidrs is required", i)
+		}
+		if slices.Contains(set.Workers, "") {
+			return fmt.Errorf("config: app.miner_credentials[%d].workers: worker IDs must not be empty", i)
+		}
+		for _, cidr := range set.CIDRs {
+			if _, err := netip.ParsePrefix(cidr); err != nil {
+				return fmt.Errorf("config: app.miner_credentials[%d].cidrs: %q is not a CIDR", i, cidr)
+			}
+		}
+		if len(set.Credentials) == 0 {
+			return fmt.Errorf("config: app.miner_credentials[%d].credentials is required", i)
+		}
+		for j, cred := range set.Credentials {
+			if cred.User == "" {
+				return fmt.Errorf("config: app.miner_credentials[%d].credentials[%d].user is required", i, j)
+			}
+		}
+	}
+	if c.App.MinerReprobeInterval < 0 {
+		return fmt.Errorf("config: app.miner_reprobe_interval must not be negative, got %s", c.App.MinerReprobeInterval)
+	}
+	if c.App.CGMinerPort <= 0 || c.App.CGMinerPort > 65535 {
+		return fmt.Errorf("config: app.cgminer_port: %d is not a valid port", c.App.CGMinerPort)
+	}
+	if c.App.FanMinRPM < 0 {
+		return fmt.Errorf("config: app.fan_min_rpm must not be negative, got %d", c.App.FanMinRPM)
+	}
+
+	for i, acc := range c.App.AntpoolAccounts {
+		if c.App.AntpoolAuth == AntpoolAuthCookie && acc.AccessKey == "" {
+			return fmt.Errorf("config: app.antpool_accounts[%d].access_key is required", i)
+		}
+		if c.App.AntpoolAuth == AntpoolAuthAPI && (acc.APIKey == "" || acc.APISecret == "") {
+			return fmt.Errorf("config: app.antpool_accounts[%d].api_key and api_secret are required when app.antpool_auth is %q", i, AntpoolAuthAPI)
+		}
+		if acc.ObserverUserID == "" {
+			return fmt.Errorf("config: app.antpool_accounts[%d].observer_user_id is required", i)
+		}
+	}
+
+	for i, r := range c.IPMapping.Rules {
+		if r.Pattern == "" || r.Template == "" {
+			return fmt.Errorf("config: ip_mapping.rules[%d]: pattern and template are required", i)
+		}
+	}
+
+	for i, cidr := range c.Discovery.CIDRs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return fmt.Errorf("config: discovery.cidrs[%d]: %q is not a CIDR", i, cidr)
+		}
+		// A /16 is already 65k probes; anything wider is almost certainly a typo
+		if !prefix.Addr().Is4() || prefix.Bits() < 16 {
+			return fmt.Errorf("config: discovery.cidrs[%d]: %q must be an IPv4 range of /16 or narrower", i, cidr)
+		}
+	}
+
+	if _, err := strconv.Atoi(c.MySQL.Port); err != nil {
+		return fmt.Errorf("config: mysql.port: %q is not a number", c.MySQL.Port)
+	}
+
+	positiveDurations := []struct {
+		key string
+		val time.Duration
+	}{
+		{"app.request_timeout", c.App.RequestTimeout},
+		{"app.miner_connect_timeout", c.App.MinerConnectTimeout},
+		{"app.miner_timeout", c.App.MinerTimeout},
+		{"app.scan_target_latency", c.App.ScanTargetLatency},
+		{"app.retry_base_delay", c.App.RetryBaseDelay},
+		{"app.retry_max_delay", c.App.RetryMaxDelay},
+		{"discovery.probe_timeout", c.Discovery.ProbeTimeout},
+		{"schedule.shutdown_timeout", c.Schedule.ShutdownTimeout},
+	}
+	for _, d := range positiveDurations {
+		if d.val <= 0 {
+			return fmt.Errorf("config: %s must be positive, got %s", d.key, d.val)
+		}
+	}
+	if c.App.AntpoolRPS <= 0 {
+		return fmt.Errorf("config: app.antpool_rps must be positive, got %g", c.App.AntpoolRPS)
+	}
+	if c.App.RetryMaxAttempts <= 0 {
+		return fmt.Errorf("config: app.retry_max_attempts must be positive, got %d", c.App.RetryMaxAttempts)
+	}
+
+	if c.App.ScanConcurrency <= 0 {
+		return fmt.Errorf("config: app.scan_concurrency must be positive, got %d", c.App.ScanConcurrency)
+	}
+	if c.App.ScanConcurrencyMin <= 0 || c.App.ScanConcurrencyMin > c.App.ScanConcurrency || c.App.ScanConcurrency > c.App.ScanConcurrencyMax {
+		return fmt.Errorf("config: app.scan_concurrency_min (%d) <= app.scan_concurrency (%d) <= app.scan_concurrency_max (%d) must hold, with a positive minimum",
+			c.App.ScanConcurrencyMin, c.App.ScanConcurrency, c.App.ScanConcurrencyMax)
+	}
+	if c.App.ScanProbePort < 0 || c.App.ScanProbePort > 65535 {
+		return fmt.Errorf("config: app.scan_probe_port: %d is not a valid port", c.App.ScanProbePort)
+	}
+	if c.App.ScanMaxFailureRate < 0 || c.App.ScanMaxFailureRate > 1 {
+		return fmt.Errorf("config: app.scan_max_failure_rate must be between 0 and 1, got %g", c.App.ScanMaxFailureRate)
+	}
+	if c.Discovery.Concurrency <= 0 {
+		return fmt.Errorf("config: discovery.concurrency must be positive, got %d", c.Discovery.Concurrency)
+	}
+
+	scheduled := map[string]bool{}
+	for i, j := range c.Schedule.Jobs {
+		if !slices.Contains(ScheduleJobs, j.Job) {
+			return fmt.Errorf("config: schedule.jobs[%d].job: %q is not one of %q", i, j.Job, ScheduleJobs)
+		}
+		if scheduled[j.Job] {
+			return fmt.Errorf("config: schedule.jobs[%d].job: %s is listed twice", i, j.Job)
+		}
+		scheduled[j.Job] = true
+		if j.Schedule == "" {
+			return fmt.Errorf("config: schedule.jobs[%d].schedule is required", i)
+		}
+	}
+	if c.App.PageSize <= 0 || c.App.PageSize > 1000 {
+		return fmt.Errorf("config: app.page_size must be between 1 and 1000, got %d", c.App.PageSize)
+	}
+	return nil
+}
+
+func validMinerDriver(driver string) bool {
+	return driver == MinerDriverAuto || slices.Contains(MinerDrivers, driver)
+}
+
+// MinerDriverFor returns the configured driver for a worker, honouring overrides by worker ID, then by IP
+func (c *AppConfig) MinerDriverFor(workerID, ip string) string {
+	if driver, ok := c.MinerDriverOverrides[workerID]; ok {
+		return driver
+	}
+	if driver, ok := c.MinerDriverOverrides[ip]; ok {
+		return driver
+	}
+	return c.MinerDriver
+}
+
+// MinerCredentialsFor returns the credentials to try on a miner, in order and
+// without duplicates: the sets naming the worker, the sets whose CIDRs contain
+// ip, then MinerUser/MinerPassword
+func (c *AppConfig) MinerCredentialsFor(workerID, ip string) []MinerCredential {
+	var byWorker, byIP []MinerCredential
+	addr, _ := netip.ParseAddr(ip)
+	for _, set := range c.MinerCredentials {
+		switch {
+		case workerID != "" && slices.Contains(set.Workers, workerID):
+			byWorker = append(byWorker, set.Credentials...)
+		case addr.IsValid() && slices.ContainsFunc(set.CIDRs, func(cidr string) bool {
+			prefix, err := netip.ParsePrefix(cidr)
+			return err == nil && prefix.Contains(addr)
+		}):
+			byIP = append(byIP, set.Credentials...)
+		}
+	}
+
+	var creds []MinerCredential
+	for _, cred := range append(append(byWorker, byIP...), MinerCredential{User: c.MinerUser, Password: c.MinerPassword}) {
+		if !slices.Contains(creds, cred) {
+			creds = append(creds, cred)
+		}
+	}
+	return creds
+}
+
+func missingError(key string) error {
+	return fmt.Errorf("config: %s is required (set it in the config file or %s)", key, EnvName(key))
+}
+
+func stringVar(p *string) func(string) error {
+	return func(s string) error {
+		*p = s
+		return nil
+	}
+}
+
+func intVar(p *int) func(string) error {
+	return func(s string) error {
+		v, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", s)
+		}
+		*p = v
+		return nil
+	}
+}
+
+func boolVar(p *bool) func(string) error {
+	return func(s string) error {
+		v, err := strconv.ParseBool(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", s)
+		}
+		*p = v
+		return nil
+	}
+}
+
+func floatVar(p *float64) func(string) error {
+	return func(s string) error {
+		v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		*p = v
+		return nil
+	}
+}
+
+func durationVar(p *time.Duration) func(string) error {
+	return func(s string) error {
+		v, err := time.ParseDuration(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", s)
+		}
+		*p = v
+		return nil
+	}
+}
+
+// listVar parses a comma separated list, dropping empty entries.
+func listVar(p *[]string) func(string) error {
+	return func(s string) error {
+		var list []string
+		for _, entry := range strings.Split(s, ",") {
+			if entry = strings.TrimSpace(entry); entry != "" {
+				list = append(list, entry)
+			}
+		}
+		*p = list
+		return nil
+	}
+}
+
+// SiteOf returns the site of the configured account with the given observer user ID
+func (c *Config) SiteOf(observerUserID string) string {
+	for _, acc := range c.App.AntpoolAccounts {
+		if acc.ObserverUserID == observerUserID {
+			return acc.Site
+		}
+	}
+	return ""
+}
+
+// accountsVar parses "accessKey:observerUserId[:coinType]" entries separated by commas.
+func accountsVar(p *[]AntpoolAccount) func(string) error {
+	return func(s string) error {
+		var accounts []AntpoolAccount
+		for _, entry := range strings.Split(s, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			parts := strings.Split(entry, ":")
+			if len(parts) < 2 || len(parts) > 3 {
+				return fmt.Errorf("invalid account %q, want accessKey:observerUserId[:coinType]", entry)
+			}
+			acc := AntpoolAccount{AccessKey: parts[0], ObserverUserID: parts[1]}
+			if len(parts) == 3 {
+				acc.CoinType = parts[2]
+			}
+			accounts = append(accounts, acc)
+		}
+		*p = accounts
+		return nil
 	}
 }
 
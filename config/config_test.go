@@ -0,0 +1,215 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// validConfig returns a configuration that passes Validate
+func validConfig() *Config {
+	cfg := Default()
+	cfg.MySQL = MySQLConfig{Host: "db", Port: "3306", User: "scan", Database: "miners"}
+	cfg.App.AntpoolAccounts = []AntpoolAccount{{AccessKey: "key", ObserverUserID: "observer", CoinType: "BTC"}}
+	return cfg
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfig(t, `
+mysql:
+  host: file-host
+  port: "3306"
+  user: scan
+  database: miners
+app:
+  miner_timeout: 9s
+  antpool_accounts:
+    - access_key: key
+      observer_user_id: observer
+discovery:
+  cidrs: [" 10.0.0.0/24 "]
+schedule:
+  jobs:
+    - job: " scan-miners "
+      schedule: " @every 10m "
+`)
+	t.Setenv(EnvName("mysql.host"), "env-host")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MySQL.Host != "env-host" {
+		t.Errorf("mysql.host = %q, the environment should win over the file", cfg.MySQL.Host)
+	}
+	if cfg.App.MinerTimeout != 9*time.Second {
+		t.Errorf("app.miner_timeout = %s, the file should win over the default", cfg.App.MinerTimeout)
+	}
+	if cfg.App.MinerConnectTimeout != time.Second {
+		t.Errorf("app.miner_connect_timeout = %s, want the default", cfg.App.MinerConnectTimeout)
+	}
+
+	acc := cfg.App.AntpoolAccounts[0]
+	if acc.CoinType != "BTC" || acc.APIUserID != "observer" {
+		t.Errorf("account defaults not applied: %+v", acc)
+	}
+	if cfg.Discovery.CIDRs[0] != "10.0.0.0/24" {
+		t.Errorf("discovery.cidrs not trimmed: %q", cfg.Discovery.CIDRs[0])
+	}
+	if j := cfg.Schedule.Jobs[0]; j.Job != "scan-miners" || j.Schedule != "@every 10m" {
+		t.Errorf("schedule.jobs not trimmed: %+v", j)
+	}
+}
+
+func TestLoadRejectsUnknownKeys(t *testing.T) {
+	path := writeConfig(t, "app:\n  miner_timeuot: 5s\n")
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "miner_timeuot") {
+		t.Fatalf("Load() error = %v, want one naming the misspelt key", err)
+	}
+}
+
+func TestAccountsEnv(t *testing.T) {
+	var accounts []AntpoolAccount
+	if err := accountsVar(&accounts)("k1:obs1, k2:obs2:LTC,"); err != nil {
+		t.Fatal(err)
+	}
+	want := []AntpoolAccount{
+		{AccessKey: "k1", ObserverUserID: "obs1"},
+		{AccessKey: "k2", ObserverUserID: "obs2", CoinType: "LTC"},
+	}
+	if len(accounts) != len(want) {
+		t.Fatalf("got %d accounts, want %d", len(accounts), len(want))
+	}
+	for i := range want {
+		if accounts[i] != want[i] {
+			t.Errorf("account %d = %+v, want %+v", i, accounts[i], want[i])
+		}
+	}
+
+	if err := accountsVar(&accounts)("only-a-key"); err == nil {
+		t.Error("an account without observer user ID should be rejected")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(*Config)
+		wantErr string
+	}{
+		{"valid", func(*Config) {}, ""},
+		{"missing host", func(c *Config) { c.MySQL.Host = "" }, "mysql.host is required"},
+		{"unknown auth", func(c *Config) { c.App.AntpoolAuth = "token" }, "app.antpool_auth"},
+		{"api without secret", func(c *Config) { c.App.AntpoolAuth = AntpoolAuthAPI }, "api_key and api_secret are required"},
+		{"unknown driver", func(c *Config) { c.App.MinerDriver = "bitmain" }, "app.miner_driver"},
+		{"unknown override", func(c *Config) { c.App.MinerDriverOverrides = map[string]string{"10.0.0.1": "x"} }, "app.miner_driver_overrides[10.0.0.1]"},
+		{"credentials without target", func(c *Config) {
+			c.App.MinerCredentials = []MinerCredentialSet{{Credentials: []MinerCredential{{User: "root"}}}}
+		}, "workers or cidrs is required"},
+		{"credentials with bad cidr", func(c *Config) {
+			c.App.MinerCredentials = []MinerCredentialSet{{CIDRs: []string{"10.0.0/8"}, Credentials: []MinerCredential{{User: "root"}}}}
+		}, "is not a CIDR"},
+		{"credential without user", func(c *Config) {
+			c.App.MinerCredentials = []MinerCredentialSet{{Workers: []string{"w1"}, Credentials: []MinerCredential{{Password: "x"}}}}
+		}, "credentials[0].user is required"},
+		{"wide discovery range", func(c *Config) { c.Discovery.CIDRs = []string{"10.0.0.0/8"} }, "/16 or narrower"},
+		{"untrimmed discovery range", func(c *Config) { c.Discovery.CIDRs = []string{" 10.0.0.0/24"} }, "is not a CIDR"},
+		{"concurrency out of bounds", func(c *Config) { c.App.ScanConcurrency = c.App.ScanConcurrencyMax + 1 }, "app.scan_concurrency_min"},
+		{"zero timeout", func(c *Config) { c.App.MinerTimeout = 0 }, "app.miner_timeout must be positive"},
+		{"unknown job", func(c *Config) { c.Schedule.Jobs = []ScheduledJob{{Job: "scan", Schedule: "@hourly"}} }, "schedule.jobs[0].job"},
+		{"duplicate job", func(c *Config) {
+			c.Schedule.Jobs = []ScheduledJob{{Job: "scan-miners", Schedule: "@hourly"}, {Job: "scan-miners", Schedule: "@daily"}}
+		}, "listed twice"},
+		{"page size", func(c *Config) { c.App.PageSize = 1001 }, "app.page_size"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.mutate(cfg)
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateDoesNotModify(t *testing.T) {
+	cfg := validConfig()
+	cfg.App.AntpoolAccounts[0].CoinType = ""
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if acc := cfg.App.AntpoolAccounts[0]; acc.CoinType != "" || acc.APIUserID != "" {
+		t.Errorf("Validate filled in defaults: %+v", acc)
+	}
+}
+
+// configKeys lists the dotted YAML keys of the non-struct fields of t
+func configKeys(prefix string, t reflect.Type) []string {
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		if f.Type.Kind() == reflect.Struct {
+			keys = append(keys, configKeys(prefix+name+".", f.Type)...)
+			continue
+		}
+		keys = append(keys, prefix+name)
+	}
+	return keys
+}
+
+func TestEveryKeyHasEnvOrIsFileOnly(t *testing.T) {
+	bound := map[string]bool{}
+	for _, b := range Default().bindings() {
+		bound[b.key] = true
+	}
+	for _, key := range configKeys("", reflect.TypeOf(Config{})) {
+		fileOnly := slices.Contains(FileOnlyKeys, key)
+		switch {
+		case bound[key] && fileOnly:
+			t.Errorf("%s has an environment binding but is listed in FileOnlyKeys", key)
+		case !bound[key] && !fileOnly:
+			t.Errorf("%s has no environment binding and is missing from FileOnlyKeys", key)
+		}
+	}
+}
+
+func TestFileOnlyKeysIgnoreEnv(t *testing.T) {
+	cfg := Default()
+	env := map[string]string{EnvName("schedule.jobs"): "scan-miners", EnvName("ip_mapping.rules"): "x"}
+	err := cfg.applyEnv(func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Default()
+	if !reflect.DeepEqual(cfg.Schedule.Jobs, want.Schedule.Jobs) || !reflect.DeepEqual(cfg.IPMapping.Rules, want.IPMapping.Rules) {
+		t.Errorf("file-only keys were set from the environment: %+v %+v", cfg.Schedule.Jobs, cfg.IPMapping.Rules)
+	}
+}
@@ -0,0 +1,24 @@
+package config_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/beatyman/scan-miners/config"
+	"github.com/beatyman/scan-miners/internal/domain/model"
+)
+
+// config keeps its own copy of the driver names so it does not depend on the domain
+func TestMinerDriversMatchModel(t *testing.T) {
+	drivers := []string{
+		model.DriverVNish,
+		model.DriverBraiins,
+		model.DriverWhatsminer,
+		model.DriverAvalon,
+		model.DriverAntminer,
+		model.DriverCGMiner,
+	}
+	if !slices.Equal(config.MinerDrivers, drivers) {
+		t.Errorf("config.MinerDrivers = %q, want %q", config.MinerDrivers, drivers)
+	}
+}
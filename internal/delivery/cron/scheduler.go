@@ -0,0 +1,116 @@
+// Package cron runs the application's jobs on cron schedules for the serve
+// subcommand.
+package cron
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/beatyman/scan-miners/pkg/logger"
+	robfig "github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+)
+
+// JobFunc runs one job to completion; ctx is cancelled on shutdown
+type JobFunc func(ctx context.Context) error
+
+type job struct {
+	name string
+	spec string
+	run  JobFunc
+	// running holds a token while the job runs
+	running chan struct{}
+}
+
+// Scheduler runs named jobs on standard 5-field cron expressions or
+// "@every <duration>". A job that is still running when it is due again is
+// skipped, so runs of the same job never overlap; different jobs may run
+// at the same time.
+type Scheduler struct {
+	jobs []job
+}
+
+func NewScheduler() *Scheduler {
+	return &Scheduler{}
+}
+
+// Add registers a job; spec is validated here so a typo fails at startup
+func (s *Scheduler) Add(name, spec string, run JobFunc) error {
+	if _, err := robfig.ParseStandard(spec); err != nil {
+		return fmt.Errorf("job %s: invalid schedule %q: %w", name, spec, err)
+	}
+	s.jobs = append(s.jobs, job{name: name, spec: spec, run: run, running: make(chan struct{}, 1)})
+	return nil
+}
+
+// Run starts the jobs and blocks until ctx is done. Running jobs then see
+// ctx cancelled and get shutdownTimeout to return before Run gives up on them.
+func (s *Scheduler) Run(ctx context.Context, shutdownTimeout time.Duration) error {
+	log := cronLogger{logger.Log.Sugar()}
+	c := robfig.New(
+		robfig.WithLogger(log),
+		robfig.WithChain(robfig.Recover(log)),
+	)
+
+	for _, j := range s.jobs {
+		id, err := c.AddFunc(j.spec, func() { runJob(ctx, j) })
+		if err != nil {
+			return fmt.Errorf("job %s: %w", j.name, err)
+		}
+		logger.Log.Info("Scheduled job", zap.String("job", j.name), zap.String("schedule", j.spec), zap.Time("next", c.Entry(id).Schedule.Next(time.Now())))
+	}
+
+	c.Start()
+	<-ctx.Done()
+	logger.Log.Info("Shutting down scheduler, waiting for running jobs", zap.Duration("timeout", shutdownTimeout))
+
+	// Stop prevents new runs; its context is done once the running ones returned
+	stopped := c.Stop()
+	select {
+	case <-stopped.Done():
+		logger.Log.Info("All jobs finished")
+		return nil
+	case <-time.After(shutdownTimeout):
+		return fmt.Errorf("jobs still running after %s", shutdownTimeout)
+	}
+}
+
+func runJob(ctx context.Context, j job) {
+	if ctx.Err() != nil {
+		return
+	}
+	select {
+	case j.running <- struct{}{}:
+		defer func() { <-j.running }()
+	default:
+		logger.Log.Warn("Skipped scheduled run, previous run still in progress", zap.String("job", j.name))
+		return
+	}
+
+	logger.Log.Info(">>> Running scheduled job <<<", zap.String("job", j.name))
+	started := time.Now()
+	if err := j.run(ctx); err != nil {
+		if ctx.Err() != nil {
+			logger.Log.Info("Scheduled job interrupted by shutdown", zap.String("job", j.name), zap.Error(err))
+			return
+		}
+		logger.Log.Error("Scheduled job failed", zap.String("job", j.name), zap.Duration("duration", time.Since(started)), zap.Error(err))
+		return
+	}
+	logger.Log.Info("Scheduled job finished", zap.String("job", j.name), zap.Duration("duration", time.Since(started)))
+}
+
+// cronLogger adapts zap to the logger robfig/cron reports to, e.g. recovered panics
+type cronLogger struct {
+	log *zap.SugaredLogger
+}
+
+// Info carries routine scheduling chatter ("wake", "run"), kept at debug
+func (l cronLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.log.Debugw(msg, keysAndValues...)
+}
+
+func (l cronLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	l.log.Errorw(msg, append(keysAndValues, "error", err)...)
+}
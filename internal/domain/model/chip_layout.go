@@ -0,0 +1,70 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ChipLayout is the physical chip arrangement of a miner type's hashboards,
+// taken from the "tpl" matrix of stats.cgi. Every cell holds the position of
+// a chip in the asic string; EmptyCell marks a cell without a chip.
+type ChipLayout struct {
+	ID        uint   `gorm:"primaryKey"`
+	MinerType string `gorm:"type:varchar(64);uniqueIndex"`
+	NumRows   int
+	NumCols   int
+	Layout    string `gorm:"type:text"` // JSON [][]int of 0-based positions
+
+	UpdatedAt time.Time
+}
+
+// EmptyCell marks a layout cell without a chip
+const EmptyCell = -1
+
+// NewChipLayout normalises a tpl matrix. Firmwares number chips from 1, so a
+// matrix without a 0 is shifted to 0-based positions; non-positive cells
+// (other than a real chip 0) become EmptyCell.
+func NewChipLayout(minerType string, tpl [][]int) (*ChipLayout, error) {
+	oneBased := true
+	for _, row := range tpl {
+		for _, v := range row {
+			if v == 0 {
+				oneBased = false
+			}
+		}
+	}
+
+	grid := make([][]int, len(tpl))
+	cols := 0
+	for r, row := range tpl {
+		grid[r] = make([]int, len(row))
+		for c, v := range row {
+			switch {
+			case oneBased && v > 0:
+				grid[r][c] = v - 1
+			case !oneBased && v >= 0:
+				grid[r][c] = v
+			default:
+				grid[r][c] = EmptyCell
+			}
+		}
+		if len(row) > cols {
+			cols = len(row)
+		}
+	}
+
+	data, err := json.Marshal(grid)
+	if err != nil {
+		return nil, err
+	}
+	return &ChipLayout{MinerType: minerType, NumRows: len(grid), NumCols: cols, Layout: string(data)}, nil
+}
+
+// Grid returns the layout as rows of chip positions
+func (l *ChipLayout) Grid() ([][]int, error) {
+	var grid [][]int
+	if err := json.Unmarshal([]byte(l.Layout), &grid); err != nil {
+		return nil, err
+	}
+	return grid, nil
+}
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+)
+
+// DiscoveredDevice is a host that answered on a miner port during a
+// "discover" LAN sweep, whether or not the pool knows about it.
+type DiscoveredDevice struct {
+	ID           uint   `gorm:"primaryKey"`
+	IP           string `gorm:"type:varchar(64);uniqueIndex"`
+	Driver       string `gorm:"type:varchar(16)"` // Miner driver that recognised the device, empty when none did
+	MinerType    string `gorm:"type:varchar(64)"`
+	MinerVersion string `gorm:"type:varchar(64)"`
+	// AuthFailed devices rejected every configured credential, so they are
+	// miners but their driver and type are unknown
+	AuthFailed bool
+	// WorkerID is the active pool worker mapped to this IP, empty when there is none
+	WorkerID       string `gorm:"type:varchar(64)"`
+	ObserverUserID string `gorm:"type:varchar(64)"`
+
+	FirstSeenAt time.Time
+	LastSeenAt  time.Time `gorm:"index"`
+}
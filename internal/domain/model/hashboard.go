@@ -0,0 +1,51 @@
+package model
+
+import (
+	"time"
+)
+
+// Hashboard is the inventory entry of a chain serial number: where it was last seen
+type Hashboard struct {
+	ID         uint   `gorm:"primaryKey"`
+	SN         string `gorm:"type:varchar(64);uniqueIndex"`
+	WorkerID   string `gorm:"type:varchar(64);index"`
+	IP         string `gorm:"type:varchar(64)"`
+	MinerType  string `gorm:"type:varchar(64)"`
+	ChainIndex int    // Slot in the miner
+
+	FirstSeenAt time.Time
+	LastSeenAt  time.Time
+}
+
+// HashboardMove records a serial number showing up in another miner or slot
+type HashboardMove struct {
+	ID             uint   `gorm:"primaryKey"`
+	SN             string `gorm:"type:varchar(64);index"`
+	FromWorkerID   string `gorm:"type:varchar(64)"`
+	FromIP         string `gorm:"type:varchar(64)"`
+	FromChainIndex int
+	ToWorkerID     string `gorm:"type:varchar(64)"`
+	ToIP           string `gorm:"type:varchar(64)"`
+	ToChainIndex   int
+
+	DetectedAt time.Time `gorm:"index"`
+}
+
+// ChainLocation is a chain of a miner's latest snapshot, as returned by chain queries
+type ChainLocation struct {
+	WorkerID   string
+	IP         string
+	MinerType  string
+	ChainIndex int
+	SN         string
+	ScannedAt  time.Time
+}
+
+// ChainAsicHealth is a chain with failed chips, as returned by the asic health query
+type ChainAsicHealth struct {
+	ChainLocation
+	AsicNum             int
+	AsicOK              int
+	AsicFailed          int
+	AsicFailedPositions string
+}
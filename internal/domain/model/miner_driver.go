@@ -0,0 +1,20 @@
+package model
+
+// Miner driver names, stored on workers.miner_driver once detected
+const (
+	DriverAntminer   = "antminer"   // Stock Antminer firmware, HTTP CGI with digest auth
+	DriverCGMiner    = "cgminer"    // Any cgminer/bmminer fork on the port 4028 TCP API
+	DriverWhatsminer = "whatsminer" // MicroBT btminer API
+	DriverAvalon     = "avalon"     // Canaan AvalonMiner
+	DriverBraiins    = "braiins"    // Braiins OS / BOSminer
+	DriverVNish      = "vnish"      // VNish aftermarket Antminer firmware
+)
+
+// DeviceInfo identifies a miner independently of its current stats
+type DeviceInfo struct {
+	Driver   string
+	Model    string
+	Firmware string
+	MAC      string
+	Hostname string
+}
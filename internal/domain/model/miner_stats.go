@@ -1,16 +1,27 @@
 package model
 
 import (
+	"encoding/json"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type MinerStats struct {
-	ID           uint   `gorm:"primaryKey;index:idx_worker_latest,priority:2,sort:desc"`
-	WorkerID     string `gorm:"type:varchar(64);index:idx_worker_latest,priority:1"` // Logically linked to Worker
-	IP           string `gorm:"type:varchar(64)"`
-	MinerType    string `gorm:"type:varchar(64)"`
-	MinerVersion string `gorm:"type:varchar(64)"`
-	CompileTime  string `gorm:"type:varchar(64)"`
+	ID       uint   `gorm:"primaryKey;index:idx_account_worker_latest,priority:3,sort:desc"`
+	WorkerID string `gorm:"type:varchar(64);index:idx_account_worker_latest,priority:2"` // Logically linked to Worker
+	// ObserverUserID is the account of the worker; worker IDs are only unique within an account
+	ObserverUserID string `gorm:"type:varchar(64);index:idx_account_worker_latest,priority:1"`
+	IP             string `gorm:"type:varchar(64);index"`
+	MinerType      string `gorm:"type:varchar(64)"`
+	MinerVersion   string `gorm:"type:varchar(64)"`
+	CompileTime    string `gorm:"type:varchar(64)"`
+	Source         string `gorm:"type:varchar(16)"` // Name of the MinerDriver that read the snapshot
+	// StatsEndpoint is the endpoint that answered, for drivers that have several
+	StatsEndpoint string `gorm:"type:varchar(64)"`
+	// PoolURL and PoolUser are the pool the miner mines on, empty when the driver does not report them
+	PoolURL  string `gorm:"type:varchar(255)"`
+	PoolUser string `gorm:"type:varchar(128)"`
 
 	Elapsed   int64
 	Rate5s    float64
@@ -21,8 +32,29 @@ type MinerStats struct {
 	FanNum    int
 	HwpTotal  float64
 
+	// FanSpeeds lists the RPM of each fan in firmware order, comma separated;
+	// FanAlert is set by CheckFans
+	FanSpeeds string `gorm:"type:varchar(128)"`
+	FanMinRPM int
+	FanAlert  string `gorm:"type:varchar(16);index"`
+
+	// MinerMode and FreqLevel are the "miner-mode" and "freq-level" of the
+	// firmware: a non-zero mode or a level below 100 means the miner was
+	// deliberately set to sleep or underclocked. FreqLevel is 0 when unknown.
+	MinerMode int
+	FreqLevel int
+
 	Chains []MinerChain `gorm:"foreignKey:MinerStatsID"`
 
+	// RawSHA256 is the archive key of the raw responses the snapshot was
+	// parsed from, empty when the archive is disabled; Raw holds them until saved
+	RawSHA256 string `gorm:"type:varchar(64);index"`
+	Raw       []byte `gorm:"-"`
+
+	// ChipLayout is the tpl matrix reported with this snapshot, if any; it is
+	// stored once per miner type in chip_layouts, not with every snapshot
+	ChipLayout [][]int `gorm:"-"`
+
 	CreatedAt time.Time
 }
 
@@ -36,12 +68,178 @@ type MinerChain struct {
 	AsicNum      int
 	Hw           int
 	Hwp          float64
-	// We simplify temp arrays to store avg or just raw string if needed,
-	// for now let's skip complex arrays or just store basic info as per requirement "解析到二层就就可以"
+	SN           string `gorm:"type:varchar(64);index"`
+	// EepromLoaded is nil when the firmware does not report it
+	EepromLoaded *bool
+
+	// Parsed from the "asic" string, e.g. " oooooooo oooxoooo": "o" is a working
+	// chip, "x" or "-" a failed one. Positions are 0-based, comma separated.
+	AsicStatus          string `gorm:"type:varchar(512)"`
+	AsicOK              int
+	AsicFailed          int    `gorm:"index"`
+	AsicFailedPositions string `gorm:"type:varchar(512)"`
+
+	// Summaries of the temp_pic, temp_pcb and temp_chip arrays; the readings
+	// themselves are kept in Temps. Zero readings (absent sensors) are ignored.
+	TempPicMin  float64
+	TempPicMax  float64
+	TempPicAvg  float64
+	TempPcbMin  float64
+	TempPcbMax  float64
+	TempPcbAvg  float64
+	TempChipMin float64
+	TempChipMax float64 `gorm:"index"`
+	TempChipAvg float64
+
+	Temps []MinerChainTemp `gorm:"foreignKey:MinerChainID"`
 
 	CreatedAt time.Time
 }
 
+// Temperature sensor kinds of a chain
+const (
+	TempSensorPic  = "pic"
+	TempSensorPcb  = "pcb"
+	TempSensorChip = "chip"
+)
+
+// MinerChainTemp is one raw sensor reading of a chain in a snapshot
+type MinerChainTemp struct {
+	ID           uint   `gorm:"primaryKey"`
+	MinerChainID uint   `gorm:"index"`
+	Sensor       string `gorm:"type:varchar(8)"`
+	Position     int    // Index in the sensor array
+	Value        float64
+}
+
+// SetTemperatures stores the readings of each sensor kind and their min/max/avg
+func (c *MinerChain) SetTemperatures(pic, pcb, chip []float64) {
+	c.TempPicMin, c.TempPicMax, c.TempPicAvg = tempSummary(pic)
+	c.TempPcbMin, c.TempPcbMax, c.TempPcbAvg = tempSummary(pcb)
+	c.TempChipMin, c.TempChipMax, c.TempChipAvg = tempSummary(chip)
+
+	c.Temps = nil
+	for _, sensor := range []struct {
+		name   string
+		values []float64
+	}{
+		{TempSensorPic, pic},
+		{TempSensorPcb, pcb},
+		{TempSensorChip, chip},
+	} {
+		for i, v := range sensor.values {
+			c.Temps = append(c.Temps, MinerChainTemp{Sensor: sensor.name, Position: i, Value: v})
+		}
+	}
+}
+
+// SetAsicStatus stores the raw asic string and the chip counts parsed from it
+func (c *MinerChain) SetAsicStatus(status string) {
+	c.AsicStatus = status
+	c.AsicOK, c.AsicFailed = 0, 0
+
+	var failed []string
+	pos := 0
+	for _, r := range status {
+		switch r {
+		case ' ':
+			continue
+		case 'o':
+			c.AsicOK++
+		case 'x', '-':
+			c.AsicFailed++
+			failed = append(failed, strconv.Itoa(pos))
+		}
+		pos++
+	}
+	c.AsicFailedPositions = strings.Join(failed, ",")
+}
+
+// Fan alerts of a snapshot
+const (
+	FanAlertMissing = "fan_missing"
+	FanAlertLow     = "fan_low"
+)
+
+// SetFanSpeeds stores the fan RPM readings and the slowest of them
+func (s *MinerStats) SetFanSpeeds(rpms []float64) {
+	speeds := make([]string, len(rpms))
+	s.FanMinRPM = 0
+	for i, rpm := range rpms {
+		speeds[i] = strconv.Itoa(int(rpm))
+		if i == 0 || int(rpm) < s.FanMinRPM {
+			s.FanMinRPM = int(rpm)
+		}
+	}
+	s.FanSpeeds = strings.Join(speeds, ",")
+}
+
+// CheckFans sets FanAlert: fan_missing when a fan reads 0 RPM or fan_num is
+// above the number of spinning fans, fan_low when a fan is below minRPM.
+// Miners that report neither spinning fans nor fan_num (hydro, immersion)
+// get no alert.
+func (s *MinerStats) CheckFans(minRPM int) {
+	s.FanAlert = ""
+	running, stopped, slowest := 0, 0, 0
+	for _, v := range strings.Split(s.FanSpeeds, ",") {
+		rpm, err := strconv.Atoi(v)
+		if err != nil {
+			continue
+		}
+		if rpm <= 0 {
+			stopped++
+			continue
+		}
+		if running == 0 || rpm < slowest {
+			slowest = rpm
+		}
+		running++
+	}
+	if running == 0 && s.FanNum == 0 {
+		return
+	}
+	switch {
+	case stopped > 0 || s.FanNum > running:
+		s.FanAlert = FanAlertMissing
+	case minRPM > 0 && slowest < minRPM:
+		s.FanAlert = FanAlertLow
+	}
+}
+
+func tempSummary(values []float64) (min, max, avg float64) {
+	n := 0
+	for _, v := range values {
+		if v <= 0 {
+			continue
+		}
+		if n == 0 || v < min {
+			min = v
+		}
+		if v > max {
+			max = v
+		}
+		avg += v
+		n++
+	}
+	if n > 0 {
+		avg /= float64(n)
+	}
+	return min, max, avg
+}
+
+// ChainTemperature is a chain of the latest snapshot of a miner, as returned by the hottest-chains query
+type ChainTemperature struct {
+	WorkerID    string
+	IP          string
+	MinerType   string
+	ChainIndex  int
+	TempChipMax float64
+	TempChipAvg float64
+	TempPcbMax  float64
+	TempPicMax  float64
+	ScannedAt   time.Time
+}
+
 // MinerAPIResponse structures for JSON unmarshalling
 type MinerAPIResponse struct {
 	Status map[string]interface{} `json:"STATUS"`
@@ -64,16 +262,27 @@ type MinerStatItem struct {
 	RateUnit  string           `json:"rate_unit"`
 	ChainNum  int              `json:"chain_num"`
 	FanNum    int              `json:"fan_num"`
+	Fan       []float64        `json:"fan"`
+	MinerMode float64          `json:"miner-mode"`
+	FreqLevel float64          `json:"freq-level"`
 	HwpTotal  float64          `json:"hwp_total"`
 	Chain     []MinerChainItem `json:"chain"`
+	// Tpl is kept raw: its shape is undocumented and must not break parsing of the rest
+	Tpl json.RawMessage `json:"tpl"`
 }
 
 type MinerChainItem struct {
-	Index     int     `json:"index"`
-	FreqAvg   int     `json:"freq_avg"`
-	RateIdeal float64 `json:"rate_ideal"`
-	RateReal  float64 `json:"rate_real"`
-	AsicNum   int     `json:"asic_num"`
-	Hw        int     `json:"hw"`
-	Hwp       float64 `json:"hwp"`
+	Index        int       `json:"index"`
+	FreqAvg      int       `json:"freq_avg"`
+	RateIdeal    float64   `json:"rate_ideal"`
+	RateReal     float64   `json:"rate_real"`
+	AsicNum      int       `json:"asic_num"`
+	Hw           int       `json:"hw"`
+	Hwp          float64   `json:"hwp"`
+	TempPic      []float64 `json:"temp_pic"`
+	TempPcb      []float64 `json:"temp_pcb"`
+	TempChip     []float64 `json:"temp_chip"`
+	SN           string    `json:"sn"`
+	EepromLoaded *bool     `json:"eeprom_loaded"`
+	Asic         string    `json:"asic"`
 }
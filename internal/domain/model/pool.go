@@ -0,0 +1,75 @@
+package model
+
+import (
+	"time"
+)
+
+// PoolAccount identifies one observer account on a mining pool
+type PoolAccount struct {
+	AccessKey      string
+	ObserverUserID string
+	CoinType       string
+
+	// Credentials for pools that sign API requests instead of using a session
+	APIKey    string
+	APISecret string
+	APIUserID string
+}
+
+// Hashrate is a pool-reported hashrate split into value and unit, e.g. 306.23 "TH/s"
+type Hashrate struct {
+	Value float64
+	Unit  string
+}
+
+// PoolWorkerOnline is the WorkerStatus of a worker that is submitting shares
+const PoolWorkerOnline = 1
+
+// PoolWorker is a worker as reported by a pool, independent of the pool's wire format
+type PoolWorker struct {
+	PoolID            int64
+	WorkerID          string
+	UserWorkerID      string
+	WorkerStatus      int
+	HsLast10Min       Hashrate
+	HsLast1H          Hashrate
+	HsLast1Hour       Hashrate
+	HsLast1D          Hashrate
+	RejectRatio       string
+	OnlineTimeLast24h float64
+	ReconnectLast24h  int
+	BurstBlock72h     bool
+	CreateTime        time.Time
+	UpdateTime        *time.Time // nil when the pool does not report it
+	ShareLastTime     *time.Time
+
+	GroupID   int64
+	GroupName string
+
+	// Pool-side health indicators, empty unless the pool flags the worker
+	FanCode          string
+	FanValue         string
+	HashCode         string
+	HashValue        string
+	NetworkCode      string
+	NetworkValue     string
+	TemperatureCode  string
+	TemperatureValue string
+}
+
+// PoolWorkerStatus is the pool's own fleet summary
+type PoolWorkerStatus struct {
+	Total   int
+	Online  int
+	Offline int
+	Disable int
+}
+
+// PoolWorkerPage is one page of a pool's worker list
+type PoolWorkerPage struct {
+	PageNum     int
+	TotalPage   int
+	TotalRecord int
+	Status      *PoolWorkerStatus // nil when the pool does not report a summary
+	Workers     []PoolWorker
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"time"
+)
+
+// Scan run statuses; a run whose failure rate is above app.scan_max_failure_rate is failed
+const (
+	ScanRunRunning = "running"
+	ScanRunSuccess = "success"
+	ScanRunFailed  = "failed"
+)
+
+// ScanRun records one scan-miners pass over the active workers
+type ScanRun struct {
+	ID        uint   `gorm:"primaryKey"`
+	Status    string `gorm:"type:varchar(16)"`
+	Error     string `gorm:"type:text"`
+	Total     int    // Active workers
+	Skipped   int    // Workers without an IP
+	Succeeded int
+	Failed    int
+	// FailureRate is Failed over the miners that were scanned
+	FailureRate float64
+	// Per-miner latency of the successful scans
+	LatencyP50Ms int64
+	LatencyP95Ms int64
+	// FinalConcurrency is where the adaptive scan concurrency ended
+	FinalConcurrency int
+
+	StartedAt  time.Time `gorm:"index"`
+	FinishedAt *time.Time
+	DurationMs int64
+}
+
+// Reasons a miner scan failed, in the order they are checked
+const (
+	ScanReasonOK           = "ok"
+	ScanReasonDBError      = "db_error"
+	ScanReasonTimeout      = "timeout"
+	ScanReasonRefused      = "connection_refused"
+	ScanReasonUnreachable  = "unreachable"
+	ScanReasonUnauthorized = "unauthorized"
+	ScanReasonBadStatus    = "bad_status"
+	ScanReasonBadJSON      = "bad_json"
+	ScanReasonBadLayout    = "bad_chip_layout"
+	ScanReasonEmptyStats   = "empty_stats"
+	ScanReasonNoDriver     = "no_driver"
+	ScanReasonCanceled     = "canceled"
+	ScanReasonOther        = "other"
+)
+
+// ScanOutcome is the result of scanning one miner in a scan run
+type ScanOutcome struct {
+	ID           uint   `gorm:"primaryKey"`
+	ScanRunID    uint   `gorm:"index"`
+	WorkerID     string `gorm:"type:varchar(64);index"`
+	IP           string `gorm:"type:varchar(64)"`
+	Driver       string `gorm:"type:varchar(16)"`
+	Reason       string `gorm:"type:varchar(32);index"`
+	Error        string `gorm:"type:text"`
+	MinerStatsID uint   // Snapshot saved by the scan, 0 when it failed
+	UsersTried   string `gorm:"type:varchar(255)"` // Users of the credentials an unauthorized scan tried
+	DurationMs   int64
+	CreatedAt    time.Time
+}
@@ -6,9 +6,13 @@ import (
 
 type Worker struct {
 	ID                uint      `gorm:"primaryKey" json:"-"`
-	WorkerID          string    `gorm:"uniqueIndex;type:varchar(64)" json:"workerId"`
+	PoolID            int64     `gorm:"index" json:"id"` // Antpool's own id of the worker
+	WorkerID          string    `gorm:"uniqueIndex:idx_worker_account,priority:1;type:varchar(64)" json:"workerId"`
 	IP                string    `gorm:"type:varchar(64)" json:"ip"`
 	UserWorkerID      string    `gorm:"type:varchar(128)" json:"userWorkerId"`
+	// The Antpool observer account and coin this worker was fetched from
+	ObserverUserID    string    `gorm:"uniqueIndex:idx_worker_account,priority:2;type:varchar(64)" json:"observerUserId"`
+	CoinType          string    `gorm:"uniqueIndex:idx_worker_account,priority:3;type:varchar(16)" json:"coinType"`
 	WorkerStatus      int       `json:"workerStatus"`
 	
 	HsLast10Min       float64   `json:"hsLast10Min"`
@@ -17,26 +21,48 @@ type Worker struct {
 	HsLast1H          float64   `json:"hsLast1H"`
 	HsLast1HUnit      string    `gorm:"type:varchar(16)" json:"hsLast1HUnit"`
 	
+	// Antpool reports hsLast1Hour separately from hsLast1H and the two can differ
+	HsLast1Hour       float64   `json:"hsLast1Hour"`
+	HsLast1HourUnit   string    `gorm:"type:varchar(16)" json:"hsLast1HourUnit"`
+	
 	HsLast1D          float64   `json:"hsLast1D"`
 	HsLast1DUnit      string    `gorm:"type:varchar(16)" json:"hsLast1DUnit"`
 	
 	RejectRatio       string    `gorm:"type:varchar(16)" json:"rejectRatio"`
 	OnlineTimeLast24h float64   `json:"onlineTimeLast24h"`
+	ReconnectLast24h  int       `json:"reconnectLast24h"`
+	BurstBlock72h     bool      `json:"burstBlock72h"`
+	
+	ShareLastTime     *time.Time `json:"shareLastTime"`
+	PoolUpdateTime    *time.Time `json:"updateTime"` // Antpool's updateTime, not the row's UpdatedAt
+	
+	GroupID           int64     `json:"groupId"`
+	GroupName         string    `gorm:"type:varchar(128)" json:"groupName"`
+	
+	// Pool-side health codes, null unless Antpool flags the worker
+	FanCode           string    `gorm:"type:varchar(64)" json:"fanCode"`
+	FanValue          string    `gorm:"type:varchar(64)" json:"fanValue"`
+	HashCode          string    `gorm:"type:varchar(64)" json:"hashCode"`
+	HashValue         string    `gorm:"type:varchar(64)" json:"hashValue"`
+	NetworkCode       string    `gorm:"type:varchar(64)" json:"networkCode"`
+	NetworkValue      string    `gorm:"type:varchar(64)" json:"networkValue"`
+	TemperatureCode   string    `gorm:"type:varchar(64)" json:"temperatureCode"`
+	TemperatureValue  string    `gorm:"type:varchar(64)" json:"temperatureValue"`
+	
+	// Miner-side detection results, owned by scan-miners and never overwritten by a pool sync
+	MinerDriver       string    `gorm:"type:varchar(32)" json:"minerDriver"`
+	MinerModel        string    `gorm:"type:varchar(64)" json:"minerModel"`
+	// StatsEndpoint is the stats endpoint the miner last answered on, tried first next time
+	StatsEndpoint     string     `gorm:"type:varchar(64)" json:"statsEndpoint"`
+	// DriverCheckedAt is when the driver was last detected; detection is redone after app.miner_reprobe_interval
+	DriverCheckedAt   *time.Time `json:"driverCheckedAt"`
+	// MinerCredential fingerprints the credential the miner last accepted (the password is not stored), tried first next time
+	MinerCredential   string     `gorm:"type:varchar(16)" json:"minerCredential"`
+	
+	// Active is cleared when a complete sync no longer lists the worker; scan-miners skips inactive workers
+	Active            bool       `gorm:"default:true;index" json:"active"`
+	LastSeenAt        *time.Time `json:"lastSeenAt"`
 	
 	CreatedAt         time.Time `json:"createTime"` // Antpool returns timestamp, we might need custom unmarshaler or handle logic
 	UpdatedAt         time.Time
 }
-
-// AntpoolWorkerResponseItem is used for JSON unmarshalling from Antpool API
-type AntpoolWorkerResponseItem struct {
-	ID                int64   `json:"id"`
-	WorkerID          string  `json:"workerId"`
-	UserWorkerID      string  `json:"userWorkerId"`
-	WorkerStatus      int     `json:"workerStatus"`
-	HsLast10Min       string  `json:"hsLast10Min"`
-	HsLast1H          string  `json:"hsLast1H"` // Note: API has hsLast1Hour and hsLast1H
-	HsLast1D          string  `json:"hsLast1D"`
-	RejectRatio       string  `json:"rejectRatio"`
-	OnlineTimeLast24h float64 `json:"onlineTimeLast24h"`
-	CreateTime        int64   `json:"createTime"`
-}
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"time"
+)
+
+// WorkerHashrateSnapshot is an append-only copy of the pool-side figures of a
+// worker as seen by one sync run. The workers table only keeps the latest values.
+type WorkerHashrateSnapshot struct {
+	ID             uint   `gorm:"primaryKey"`
+	SyncRunID      uint   `gorm:"uniqueIndex:idx_snapshot_run_worker,priority:1"`
+	WorkerID       string `gorm:"type:varchar(64);uniqueIndex:idx_snapshot_run_worker,priority:2;index:idx_snapshot_account_worker_time,priority:3"`
+	ObserverUserID string `gorm:"type:varchar(64);index:idx_snapshot_account_worker_time,priority:1"`
+	CoinType       string `gorm:"type:varchar(16);index:idx_snapshot_account_worker_time,priority:2"`
+	WorkerStatus   int
+
+	HsLast10Min     float64
+	HsLast10MinUnit string `gorm:"type:varchar(16)"`
+	HsLast1H        float64
+	HsLast1HUnit    string `gorm:"type:varchar(16)"`
+	HsLast1Hour     float64
+	HsLast1HourUnit string `gorm:"type:varchar(16)"`
+	HsLast1D        float64
+	HsLast1DUnit    string `gorm:"type:varchar(16)"`
+
+	RejectRatio       string `gorm:"type:varchar(16)"`
+	OnlineTimeLast24h float64
+	ReconnectLast24h  int
+	ShareLastTime     *time.Time
+
+	CreatedAt time.Time `gorm:"index:idx_snapshot_account_worker_time,priority:4"`
+}
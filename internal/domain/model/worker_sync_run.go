@@ -0,0 +1,43 @@
+package model
+
+import (
+	"time"
+)
+
+// Sync run statuses
+const (
+	SyncRunRunning = "running"
+	SyncRunSuccess = "success"
+	SyncRunFailed  = "failed"
+)
+
+// WorkerSyncRun records one fetch-workers pass over a pool account
+type WorkerSyncRun struct {
+	ID             uint   `gorm:"primaryKey"`
+	ObserverUserID string `gorm:"type:varchar(64);index:idx_sync_account_started,priority:1"`
+	CoinType       string `gorm:"type:varchar(16);index:idx_sync_account_started,priority:2"`
+	Status         string `gorm:"type:varchar(16)"`
+	Error          string `gorm:"type:text"`
+
+	// Pool-side summary from data.workerStatus, taken from the first page fetched
+	TotalWorkerNum   int
+	OnlineWorkerNum  int
+	OfflineWorkerNum int
+	DisableWorkerNum int
+
+	StartPage     int
+	PagesFetched  int
+	TotalRecord   int // totalRecord as reported by the pool
+	RecordsStored int
+	// CountMismatch is set when a full run stored a different number of workers than totalRecord
+	CountMismatch bool
+
+	// Changes against the previous complete sync, zero for resumed runs
+	NewWorkers     int
+	RemovedWorkers int
+	RenamedWorkers int
+
+	StartedAt  time.Time `gorm:"index:idx_sync_account_started,priority:3"`
+	FinishedAt *time.Time
+	DurationMs int64
+}
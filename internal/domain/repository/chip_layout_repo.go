@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/beatyman/scan-miners/internal/domain/model"
+)
+
+type ChipLayoutRepository interface {
+	// Save inserts the layout or replaces the one stored for the same miner type
+	Save(ctx context.Context, layout *model.ChipLayout) error
+	// FindByMinerType returns nil when no layout is known for the miner type
+	FindByMinerType(ctx context.Context, minerType string) (*model.ChipLayout, error)
+}
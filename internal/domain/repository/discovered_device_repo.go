@@ -0,0 +1,12 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/beatyman/scan-miners/internal/domain/model"
+)
+
+type DiscoveredDeviceRepository interface {
+	// Save inserts the device or refreshes the row with the same IP, keeping FirstSeenAt
+	Save(ctx context.Context, device *model.DiscoveredDevice) error
+}
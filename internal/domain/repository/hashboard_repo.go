@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/beatyman/scan-miners/internal/domain/model"
+)
+
+type HashboardRepository interface {
+	// FindBySN returns nil when the serial number has not been seen before
+	FindBySN(ctx context.Context, sn string) (*model.Hashboard, error)
+	Save(ctx context.Context, board *model.Hashboard) error
+	CreateMove(ctx context.Context, move *model.HashboardMove) error
+	FindMovesSince(ctx context.Context, since time.Time) ([]*model.HashboardMove, error)
+}
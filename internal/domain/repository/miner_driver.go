@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/beatyman/scan-miners/internal/domain/model"
+)
+
+// MinerDriver reads one vendor's or firmware's local API
+type MinerDriver interface {
+	// Name is persisted on the worker, so it must stay stable (see model.Driver*)
+	Name() string
+	// Detect reports whether the miner at ip runs this firmware. An error means
+	// the probe could not be completed, not that the firmware differs.
+	Detect(ctx context.Context, ip string) (bool, error)
+	FetchInfo(ctx context.Context, ip string) (*model.DeviceInfo, error)
+	FetchStats(ctx context.Context, ip string) (*model.MinerStats, error)
+}
+
+// Errors a MinerDriver wraps so callers can classify a failed read without
+// knowing the miner's protocol. Test with errors.Is; network failures are
+// reported as the net package's errors.
+var (
+	ErrMinerUnauthorized = errors.New("miner rejected the credentials")
+	ErrMinerBadStatus    = errors.New("miner answered with an error status")
+	ErrMinerNoStats      = errors.New("miner returned no stats")
+)
+
+type statsEndpointKey struct{}
+
+// WithStatsEndpoint asks a driver that knows several stats endpoints to try
+// endpoint first; drivers report the one that answered in MinerStats.StatsEndpoint
+func WithStatsEndpoint(ctx context.Context, endpoint string) context.Context {
+	if endpoint == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, statsEndpointKey{}, endpoint)
+}
+
+// StatsEndpointFrom returns the endpoint set by WithStatsEndpoint, or ""
+func StatsEndpointFrom(ctx context.Context) string {
+	endpoint, _ := ctx.Value(statsEndpointKey{}).(string)
+	return endpoint
+}
+
+// MinerCredential is a user and password for a miner's authenticated API
+type MinerCredential struct {
+	User     string
+	Password string
+}
+
+// MinerAuth is the ordered list of credentials a driver tries when the miner
+// rejects one. The driver records the credential the miner accepted in
+// Accepted and moves it to the front, so later requests start with it.
+type MinerAuth struct {
+	Credentials []MinerCredential
+	Accepted    *MinerCredential
+}
+
+// Accept records that the miner took Credentials[i]
+func (a *MinerAuth) Accept(i int) {
+	cred := a.Credentials[i]
+	copy(a.Credentials[1:i+1], a.Credentials[:i])
+	a.Credentials[0] = cred
+	a.Accepted = &cred
+}
+
+type minerAuthKey struct{}
+
+// WithMinerAuth makes drivers authenticate with auth instead of their default credential
+func WithMinerAuth(ctx context.Context, auth *MinerAuth) context.Context {
+	return context.WithValue(ctx, minerAuthKey{}, auth)
+}
+
+// MinerAuthFrom returns the auth set by WithMinerAuth, or nil
+func MinerAuthFrom(ctx context.Context) *MinerAuth {
+	auth, _ := ctx.Value(minerAuthKey{}).(*MinerAuth)
+	return auth
+}
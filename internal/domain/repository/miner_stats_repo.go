@@ -2,11 +2,28 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/beatyman/scan-miners/internal/domain/model"
 )
 
 type MinerStatsRepository interface {
 	Save(ctx context.Context, stats *model.MinerStats) error
-	FindLatestByWorkerID(ctx context.Context, workerID string) (*model.MinerStats, error)
+	// Replace overwrites a stored snapshot, keeping its ID, and swaps its chains for stats.Chains
+	Replace(ctx context.Context, stats *model.MinerStats) error
+	// FindLatestByWorkerID returns the latest snapshot of the account's worker, nil when there is none
+	FindLatestByWorkerID(ctx context.Context, observerUserID, workerID string) (*model.MinerStats, error)
+	// FindLatestByIP returns the latest snapshot of the miner at ip with its chains and their readings, nil when there is none
+	FindLatestByIP(ctx context.Context, ip string) (*model.MinerStats, error)
+	// FindHottestChains ranks the chains of each miner's latest snapshot taken after since by max chip temperature
+	FindHottestChains(ctx context.Context, since time.Time, limit int) ([]*model.ChainTemperature, error)
+	// FindEEPROMNotLoaded returns the chains of each miner's latest snapshot after since that report eeprom_loaded false
+	FindEEPROMNotLoaded(ctx context.Context, since time.Time) ([]*model.ChainLocation, error)
+	// FindFailedAsics returns the chains of each miner's latest snapshot after since that have failed chips
+	FindFailedAsics(ctx context.Context, since time.Time) ([]*model.ChainAsicHealth, error)
+	// FindArchived pages through snapshots after since that have archived raw
+	// responses, by ascending ID after afterID; ip is optional
+	FindArchived(ctx context.Context, since time.Time, ip string, afterID uint, limit int) ([]*model.MinerStats, error)
+	// FindFanAlerts returns each miner's latest snapshot after since when it has a fan alert
+	FindFanAlerts(ctx context.Context, since time.Time) ([]*model.MinerStats, error)
 }
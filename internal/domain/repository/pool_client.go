@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/beatyman/scan-miners/internal/domain/model"
+)
+
+type PoolClient interface {
+	// ListWorkers walks the account's worker list from startPage (1-based) to the
+	// last page and hands each page to fn as soon as it is fetched. An error from
+	// fn stops the walk.
+	ListWorkers(ctx context.Context, account model.PoolAccount, startPage int, fn func(page *model.PoolWorkerPage) error) error
+}
+
+// Errors a PoolClient wraps so callers can react to the failure class without
+// knowing the pool's wire format. Test with errors.Is.
+var (
+	ErrPoolAuthExpired   = errors.New("pool session or credentials expired")
+	ErrPoolRateLimited   = errors.New("pool rate limit exceeded")
+	ErrPoolMaintenance   = errors.New("pool under maintenance")
+	ErrPoolSchemaChanged = errors.New("pool response schema changed")
+)
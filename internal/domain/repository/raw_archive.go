@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrRawNotFound is returned by RawArchive.Get for an unknown key
+var ErrRawNotFound = errors.New("raw response not found in archive")
+
+// RawArchive keeps raw miner responses by content address
+type RawArchive interface {
+	// Put stores data and returns its key, the hex SHA-256 of data; storing
+	// the same data twice keeps one copy
+	Put(ctx context.Context, data []byte) (string, error)
+	Get(ctx context.Context, key string) ([]byte, error)
+}
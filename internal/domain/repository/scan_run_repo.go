@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/beatyman/scan-miners/internal/domain/model"
+)
+
+type ScanRunRepository interface {
+	Create(ctx context.Context, run *model.ScanRun) error
+	Update(ctx context.Context, run *model.ScanRun) error
+	SaveOutcomes(ctx context.Context, outcomes []*model.ScanOutcome) error
+	// FindLatestFinished returns the most recent run that has finished, or nil
+	FindLatestFinished(ctx context.Context) (*model.ScanRun, error)
+	FindOutcomes(ctx context.Context, scanRunID uint, reason string) ([]*model.ScanOutcome, error)
+}
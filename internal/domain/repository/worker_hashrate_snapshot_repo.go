@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/beatyman/scan-miners/internal/domain/model"
+)
+
+type WorkerHashrateSnapshotRepository interface {
+	SaveBatch(ctx context.Context, snapshots []*model.WorkerHashrateSnapshot) error
+	// FindByWorkerID returns the snapshots of the account's worker taken in [from, to), oldest first
+	FindByWorkerID(ctx context.Context, observerUserID, coinType, workerID string, from, to time.Time) ([]*model.WorkerHashrateSnapshot, error)
+}
@@ -9,5 +9,13 @@ type WorkerRepository interface {
 	Save(ctx context.Context, worker *model.Worker) error
 	SaveBatch(ctx context.Context, workers []*model.Worker) error
 	FindAll(ctx context.Context) ([]*model.Worker, error)
-	FindByWorkerID(ctx context.Context, workerID string) (*model.Worker, error)
+	FindActive(ctx context.Context) ([]*model.Worker, error)
+	FindByAccount(ctx context.Context, observerUserID, coinType string) ([]*model.Worker, error)
+	MarkInactive(ctx context.Context, ids []uint) error
+	UpdateIP(ctx context.Context, id uint, ip string) error
+	// UpdateMinerDriver stores a detection result, stamps driver_checked_at and
+	// forgets the stats endpoint, which may differ under the new firmware
+	UpdateMinerDriver(ctx context.Context, id uint, driver, minerModel string) error
+	UpdateStatsEndpoint(ctx context.Context, id uint, endpoint string) error
+	UpdateMinerCredential(ctx context.Context, id uint, fingerprint string) error
 }
@@ -0,0 +1,12 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/beatyman/scan-miners/internal/domain/model"
+)
+
+type WorkerSyncRunRepository interface {
+	Create(ctx context.Context, run *model.WorkerSyncRun) error
+	Update(ctx context.Context, run *model.WorkerSyncRun) error
+}
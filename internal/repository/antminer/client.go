@@ -0,0 +1,271 @@
+// Package antminer talks to the CGI endpoints of stock Antminer firmware,
+// authenticated with HTTP digest auth.
+package antminer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/beatyman/scan-miners/internal/domain/model"
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+	"github.com/beatyman/scan-miners/pkg/transcript"
+	"github.com/icholy/digest"
+)
+
+// StatsEndpoints are tried in order until one answers
+var StatsEndpoints = []string{
+	"/cgi-bin/get_stats.cgi",
+	"/cgi-bin/stats.cgi",
+}
+
+// ErrUnauthorized is returned when the miner rejects every digest credential
+var ErrUnauthorized = fmt.Errorf("digest authentication failed: %w", repository.ErrMinerUnauthorized)
+
+// StatusError is a non-200 answer from a miner endpoint
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("bad status: %d", e.StatusCode)
+}
+
+func (e *StatusError) Unwrap() error {
+	return repository.ErrMinerBadStatus
+}
+
+// StatsResult is a parsed stats response together with where it came from
+type StatsResult struct {
+	Endpoint string
+	Body     []byte
+	Response *model.MinerAPIResponse
+}
+
+type Client struct {
+	transport  *http.Transport
+	timeout    time.Duration
+	credential repository.MinerCredential
+
+	// clients holds one digest-authenticating *http.Client per credential, so
+	// each keeps its cached challenges
+	clients sync.Map
+}
+
+// NewClient authenticates as user unless a request carries a
+// repository.MinerAuth. It gives each connection connectTimeout to be
+// established and each request readTimeout to be answered once connected.
+func NewClient(user, password string, connectTimeout, readTimeout time.Duration) *Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: connectTimeout}).DialContext
+	transport.ResponseHeaderTimeout = readTimeout
+
+	return &Client{
+		transport:  transport,
+		timeout:    connectTimeout + readTimeout,
+		credential: repository.MinerCredential{User: user, Password: password},
+	}
+}
+
+func (c *Client) httpClient(cred repository.MinerCredential) *http.Client {
+	if client, ok := c.clients.Load(cred); ok {
+		return client.(*http.Client)
+	}
+	// Setup digest authentication client
+	client, _ := c.clients.LoadOrStore(cred, &http.Client{
+		Transport: &digest.Transport{
+			Username:  cred.User,
+			Password:  cred.Password,
+			Transport: c.transport,
+		},
+		Timeout: c.timeout,
+	})
+	return client.(*http.Client)
+}
+
+// FetchStats tries every stats endpoint in order, the one set with
+// repository.WithStatsEndpoint first, and returns the first parsed answer
+func (c *Client) FetchStats(ctx context.Context, ip string) (*StatsResult, error) {
+	var body []byte
+	var endpoint string
+	var err error
+
+	for _, endpoint = range endpointOrder(repository.StatsEndpointFrom(ctx)) {
+		body, err = c.fetchURL(ctx, fmt.Sprintf("http://%s%s", ip, endpoint))
+		// Every endpoint takes the same credentials
+		if err == nil || errors.Is(err, ErrUnauthorized) {
+			break
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse JSON
+	var resp model.MinerAPIResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+
+	return &StatsResult{Endpoint: endpoint, Body: body, Response: &resp}, nil
+}
+
+// endpointOrder moves preferred to the front of StatsEndpoints; an unknown
+// preference is ignored
+func endpointOrder(preferred string) []string {
+	if !slices.Contains(StatsEndpoints, preferred) {
+		return StatsEndpoints
+	}
+	order := []string{preferred}
+	for _, e := range StatsEndpoints {
+		if e != preferred {
+			order = append(order, e)
+		}
+	}
+	return order
+}
+
+// fetchURL gets url with each credential of the request's repository.MinerAuth
+// in turn until one is accepted
+func (c *Client) fetchURL(ctx context.Context, url string) ([]byte, error) {
+	// Transcripts are keyed by path so a replay does not depend on the IP
+	t := transcript.FromContext(ctx)
+	if t.Replaying() {
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		return t.Get(req.URL.Path)
+	}
+
+	auth := repository.MinerAuthFrom(ctx)
+	if auth == nil || len(auth.Credentials) == 0 {
+		return c.get(ctx, url, c.credential)
+	}
+	for i, cred := range auth.Credentials {
+		body, err := c.get(ctx, url, cred)
+		if errors.Is(err, ErrUnauthorized) {
+			continue
+		}
+		if err == nil {
+			auth.Accept(i)
+		}
+		return body, err
+	}
+	return nil, fmt.Errorf("%w (%d credentials tried)", ErrUnauthorized, len(auth.Credentials))
+}
+
+func (c *Client) get(ctx context.Context, url string, cred repository.MinerCredential) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient(cred).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, ErrUnauthorized
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	transcript.FromContext(ctx).Add(req.URL.Path, body)
+	return body, nil
+}
+
+// ToMinerStats flattens a stats response into the stored snapshot.
+// Only the first STATS item is used, as on every Antminer seen so far.
+func ToMinerStats(resp *model.MinerAPIResponse) (*model.MinerStats, error) {
+	if len(resp.Stats) == 0 {
+		return nil, fmt.Errorf("no stats data found: %w", repository.ErrMinerNoStats)
+	}
+
+	statItem := resp.Stats[0]
+
+	minerStats := &model.MinerStats{
+		Source:       model.DriverAntminer,
+		MinerType:    resp.Info.Type,
+		MinerVersion: resp.Info.MinerVersion,
+		CompileTime:  resp.Info.CompileTime,
+		Elapsed:      statItem.Elapsed,
+		Rate5s:       statItem.Rate5s,
+		Rate30m:      statItem.Rate30m,
+		RateAvg:      statItem.RateAvg,
+		RateIdeal:    statItem.RateIdeal,
+		RateUnit:     statItem.RateUnit,
+		FanNum:       statItem.FanNum,
+		HwpTotal:     statItem.HwpTotal,
+		MinerMode:    int(statItem.MinerMode),
+		FreqLevel:    int(statItem.FreqLevel),
+		ChipLayout:   parseTpl(statItem.Tpl),
+	}
+	minerStats.SetFanSpeeds(statItem.Fan)
+
+	// Map Chains
+	for _, chainItem := range statItem.Chain {
+		chain := model.MinerChain{
+			ChainIndex:   chainItem.Index,
+			FreqAvg:      chainItem.FreqAvg,
+			RateIdeal:    chainItem.RateIdeal,
+			RateReal:     chainItem.RateReal,
+			AsicNum:      chainItem.AsicNum,
+			Hw:           chainItem.Hw,
+			Hwp:          chainItem.Hwp,
+			SN:           chainItem.SN,
+			EepromLoaded: chainItem.EepromLoaded,
+		}
+		chain.SetTemperatures(chainItem.TempPic, chainItem.TempPcb, chainItem.TempChip)
+		chain.SetAsicStatus(chainItem.Asic)
+		minerStats.Chains = append(minerStats.Chains, chain)
+	}
+
+	return minerStats, nil
+}
+
+// parseTpl reads the tpl chip matrix, either one [][]int for all chains or one
+// matrix per chain (all chains of a miner share the layout, the first is used).
+// Anything else yields nil.
+func parseTpl(raw json.RawMessage) [][]int {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	var matrix [][]float64
+	if err := json.Unmarshal(raw, &matrix); err != nil {
+		var perChain [][][]float64
+		if err := json.Unmarshal(raw, &perChain); err != nil || len(perChain) == 0 {
+			return nil
+		}
+		matrix = perChain[0]
+	}
+
+	tpl := make([][]int, 0, len(matrix))
+	for _, row := range matrix {
+		cells := make([]int, len(row))
+		for i, v := range row {
+			cells[i] = int(v)
+		}
+		tpl = append(tpl, cells)
+	}
+	if len(tpl) == 0 {
+		return nil
+	}
+	return tpl
+}
@@ -0,0 +1,80 @@
+package antminer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/beatyman/scan-miners/internal/domain/model"
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+)
+
+const systemInfoEndpoint = "/cgi-bin/get_system_info.cgi"
+
+type systemInfo struct {
+	MinerType         string `json:"minertype"`
+	MACAddr           string `json:"macaddr"`
+	Hostname          string `json:"hostname"`
+	FilesystemVersion string `json:"system_filesystem_version"`
+}
+
+type driver struct {
+	client *Client
+}
+
+// NewDriver reads stock Antminer firmware over its HTTP CGI endpoints
+func NewDriver(client *Client) repository.MinerDriver {
+	return &driver{client: client}
+}
+
+func (d *driver) Name() string {
+	return model.DriverAntminer
+}
+
+func (d *driver) Detect(ctx context.Context, ip string) (bool, error) {
+	info, err := d.systemInfo(ctx, ip)
+	if err != nil {
+		return false, err
+	}
+	return strings.HasPrefix(info.MinerType, "Antminer"), nil
+}
+
+func (d *driver) FetchInfo(ctx context.Context, ip string) (*model.DeviceInfo, error) {
+	info, err := d.systemInfo(ctx, ip)
+	if err != nil {
+		return nil, err
+	}
+	return &model.DeviceInfo{
+		Driver:   model.DriverAntminer,
+		Model:    info.MinerType,
+		Firmware: info.FilesystemVersion,
+		MAC:      info.MACAddr,
+		Hostname: info.Hostname,
+	}, nil
+}
+
+func (d *driver) FetchStats(ctx context.Context, ip string) (*model.MinerStats, error) {
+	result, err := d.client.FetchStats(ctx, ip)
+	if err != nil {
+		return nil, err
+	}
+	minerStats, err := ToMinerStats(result.Response)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ip, err)
+	}
+	minerStats.StatsEndpoint = result.Endpoint
+	return minerStats, nil
+}
+
+func (d *driver) systemInfo(ctx context.Context, ip string) (*systemInfo, error) {
+	body, err := d.client.fetchURL(ctx, fmt.Sprintf("http://%s%s", ip, systemInfoEndpoint))
+	if err != nil {
+		return nil, err
+	}
+	var info systemInfo
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
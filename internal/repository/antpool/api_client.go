@@ -0,0 +1,127 @@
+package antpool
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/beatyman/scan-miners/config"
+	"github.com/beatyman/scan-miners/internal/domain/model"
+)
+
+// apiClient talks to Antpool's official API (https://antpool.com/api/*.htm),
+// signing every request with the account's API key and secret.
+type apiClient struct {
+	baseURL  string
+	pageSize int
+	pager    *pager
+	http     *http.Client
+
+	// Antpool rejects a nonce that is not larger than the previous one for the same key
+	lastNonce atomic.Int64
+}
+
+func newAPIClient(cfg *config.Config, p *pager) *apiClient {
+	return &apiClient{
+		baseURL:  strings.TrimRight(cfg.App.AntpoolAPIURL, "/"),
+		pageSize: cfg.App.PageSize,
+		pager:    p,
+		http: &http.Client{
+			Timeout: cfg.App.RequestTimeout,
+		},
+	}
+}
+
+// Sign returns the request signature: upper-case hex HMAC-SHA256 over
+// userID + apiKey + nonce, keyed with the API secret.
+func Sign(userID, apiKey, apiSecret string, nonce int64) string {
+	mac := hmac.New(sha256.New, []byte(apiSecret))
+	mac.Write([]byte(userID + apiKey + strconv.FormatInt(nonce, 10)))
+	return strings.ToUpper(hex.EncodeToString(mac.Sum(nil)))
+}
+
+func (c *apiClient) nextNonce() int64 {
+	for {
+		last := c.lastNonce.Load()
+		next := time.Now().UnixMilli()
+		if next <= last {
+			next = last + 1
+		}
+		if c.lastNonce.CompareAndSwap(last, next) {
+			return next
+		}
+	}
+}
+
+func (c *apiClient) ListWorkers(ctx context.Context, account model.PoolAccount, startPage int, fn func(page *model.PoolWorkerPage) error) error {
+	return c.pager.walk(ctx, account, startPage, func(ctx context.Context, pageNum int) (*model.PoolWorkerPage, error) {
+		return c.fetchPage(ctx, account, pageNum)
+	}, fn)
+}
+
+func (c *apiClient) fetchPage(ctx context.Context, account model.PoolAccount, pageNum int) (*model.PoolWorkerPage, error) {
+	nonce := c.nextNonce()
+
+	form := url.Values{}
+	form.Set("key", account.APIKey)
+	form.Set("nonce", strconv.FormatInt(nonce, 10))
+	form.Set("signature", Sign(account.APIUserID, account.APIKey, account.APISecret, nonce))
+	form.Set("coin_type", account.CoinType)
+	form.Set("userId", account.APIUserID)
+	form.Set("page", strconv.Itoa(pageNum))
+	form.Set("pageSize", strconv.Itoa(c.pageSize))
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/workers.htm", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := classifyHTTP(resp.StatusCode, body, pageNum); err != nil {
+		return nil, err
+	}
+
+	var result apiWorkersResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, schemaError(resp.StatusCode, pageNum, err.Error())
+	}
+
+	if result.Code != 0 {
+		return nil, classifyCode(resp.StatusCode, strconv.Itoa(result.Code), result.Message, pageNum)
+	}
+	if result.Data == nil {
+		return nil, schemaError(resp.StatusCode, pageNum, `missing "data" object`)
+	}
+
+	// workers.htm has no fleet summary, so Status stays nil and the caller
+	// counts the rows itself
+	page := &model.PoolWorkerPage{
+		PageNum:     pageNum,
+		TotalPage:   result.Data.TotalPage,
+		TotalRecord: result.Data.TotalRecord,
+	}
+	for _, row := range result.Data.Rows {
+		page.Workers = append(page.Workers, row.toPoolWorker(account))
+	}
+	return page, nil
+}
@@ -0,0 +1,236 @@
+package antpool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/beatyman/scan-miners/config"
+	"github.com/beatyman/scan-miners/internal/domain/model"
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+)
+
+const (
+	testAPIKey    = "key-1"
+	testAPISecret = "secret-1"
+	testUserID    = "observer"
+)
+
+// fakeAPI stands in for workers.htm: it checks the form fields, the nonce and
+// the signature of every request and serves rows in pages of pageSize
+type fakeAPI struct {
+	t         *testing.T
+	rows      []apiWorkerRow
+	pageSize  int
+	mu        sync.Mutex
+	lastNonce int64
+	requests  int
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests++
+
+	if r.Method != http.MethodPost || r.URL.Path != "/workers.htm" {
+		f.t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "application/x-www-form-urlencoded" {
+		f.t.Errorf("Content-Type = %q", ct)
+	}
+	if err := r.ParseForm(); err != nil {
+		f.t.Fatal(err)
+	}
+	for field, want := range map[string]string{
+		"key":       testAPIKey,
+		"userId":    testUserID,
+		"coin_type": "BTC",
+		"pageSize":  strconv.Itoa(f.pageSize),
+	} {
+		if got := r.PostForm.Get(field); got != want {
+			f.t.Errorf("form %s = %q, want %q", field, got, want)
+		}
+	}
+
+	reply := func(v any) {
+		if err := json.NewEncoder(w).Encode(v); err != nil {
+			f.t.Error(err)
+		}
+	}
+	nonce, err := strconv.ParseInt(r.PostForm.Get("nonce"), 10, 64)
+	if err != nil || nonce <= f.lastNonce {
+		reply(map[string]any{"code": 1, "message": "invalid nonce"})
+		return
+	}
+	f.lastNonce = nonce
+	if r.PostForm.Get("signature") != Sign(testUserID, testAPIKey, testAPISecret, nonce) {
+		reply(map[string]any{"code": 1, "message": "signature verification failed"})
+		return
+	}
+
+	page, _ := strconv.Atoi(r.PostForm.Get("page"))
+	totalPage := (len(f.rows) + f.pageSize - 1) / f.pageSize
+	from := min((page-1)*f.pageSize, len(f.rows))
+	to := min(from+f.pageSize, len(f.rows))
+	reply(map[string]any{
+		"code":    0,
+		"message": "ok",
+		"data": map[string]any{
+			"page":        page,
+			"totalPage":   totalPage,
+			"pageSize":    f.pageSize,
+			"totalRecord": len(f.rows),
+			"rows":        f.rows[from:to],
+		},
+	})
+}
+
+func newTestAPIClient(t *testing.T, handler http.Handler) *apiClient {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	cfg := config.Default()
+	cfg.App.AntpoolAPIURL = srv.URL + "/"
+	cfg.App.PageSize = 2
+	cfg.App.AntpoolRPS = 1000
+	cfg.App.RetryBaseDelay = time.Millisecond
+	cfg.App.RetryMaxDelay = time.Millisecond
+	return newAPIClient(cfg, newPager(cfg))
+}
+
+func testAccount() model.PoolAccount {
+	return model.PoolAccount{ObserverUserID: testUserID, CoinType: "BTC", APIKey: testAPIKey, APISecret: testAPISecret, APIUserID: testUserID}
+}
+
+func TestSign(t *testing.T) {
+	// Computed independently: HMAC-SHA256 keyed "secret" over "user1" + "key1" + "1700000000000"
+	const want = "A2B00271D2F1804EE33731CABC900D3E0ED781DF46B7AF2D85639770EBFE109B"
+	if got := Sign("user1", "key1", "secret", 1700000000000); got != want {
+		t.Fatalf("Sign() = %s, want %s", got, want)
+	}
+}
+
+func TestAPIClientListWorkers(t *testing.T) {
+	api := &fakeAPI{t: t, pageSize: 2, rows: []apiWorkerRow{
+		{Worker: testUserID + ".1x1", Last10m: "110000000", Last1h: "100000000", Last1d: "90000000", Accepted: "990", Stale: "10"},
+		{Worker: testUserID + ".1x2", Last10m: "0"},
+		{Worker: testUserID + ".1x3", Last10m: "95000000"},
+	}}
+	client := newTestAPIClient(t, api)
+
+	var pages []*model.PoolWorkerPage
+	err := client.ListWorkers(context.Background(), testAccount(), 1, func(page *model.PoolWorkerPage) error {
+		pages = append(pages, page)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 2 || api.requests != 2 {
+		t.Fatalf("got %d pages in %d requests, want 2", len(pages), api.requests)
+	}
+	if pages[0].TotalRecord != 3 || pages[0].Status != nil {
+		t.Errorf("unexpected first page: %+v", pages[0])
+	}
+
+	w := pages[0].Workers[0]
+	if w.WorkerID != "1x1" || w.UserWorkerID != testUserID+".1x1" || w.PoolID != 0 {
+		t.Errorf("unexpected identity: %+v", w)
+	}
+	if w.HsLast10Min != (model.Hashrate{Value: 110, Unit: "TH/s"}) || w.HsLast1D.Value != 90 {
+		t.Errorf("hashrates not converted from MH/s: %+v", w)
+	}
+	if w.RejectRatio != "1.00%" || w.WorkerStatus != model.PoolWorkerOnline {
+		t.Errorf("reject ratio %q, status %d", w.RejectRatio, w.WorkerStatus)
+	}
+	if off := pages[0].Workers[1]; off.WorkerStatus == model.PoolWorkerOnline || off.RejectRatio != "" {
+		t.Errorf("idle worker reported online: %+v", off)
+	}
+	if pages[1].Workers[0].WorkerID != "1x3" {
+		t.Errorf("second page starts with %q", pages[1].Workers[0].WorkerID)
+	}
+}
+
+func TestAPIClientStartPage(t *testing.T) {
+	api := &fakeAPI{t: t, pageSize: 2, rows: []apiWorkerRow{{Worker: "a"}, {Worker: "b"}, {Worker: "c"}}}
+	client := newTestAPIClient(t, api)
+
+	var got []int
+	err := client.ListWorkers(context.Background(), testAccount(), 2, func(page *model.PoolWorkerPage) error {
+		got = append(got, page.PageNum)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != 2 {
+		t.Errorf("fetched pages %v, want [2]", got)
+	}
+}
+
+func TestAPIClientBadSignature(t *testing.T) {
+	api := &fakeAPI{t: t, pageSize: 2, rows: []apiWorkerRow{{Worker: "a"}}}
+	client := newTestAPIClient(t, api)
+	account := testAccount()
+	account.APISecret = "wrong"
+
+	err := client.ListWorkers(context.Background(), account, 1, func(*model.PoolWorkerPage) error { return nil })
+	if !errors.Is(err, repository.ErrPoolAuthExpired) {
+		t.Fatalf("ListWorkers() = %v, want ErrPoolAuthExpired", err)
+	}
+	if api.requests != 1 {
+		t.Errorf("rejected credentials were retried: %d requests", api.requests)
+	}
+}
+
+func TestAPIClientRetriesGatewayPage(t *testing.T) {
+	api := &fakeAPI{t: t, pageSize: 2, rows: []apiWorkerRow{{Worker: "a"}, {Worker: "b"}, {Worker: "c"}, {Worker: "d"}, {Worker: "e"}}}
+	failed := false
+	client := newTestAPIClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A proxy in front of the pool answers page 2 once with its own error page
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		if r.PostForm.Get("page") == "2" && !failed {
+			failed = true
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte("<html><head><title>502 Bad Gateway</title></head><body>nginx</body></html>"))
+			return
+		}
+		api.ServeHTTP(w, r)
+	}))
+
+	var got []int
+	err := client.ListWorkers(context.Background(), testAccount(), 1, func(page *model.PoolWorkerPage) error {
+		got = append(got, page.PageNum)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ListWorkers() = %v, want the gateway error retried", err)
+	}
+	if !failed || len(got) != 3 || got[1] != 2 || got[2] != 3 {
+		t.Errorf("fetched pages %v, want [1 2 3] after one failure", got)
+	}
+}
+
+func TestAPIClientNonceIncreases(t *testing.T) {
+	c := &apiClient{}
+	// Far in the future, so the clock alone would go backwards
+	c.lastNonce.Store(time.Now().Add(time.Hour).UnixMilli())
+	prev := c.lastNonce.Load()
+	for range 100 {
+		n := c.nextNonce()
+		if n <= prev {
+			t.Fatalf("nonce %d not above %d", n, prev)
+		}
+		prev = n
+	}
+}
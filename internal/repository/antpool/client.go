@@ -0,0 +1,125 @@
+// Package antpool implements repository.PoolClient for Antpool, either through the
+// observer web endpoints (cookie auth) or the official signed API (key/secret auth).
+package antpool
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/beatyman/scan-miners/config"
+	"github.com/beatyman/scan-miners/internal/domain/model"
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+	"github.com/beatyman/scan-miners/pkg/logger"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+// NewClient returns the Antpool client for the configured authentication mode
+func NewClient(cfg *config.Config) repository.PoolClient {
+	p := newPager(cfg)
+	if cfg.App.AntpoolAuth == config.AntpoolAuthAPI {
+		return newAPIClient(cfg, p)
+	}
+	return newCookieClient(cfg, p)
+}
+
+type fetchPageFunc func(ctx context.Context, pageNum int) (*model.PoolWorkerPage, error)
+
+// pager walks a paginated worker list. Every request, including retries, waits
+// on a shared rate limiter; a failed page is retried with jittered backoff.
+type pager struct {
+	limiter     *rate.Limiter
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+}
+
+func newPager(cfg *config.Config) *pager {
+	return &pager{
+		limiter:     rate.NewLimiter(rate.Limit(cfg.App.AntpoolRPS), 1),
+		maxAttempts: cfg.App.RetryMaxAttempts,
+		baseDelay:   cfg.App.RetryBaseDelay,
+		maxDelay:    cfg.App.RetryMaxDelay,
+	}
+}
+
+// walk fetches pages from startPage until the last page reported by the pool
+func (p *pager) walk(ctx context.Context, account model.PoolAccount, startPage int, fetch fetchPageFunc, fn func(page *model.PoolWorkerPage) error) error {
+	if startPage < 1 {
+		startPage = 1
+	}
+
+	// The response reports "totalPage" and "totalRecord", so we loop until every page is fetched.
+	for pageNum := startPage; ; pageNum++ {
+		logger.Log.Info("Fetching workers page", zap.String("observerUserId", account.ObserverUserID), zap.Int("page", pageNum))
+
+		page, err := p.fetchWithRetry(ctx, pageNum, fetch)
+		if err != nil {
+			return err
+		}
+		if err := fn(page); err != nil {
+			return err
+		}
+
+		if pageNum >= page.TotalPage {
+			return nil
+		}
+	}
+}
+
+func (p *pager) fetchWithRetry(ctx context.Context, pageNum int, fetch fetchPageFunc) (*model.PoolWorkerPage, error) {
+	for attempt := 1; ; attempt++ {
+		if err := p.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		page, err := fetch(ctx, pageNum)
+		if err == nil {
+			return page, nil
+		}
+		if attempt >= p.maxAttempts || !retryable(ctx, err) {
+			return nil, err
+		}
+
+		delay := p.backoff(attempt)
+		logger.Log.Warn("Fetching workers page failed, retrying",
+			zap.Int("page", pageNum), zap.Int("attempt", attempt), zap.Duration("backoff", delay), zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// backoff returns a "full jitter" delay: uniform in [0, min(maxDelay, baseDelay*2^(attempt-1))]
+func (p *pager) backoff(attempt int) time.Duration {
+	ceiling := p.baseDelay << (attempt - 1)
+	if ceiling <= 0 || ceiling > p.maxDelay {
+		ceiling = p.maxDelay
+	}
+	return rand.N(ceiling + 1)
+}
+
+// retryable reports whether a page failure is worth another attempt.
+// Expired credentials and schema changes will not fix themselves.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if errors.Is(err, repository.ErrPoolAuthExpired) || errors.Is(err, repository.ErrPoolSchemaChanged) {
+		return false
+	}
+
+	var respErr *ResponseError
+	if errors.As(err, &respErr) && respErr.Kind == nil {
+		// Unclassified application errors are deterministic; only server errors are transient
+		return respErr.StatusCode >= http.StatusInternalServerError
+	}
+	// Network errors, rate limiting and maintenance windows
+	return true
+}
@@ -0,0 +1,121 @@
+package antpool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/beatyman/scan-miners/internal/domain/model"
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+	"golang.org/x/time/rate"
+)
+
+func testPager(maxAttempts int) *pager {
+	return &pager{
+		limiter:     rate.NewLimiter(rate.Inf, 1),
+		maxAttempts: maxAttempts,
+		baseDelay:   time.Millisecond,
+		maxDelay:    4 * time.Millisecond,
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := &pager{baseDelay: 100 * time.Millisecond, maxDelay: time.Second}
+	ceilings := map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 4: 800 * time.Millisecond, 5: time.Second, 60: time.Second}
+	for attempt, ceiling := range ceilings {
+		for range 200 {
+			if d := p.backoff(attempt); d < 0 || d > ceiling {
+				t.Fatalf("backoff(%d) = %s, want within [0, %s]", attempt, d, ceiling)
+			}
+		}
+	}
+}
+
+func TestFetchWithRetry(t *testing.T) {
+	rateLimited := &ResponseError{Kind: repository.ErrPoolRateLimited}
+	authExpired := &ResponseError{Kind: repository.ErrPoolAuthExpired}
+
+	tests := []struct {
+		name         string
+		maxAttempts  int
+		failures     []error // returned by the first calls, then the page
+		wantErr      error
+		wantAttempts int
+	}{
+		{"first try", 3, nil, nil, 1},
+		{"transient then ok", 3, []error{rateLimited, errors.New("reset")}, nil, 3},
+		{"gives up", 3, []error{rateLimited, rateLimited, rateLimited, rateLimited}, repository.ErrPoolRateLimited, 3},
+		{"not retried", 3, []error{authExpired}, repository.ErrPoolAuthExpired, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			page, err := testPager(tt.maxAttempts).fetchWithRetry(context.Background(), 1, func(ctx context.Context, pageNum int) (*model.PoolWorkerPage, error) {
+				attempts++
+				if attempts <= len(tt.failures) {
+					return nil, tt.failures[attempts-1]
+				}
+				return &model.PoolWorkerPage{PageNum: pageNum, TotalPage: 1}, nil
+			})
+			if tt.wantErr == nil && (err != nil || page == nil) {
+				t.Fatalf("fetchWithRetry() = %v, %v", page, err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("fetchWithRetry() error = %v, want %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("%d attempts, want %d", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestFetchWithRetryStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := testPager(10)
+	p.baseDelay, p.maxDelay = time.Hour, time.Hour
+
+	attempts := 0
+	done := make(chan error)
+	go func() {
+		_, err := p.fetchWithRetry(ctx, 1, func(context.Context, int) (*model.PoolWorkerPage, error) {
+			attempts++
+			return nil, errors.New("reset")
+		})
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("fetchWithRetry() = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("fetchWithRetry() kept waiting after cancellation")
+	}
+}
+
+func TestWalk(t *testing.T) {
+	var fetched, handed []int
+	fetch := func(ctx context.Context, pageNum int) (*model.PoolWorkerPage, error) {
+		fetched = append(fetched, pageNum)
+		return &model.PoolWorkerPage{PageNum: pageNum, TotalPage: 4}, nil
+	}
+	stop := errors.New("stop")
+	err := testPager(1).walk(context.Background(), model.PoolAccount{}, 2, fetch, func(page *model.PoolWorkerPage) error {
+		handed = append(handed, page.PageNum)
+		if page.PageNum == 3 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) {
+		t.Fatalf("walk() = %v, want the callback error", err)
+	}
+	if len(fetched) != 2 || fetched[0] != 2 || fetched[1] != 3 || len(handed) != 2 {
+		t.Errorf("fetched %v, handed %v, want pages 2 and 3", fetched, handed)
+	}
+}
@@ -0,0 +1,112 @@
+package antpool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/beatyman/scan-miners/config"
+	"github.com/beatyman/scan-miners/internal/domain/model"
+)
+
+const workerListURL = "https://www.antpool.com/auth/v3/observer/api/worker/list"
+
+// cookieClient talks to the observer endpoints of the Antpool web UI, authenticated by a browser cookie
+type cookieClient struct {
+	cookie   string
+	pageSize int
+	pager    *pager
+	http     *http.Client
+}
+
+func newCookieClient(cfg *config.Config, p *pager) *cookieClient {
+	return &cookieClient{
+		cookie:   cfg.App.AntpoolCookie,
+		pageSize: cfg.App.PageSize,
+		pager:    p,
+		http: &http.Client{
+			Timeout: cfg.App.RequestTimeout,
+		},
+	}
+}
+
+func (c *cookieClient) ListWorkers(ctx context.Context, account model.PoolAccount, startPage int, fn func(page *model.PoolWorkerPage) error) error {
+	if c.cookie == "" {
+		return fmt.Errorf("app.antpool_cookie is required for the Antpool observer API (set it in the config file or %s)", config.EnvName("app.antpool_cookie"))
+	}
+
+	return c.pager.walk(ctx, account, startPage, func(ctx context.Context, pageNum int) (*model.PoolWorkerPage, error) {
+		return c.fetchPage(ctx, account, pageNum)
+	}, fn)
+}
+
+func (c *cookieClient) fetchPage(ctx context.Context, account model.PoolAccount, pageNum int) (*model.PoolWorkerPage, error) {
+	query := url.Values{}
+	query.Set("search", "")
+	query.Set("workerStatus", "0")
+	query.Set("accessKey", account.AccessKey)
+	query.Set("coinType", account.CoinType)
+	query.Set("observerUserId", account.ObserverUserID)
+	query.Set("pageNum", strconv.Itoa(pageNum))
+	query.Set("pageSize", strconv.Itoa(c.pageSize))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", workerListURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set headers from requirement
+	req.Header.Set("accept", "application/json, text/plain, */*")
+	req.Header.Set("accept-language", "en,zh-CN;q=0.9,zh;q=0.8")
+	req.Header.Set("cache-control", "no-cache")
+	req.Header.Set("cookie", c.cookie)
+	req.Header.Set("user-agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/144.0.0.0 Safari/537.36")
+	// Add other headers if strictly necessary, but usually UA and Cookie are key.
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := classifyHTTP(resp.StatusCode, body, pageNum); err != nil {
+		return nil, err
+	}
+
+	var result workerListResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, schemaError(resp.StatusCode, pageNum, err.Error())
+	}
+
+	if result.Code != "000000" {
+		return nil, classifyCode(resp.StatusCode, result.Code, result.Msg, pageNum)
+	}
+	if result.Data == nil {
+		return nil, schemaError(resp.StatusCode, pageNum, `missing "data" object`)
+	}
+
+	page := &model.PoolWorkerPage{
+		PageNum:     pageNum,
+		TotalPage:   result.Data.TotalPage,
+		TotalRecord: result.Data.TotalRecord,
+		Status: &model.PoolWorkerStatus{
+			Total:   result.Data.WorkerStatus.TotalWorkerNum,
+			Online:  result.Data.WorkerStatus.OnlineWorkerNum,
+			Offline: result.Data.WorkerStatus.OfflineWorkerNum,
+			Disable: result.Data.WorkerStatus.DisableWorkerNum,
+		},
+	}
+	for _, item := range result.Data.Items {
+		page.Workers = append(page.Workers, item.toPoolWorker())
+	}
+	return page, nil
+}
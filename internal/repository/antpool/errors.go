@@ -0,0 +1,108 @@
+package antpool
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+)
+
+// ResponseError describes an Antpool response that could not be turned into a
+// worker page. Kind is one of the repository.ErrPool* sentinels, or nil when
+// the failure does not fit a known class.
+type ResponseError struct {
+	Kind       error
+	StatusCode int
+	Code       string
+	Msg        string
+	Page       int
+}
+
+func (e *ResponseError) Error() string {
+	kind := "unexpected response"
+	if e.Kind != nil {
+		kind = e.Kind.Error()
+	}
+	msg := fmt.Sprintf("antpool: %s (page %d, http %d", kind, e.Page, e.StatusCode)
+	if e.Code != "" {
+		msg += ", code " + e.Code
+	}
+	if e.Msg != "" {
+		msg += ", msg " + e.Msg
+	}
+	return msg + ")"
+}
+
+func (e *ResponseError) Unwrap() error {
+	return e.Kind
+}
+
+var (
+	authKeywords        = []string{"login", "log in", "sign in", "session", "token", "expired", "unauthorized", "signature", "登录", "过期"}
+	rateLimitKeywords   = []string{"too many", "frequent", "rate limit", "频繁", "限流"}
+	maintenanceKeywords = []string{"maintenance", "upgrading", "维护", "升级"}
+)
+
+func containsAny(s string, keywords []string) bool {
+	s = strings.ToLower(s)
+	for _, k := range keywords {
+		if strings.Contains(s, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyHTTP inspects the transport-level shape of a response before any
+// JSON decoding and returns an error when it is clearly not a worker page.
+func classifyHTTP(statusCode int, body []byte, page int) error {
+	switch statusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return &ResponseError{Kind: repository.ErrPoolAuthExpired, StatusCode: statusCode, Page: page}
+	case http.StatusTooManyRequests:
+		return &ResponseError{Kind: repository.ErrPoolRateLimited, StatusCode: statusCode, Page: page}
+	case http.StatusServiceUnavailable:
+		return &ResponseError{Kind: repository.ErrPoolMaintenance, StatusCode: statusCode, Page: page}
+	}
+
+	trimmed := bytes.TrimSpace(body)
+	html := len(trimmed) > 0 && trimmed[0] == '<'
+	if html && containsAny(string(trimmed), maintenanceKeywords) {
+		return &ResponseError{Kind: repository.ErrPoolMaintenance, StatusCode: statusCode, Msg: "HTML page instead of JSON", Page: page}
+	}
+
+	if statusCode != http.StatusOK {
+		// A gateway error page from a proxy says nothing about the session;
+		// unclassified server errors stay retryable
+		if html && statusCode >= http.StatusMultipleChoices && statusCode < http.StatusBadRequest && containsAny(string(trimmed), authKeywords) {
+			return &ResponseError{Kind: repository.ErrPoolAuthExpired, StatusCode: statusCode, Msg: "redirect to the login page", Page: page}
+		}
+		return &ResponseError{StatusCode: statusCode, Page: page}
+	}
+
+	// An expired observer session is answered with the HTML login page, not JSON
+	if html {
+		return &ResponseError{Kind: repository.ErrPoolAuthExpired, StatusCode: statusCode, Msg: "HTML page instead of JSON", Page: page}
+	}
+	return nil
+}
+
+// classifyCode turns an application-level error code and message into a ResponseError
+func classifyCode(statusCode int, code, msg string, page int) error {
+	var kind error
+	switch {
+	case containsAny(msg, rateLimitKeywords):
+		kind = repository.ErrPoolRateLimited
+	case containsAny(msg, maintenanceKeywords):
+		kind = repository.ErrPoolMaintenance
+	case containsAny(msg, authKeywords):
+		kind = repository.ErrPoolAuthExpired
+	}
+	return &ResponseError{Kind: kind, StatusCode: statusCode, Code: code, Msg: msg, Page: page}
+}
+
+func schemaError(statusCode int, page int, msg string) error {
+	return &ResponseError{Kind: repository.ErrPoolSchemaChanged, StatusCode: statusCode, Msg: msg, Page: page}
+}
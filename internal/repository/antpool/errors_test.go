@@ -0,0 +1,121 @@
+package antpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+)
+
+func TestClassifyHTTP(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error // nil: not an error; errUnclassified: a ResponseError without Kind
+	}{
+		{"ok json", http.StatusOK, `{"code":"000000"}`, nil},
+		{"unauthorized", http.StatusUnauthorized, ``, repository.ErrPoolAuthExpired},
+		{"forbidden", http.StatusForbidden, ``, repository.ErrPoolAuthExpired},
+		{"too many requests", http.StatusTooManyRequests, ``, repository.ErrPoolRateLimited},
+		{"unavailable", http.StatusServiceUnavailable, ``, repository.ErrPoolMaintenance},
+		{"login page", http.StatusOK, "  <!DOCTYPE html><title>Login</title>", repository.ErrPoolAuthExpired},
+		{"maintenance page", http.StatusOK, "<html>System maintenance in progress</html>", repository.ErrPoolMaintenance},
+		{"server error", http.StatusBadGateway, `{}`, errUnclassified},
+		{"bad gateway page", http.StatusBadGateway, "<html><head><title>502 Bad Gateway</title></head><body><center>nginx</center></body></html>", errUnclassified},
+		{"gateway timeout page", http.StatusGatewayTimeout, "<html><title>504 Gateway Time-out</title></html>", errUnclassified},
+		{"maintenance page on error", http.StatusBadGateway, "<html>维护中</html>", repository.ErrPoolMaintenance},
+		{"redirect to login", http.StatusFound, `<a href="/auth/login">Found</a>`, repository.ErrPoolAuthExpired},
+		{"other redirect", http.StatusFound, `<a href="/home">Found</a>`, errUnclassified},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkKind(t, classifyHTTP(tt.status, []byte(tt.body), 3), tt.want)
+		})
+	}
+}
+
+func TestClassifyCode(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want error
+	}{
+		{"Request too frequent", repository.ErrPoolRateLimited},
+		{"请求过于频繁", repository.ErrPoolRateLimited},
+		{"System upgrading", repository.ErrPoolMaintenance},
+		{"Login expired, please log in again", repository.ErrPoolAuthExpired},
+		{"signature verification failed", repository.ErrPoolAuthExpired},
+		{"coin type not supported", errUnclassified},
+	}
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			err := classifyCode(http.StatusOK, "E1", tt.msg, 2)
+			checkKind(t, err, tt.want)
+			var respErr *ResponseError
+			if errors.As(err, &respErr) && (respErr.Code != "E1" || respErr.Msg != tt.msg || respErr.Page != 2) {
+				t.Errorf("details lost: %+v", respErr)
+			}
+		})
+	}
+}
+
+func TestSchemaError(t *testing.T) {
+	checkKind(t, schemaError(http.StatusOK, 1, "missing data"), repository.ErrPoolSchemaChanged)
+}
+
+func TestRetryable(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want bool
+	}{
+		{"network error", context.Background(), errors.New("connection reset"), true},
+		{"rate limited", context.Background(), &ResponseError{Kind: repository.ErrPoolRateLimited}, true},
+		{"maintenance", context.Background(), &ResponseError{Kind: repository.ErrPoolMaintenance}, true},
+		{"auth expired", context.Background(), &ResponseError{Kind: repository.ErrPoolAuthExpired}, false},
+		{"schema changed", context.Background(), fmt.Errorf("page 3: %w", schemaError(200, 3, "x")), false},
+		{"unclassified 5xx", context.Background(), &ResponseError{StatusCode: 502}, true},
+		{"gateway page", context.Background(), classifyHTTP(http.StatusGatewayTimeout, []byte("<html>504</html>"), 1), true},
+		{"unclassified application error", context.Background(), &ResponseError{StatusCode: 200, Code: "E1"}, false},
+		{"cancelled", cancelled, errors.New("connection reset"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryable(tt.ctx, tt.err); got != tt.want {
+				t.Errorf("retryable() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+var errUnclassified = errors.New("unclassified")
+
+func checkKind(t *testing.T, err, want error) {
+	t.Helper()
+	if want == nil {
+		if err != nil {
+			t.Fatalf("got %v, want no error", err)
+		}
+		return
+	}
+	var respErr *ResponseError
+	if !errors.As(err, &respErr) {
+		t.Fatalf("got %v, want a *ResponseError", err)
+	}
+	if want == errUnclassified {
+		if respErr.Kind != nil {
+			t.Fatalf("got kind %v, want none", respErr.Kind)
+		}
+		return
+	}
+	if !errors.Is(err, want) {
+		t.Fatalf("got %v, want %v", err, want)
+	}
+}
@@ -0,0 +1,14 @@
+package antpool
+
+import (
+	"os"
+	"testing"
+
+	"github.com/beatyman/scan-miners/pkg/logger"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	logger.Log = zap.NewNop()
+	os.Exit(m.Run())
+}
@@ -0,0 +1,191 @@
+package antpool
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beatyman/scan-miners/internal/domain/model"
+	"github.com/beatyman/scan-miners/pkg/utils"
+)
+
+// workerListResponse is the envelope returned by the observer worker list endpoint
+type workerListResponse struct {
+	Code string `json:"code"`
+	Msg  string `json:"msg"`
+	Data *struct {
+		WorkerStatus struct {
+			TotalWorkerNum   int `json:"totalWorkerNum"`
+			OnlineWorkerNum  int `json:"onlineWorkerNum"`
+			OfflineWorkerNum int `json:"offlineWorkerNum"`
+			DisableWorkerNum int `json:"disableWorkerNum"`
+		} `json:"workerStatus"`
+		Items       []workerItem `json:"items"`
+		PageNum     int          `json:"pageNum"`
+		TotalPage   int          `json:"totalPage"`
+		TotalRecord int          `json:"totalRecord"`
+	} `json:"data"`
+}
+
+// workerItem is used for JSON unmarshalling from Antpool API
+type workerItem struct {
+	ID                int64   `json:"id"`
+	WorkerID          string  `json:"workerId"`
+	UserWorkerID      string  `json:"userWorkerId"`
+	WorkerStatus      int     `json:"workerStatus"`
+	HsLast10Min       string  `json:"hsLast10Min"`
+	HsLast1H          string  `json:"hsLast1H"` // Note: API has hsLast1Hour and hsLast1H
+	HsLast1Hour       string  `json:"hsLast1Hour"`
+	HsLast1D          string  `json:"hsLast1D"`
+	RejectRatio       string  `json:"rejectRatio"`
+	OnlineTimeLast24h float64 `json:"onlineTimeLast24h"`
+	ReconnectLast24h  int     `json:"reconnectLast24h"`
+	BurstBlock72h     bool    `json:"burstBlock72h"`
+	CreateTime        int64   `json:"createTime"`
+	UpdateTime        *int64  `json:"updateTime"`
+	ShareLastTime     *int64  `json:"shareLastTime"`
+	GroupID           int64   `json:"groupId"`
+	GroupName         *string `json:"groupName"`
+
+	// Types of the health fields are undocumented (always null in samples), so keep them raw
+	FanCode          json.RawMessage `json:"fanCode"`
+	FanValue         json.RawMessage `json:"fanValue"`
+	HashCode         json.RawMessage `json:"hashCode"`
+	HashValue        json.RawMessage `json:"hashValue"`
+	NetworkCode      json.RawMessage `json:"networkCode"`
+	NetworkValue     json.RawMessage `json:"networkValue"`
+	TemperatureCode  json.RawMessage `json:"temperatureCode"`
+	TemperatureValue json.RawMessage `json:"temperatureValue"`
+}
+
+func (it workerItem) toPoolWorker() model.PoolWorker {
+	pw := model.PoolWorker{
+		PoolID:            it.ID,
+		WorkerID:          it.WorkerID,
+		UserWorkerID:      it.UserWorkerID,
+		WorkerStatus:      it.WorkerStatus,
+		HsLast10Min:       parseHashrate(it.HsLast10Min),
+		HsLast1H:          parseHashrate(it.HsLast1H),
+		HsLast1Hour:       parseHashrate(it.HsLast1Hour),
+		HsLast1D:          parseHashrate(it.HsLast1D),
+		RejectRatio:       it.RejectRatio,
+		OnlineTimeLast24h: it.OnlineTimeLast24h,
+		ReconnectLast24h:  it.ReconnectLast24h,
+		BurstBlock72h:     it.BurstBlock72h,
+		CreateTime:        time.UnixMilli(it.CreateTime),
+		UpdateTime:        millisToTime(it.UpdateTime),
+		ShareLastTime:     millisToTime(it.ShareLastTime),
+		GroupID:           it.GroupID,
+		FanCode:           rawString(it.FanCode),
+		FanValue:          rawString(it.FanValue),
+		HashCode:          rawString(it.HashCode),
+		HashValue:         rawString(it.HashValue),
+		NetworkCode:       rawString(it.NetworkCode),
+		NetworkValue:      rawString(it.NetworkValue),
+		TemperatureCode:   rawString(it.TemperatureCode),
+		TemperatureValue:  rawString(it.TemperatureValue),
+	}
+	if it.GroupName != nil {
+		pw.GroupName = *it.GroupName
+	}
+	return pw
+}
+
+func millisToTime(ms *int64) *time.Time {
+	if ms == nil || *ms == 0 {
+		return nil
+	}
+	t := time.UnixMilli(*ms)
+	return &t
+}
+
+// rawString renders a JSON scalar as text: strings unquoted, null as ""
+func rawString(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
+
+func parseHashrate(s string) model.Hashrate {
+	val, unit := utils.ParseHashrate(s)
+	return model.Hashrate{Value: val, Unit: unit}
+}
+
+// apiWorkersResponse is the envelope returned by the signed workers.htm endpoint
+type apiWorkersResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    *struct {
+		Page        int            `json:"page"`
+		TotalPage   int            `json:"totalPage"`
+		PageSize    int            `json:"pageSize"`
+		TotalRecord int            `json:"totalRecord"`
+		Rows        []apiWorkerRow `json:"rows"`
+	} `json:"data"`
+}
+
+// apiWorkerRow is one worker in workers.htm. Hashrates are numeric strings in
+// MH/s, share counters are used to derive the reject ratio.
+type apiWorkerRow struct {
+	Worker    string `json:"worker"`
+	Last10m   string `json:"last10m"`
+	Last1h    string `json:"last1h"`
+	Last1d    string `json:"last1d"`
+	Accepted  string `json:"accepted"`
+	Stale     string `json:"stale"`
+	Duplicate string `json:"dupelicate"` // Sic, the API spells it this way
+	Other     string `json:"other"`
+}
+
+// toPoolWorker maps a row onto a PoolWorker. The API has no worker id, so
+// PoolID stays 0 and a renamed worker shows up as removed plus new.
+func (r apiWorkerRow) toPoolWorker(account model.PoolAccount) model.PoolWorker {
+	// "worker" is the full "<user>.<worker>" name
+	workerID := strings.TrimPrefix(r.Worker, account.APIUserID+".")
+
+	last10m := apiHashrate(r.Last10m)
+	status := 0
+	if last10m.Value > 0 {
+		status = model.PoolWorkerOnline
+	}
+
+	return model.PoolWorker{
+		WorkerID:     workerID,
+		UserWorkerID: r.Worker,
+		WorkerStatus: status,
+		HsLast10Min:  last10m,
+		HsLast1H:     apiHashrate(r.Last1h),
+		HsLast1D:     apiHashrate(r.Last1d),
+		RejectRatio:  r.rejectRatio(),
+	}
+}
+
+// apiHashrate converts the API's raw MH/s figure to TH/s so both clients store the same unit
+func apiHashrate(s string) model.Hashrate {
+	val, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return model.Hashrate{}
+	}
+	return model.Hashrate{Value: val / 1e6, Unit: "TH/s"}
+}
+
+func (r apiWorkerRow) rejectRatio() string {
+	accepted := parseCount(r.Accepted)
+	rejected := parseCount(r.Stale) + parseCount(r.Duplicate) + parseCount(r.Other)
+	if accepted+rejected == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%.2f%%", rejected/(accepted+rejected)*100)
+}
+
+func parseCount(s string) float64 {
+	v, _ := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return v
+}
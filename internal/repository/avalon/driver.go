@@ -0,0 +1,170 @@
+// Package avalon reads Canaan AvalonMiners. Their cgminer fork packs most
+// per-module figures into "Key[value]" strings under STATS "MM ID<n>".
+package avalon
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/beatyman/scan-miners/internal/domain/model"
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+	"github.com/beatyman/scan-miners/internal/repository/cgminer"
+)
+
+type driver struct {
+	client *cgminer.Client
+}
+
+func NewDriver(client *cgminer.Client) repository.MinerDriver {
+	return &driver{client: client}
+}
+
+func (d *driver) Name() string {
+	return model.DriverAvalon
+}
+
+func (d *driver) Detect(ctx context.Context, ip string) (bool, error) {
+	version, err := d.version(ctx, ip)
+	if err != nil {
+		return false, err
+	}
+	return strings.HasPrefix(cgminer.Str(version["PROD"]), "Avalon"), nil
+}
+
+func (d *driver) FetchInfo(ctx context.Context, ip string) (*model.DeviceInfo, error) {
+	version, err := d.version(ctx, ip)
+	if err != nil {
+		return nil, err
+	}
+	return &model.DeviceInfo{
+		Driver:   model.DriverAvalon,
+		Model:    cgminer.Str(version["PROD"]),
+		Firmware: cgminer.FirstStr(version, "LVERSION", "CGMiner"),
+		MAC:      cgminer.Str(version["MAC"]),
+	}, nil
+}
+
+func (d *driver) FetchStats(ctx context.Context, ip string) (*model.MinerStats, error) {
+	body, err := d.client.Command(ctx, ip, "summary")
+	if err != nil {
+		return nil, err
+	}
+	summary, err := cgminer.DecodeSection(body, "SUMMARY")
+	if err != nil {
+		return nil, err
+	}
+
+	ms := &model.MinerStats{
+		Source:   model.DriverAvalon,
+		Elapsed:  int64(cgminer.Num(summary["Elapsed"])),
+		Rate5s:   cgminer.GHS(summary, "5s"),
+		Rate30m:  cgminer.GHS(summary, "30m"),
+		RateAvg:  cgminer.GHS(summary, "av"),
+		RateUnit: "GH/s",
+		HwpTotal: cgminer.Num(summary["Device Hardware%"]),
+	}
+
+	if version, err := d.version(ctx, ip); err == nil {
+		ms.MinerType = cgminer.Str(version["PROD"])
+		ms.MinerVersion = cgminer.FirstStr(version, "LVERSION", "CGMiner")
+	}
+
+	body, err = d.client.Command(ctx, ip, "stats")
+	if err != nil {
+		return nil, err
+	}
+	stats, err := cgminer.DecodeSection(body, "STATS")
+	if err != nil {
+		return nil, err
+	}
+	var fans []float64
+	for _, mm := range moduleStrings(stats) {
+		fields := parseBracketFields(mm)
+		ms.RateIdeal += cgminer.Num(fields["GHSmm"])
+		fans = append(fans, fanSpeeds(fields)...)
+		// MGHS lists the real hashrate of each hashboard of the module, MTmax
+		// and MTavg the hottest and average chip temperature of each
+		maxTemps, avgTemps := strings.Fields(fields["MTmax"]), strings.Fields(fields["MTavg"])
+		for i, rate := range strings.Fields(fields["MGHS"]) {
+			chain := model.MinerChain{
+				ChainIndex: len(ms.Chains),
+				RateReal:   cgminer.Num(rate),
+			}
+			if i < len(maxTemps) {
+				chain.TempChipMax = cgminer.Num(maxTemps[i])
+			}
+			if i < len(avgTemps) {
+				chain.TempChipAvg = cgminer.Num(avgTemps[i])
+			}
+			ms.Chains = append(ms.Chains, chain)
+		}
+	}
+
+	ms.FanNum = len(fans)
+	ms.SetFanSpeeds(fans)
+
+	if cgminer.IsEmpty(ms) {
+		return nil, cgminer.ErrNoStats
+	}
+	return ms, nil
+}
+
+func (d *driver) version(ctx context.Context, ip string) (map[string]interface{}, error) {
+	body, err := d.client.Command(ctx, ip, "version")
+	if err != nil {
+		return nil, err
+	}
+	return cgminer.DecodeSection(body, "VERSION")
+}
+
+var reMMKey = regexp.MustCompile(`^MM ID\d+$`)
+
+// moduleStrings returns the "MM ID<n>" values in module order
+func moduleStrings(stats map[string]interface{}) []string {
+	var keys []string
+	for k := range stats {
+		if reMMKey.MatchString(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	var modules []string
+	for _, k := range keys {
+		modules = append(modules, cgminer.Str(stats[k]))
+	}
+	return modules
+}
+
+var reBracketField = regexp.MustCompile(`([A-Za-z][\w]*)\[([^\]]*)\]`)
+
+// parseBracketFields splits "Ver[1246-N] Elapsed[1234] MGHS[28.1 28.3 28.0]" into a map
+func parseBracketFields(s string) map[string]string {
+	fields := make(map[string]string)
+	for _, m := range reBracketField.FindAllStringSubmatch(s, -1) {
+		fields[m[1]] = strings.TrimSpace(m[2])
+	}
+	return fields
+}
+
+var reFanKey = regexp.MustCompile(`^Fan(\d+)$`)
+
+// fanSpeeds returns the Fan1, Fan2, ... readings of a module in fan order
+func fanSpeeds(fields map[string]string) []float64 {
+	var ports []int
+	for k := range fields {
+		if m := reFanKey.FindStringSubmatch(k); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			ports = append(ports, n)
+		}
+	}
+	sort.Ints(ports)
+
+	var rpms []float64
+	for _, n := range ports {
+		rpms = append(rpms, cgminer.Num(fields["Fan"+strconv.Itoa(n)]))
+	}
+	return rpms
+}
@@ -0,0 +1,62 @@
+package avalon
+
+import (
+	"context"
+	"testing"
+
+	"github.com/beatyman/scan-miners/internal/domain/model"
+	"github.com/beatyman/scan-miners/internal/repository/cgminer"
+	"github.com/beatyman/scan-miners/pkg/transcript"
+)
+
+// replay returns a context that answers cgminer commands from the given raw replies
+func replay(responses map[string]string) context.Context {
+	ctx, tr := transcript.Record(context.Background())
+	for command, raw := range responses {
+		tr.Add(command, []byte(raw))
+	}
+	return transcript.Replay(ctx, tr)
+}
+
+// Replies of an AvalonMiner 1246 with one module of three hashboards
+var a1246Replies = map[string]string{
+	"summary": `{"STATUS":[{"STATUS":"S","Msg":"Summary"}],"SUMMARY":[{"Elapsed":86400,"MHS av":83512345.00,"MHS 5s":84000000.00,"MHS 30m":83800000.00,"Device Hardware%":0.0100}],"id":1}` + "\x00",
+	"version": `{"STATUS":[{"STATUS":"S","Msg":"CGMiner versions"}],"VERSION":[{"CGMiner":"4.11.1","API":"3.7","PROD":"AvalonMiner 1246-N","MODEL":"1246-N","LVERSION":"21042001_4ec6bb0_61407fa","MAC":"b4a2eb0012ab"}],"id":1}` + "\x00",
+	"stats": `{"STATUS":[{"STATUS":"S","Msg":"CGMiner stats"}],"STATS":[{"STATS":0,"ID":"AVA100","Elapsed":86400,` +
+		`"MM ID0":"Ver[1246-N-21042001_4ec6bb0_61407fa] DNA[0201000012345678] Elapsed[86400] MW[1200 1200 1200] LW[3600] Temp[32] TMax[89] TAvg[80] Fan1[6720] Fan2[6690] FanR[94%] GHSspd[83700.00] DH[2.10%] GHSmm[90000.00] GHSavg[83512.34] MGHS[27901.02 27756.30 27855.02] MTmax[89 88 87] MTavg[80 79 81] TA[360] ECHU[0 0 0] ECMM[0]"},` +
+		`{"STATS":1,"ID":"POOL0"}],"id":1}` + "\x00",
+}
+
+func TestFetchStats(t *testing.T) {
+	ms, err := NewDriver(cgminer.NewClient(4028, 0, 0)).FetchStats(replay(a1246Replies), "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ms.Source != model.DriverAvalon || ms.MinerType != "AvalonMiner 1246-N" || ms.MinerVersion != "21042001_4ec6bb0_61407fa" {
+		t.Errorf("source %q, type %q, version %q", ms.Source, ms.MinerType, ms.MinerVersion)
+	}
+	if ms.Elapsed != 86400 || ms.Rate5s != 84000 || ms.Rate30m != 83800 || ms.RateIdeal != 90000 {
+		t.Errorf("unexpected rates: %+v", ms)
+	}
+	if ms.FanNum != 2 || ms.FanSpeeds != "6720,6690" {
+		t.Errorf("fans = %q of %d", ms.FanSpeeds, ms.FanNum)
+	}
+	if len(ms.Chains) != 3 {
+		t.Fatalf("%d chains, want one per MGHS entry", len(ms.Chains))
+	}
+	for i, want := range []struct{ rate, tmax, tavg float64 }{{27901.02, 89, 80}, {27756.30, 88, 79}, {27855.02, 87, 81}} {
+		c := ms.Chains[i]
+		if c.ChainIndex != i || c.RateReal != want.rate || c.TempChipMax != want.tmax || c.TempChipAvg != want.tavg {
+			t.Errorf("chain %d = index %d, rate %v, chip max %v avg %v; want %v", i, c.ChainIndex, c.RateReal, c.TempChipMax, c.TempChipAvg, want)
+		}
+	}
+}
+
+func TestParseBracketFields(t *testing.T) {
+	fields := parseBracketFields("Ver[1246-N] Elapsed[1234] MGHS[28.1 28.3 28.0] FanR[94%] Empty[]")
+	for k, want := range map[string]string{"Ver": "1246-N", "Elapsed": "1234", "MGHS": "28.1 28.3 28.0", "FanR": "94%", "Empty": ""} {
+		if got, ok := fields[k]; !ok || got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+}
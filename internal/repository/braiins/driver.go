@@ -0,0 +1,130 @@
+// Package braiins reads miners running Braiins OS, whose BOSminer exposes a
+// cgminer-compatible API on port 4028 with extra fans/temps commands.
+package braiins
+
+import (
+	"context"
+
+	"github.com/beatyman/scan-miners/internal/domain/model"
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+	"github.com/beatyman/scan-miners/internal/repository/cgminer"
+)
+
+type driver struct {
+	client *cgminer.Client
+}
+
+func NewDriver(client *cgminer.Client) repository.MinerDriver {
+	return &driver{client: client}
+}
+
+func (d *driver) Name() string {
+	return model.DriverBraiins
+}
+
+func (d *driver) Detect(ctx context.Context, ip string) (bool, error) {
+	version, err := d.version(ctx, ip)
+	if err != nil {
+		return false, err
+	}
+	return cgminer.FirstStr(version, "BOSminer", "BOSer") != "", nil
+}
+
+func (d *driver) FetchInfo(ctx context.Context, ip string) (*model.DeviceInfo, error) {
+	version, err := d.version(ctx, ip)
+	if err != nil {
+		return nil, err
+	}
+	return &model.DeviceInfo{
+		Driver:   model.DriverBraiins,
+		Model:    d.model(ctx, ip),
+		Firmware: cgminer.FirstStr(version, "BOSer", "BOSminer"),
+	}, nil
+}
+
+func (d *driver) FetchStats(ctx context.Context, ip string) (*model.MinerStats, error) {
+	body, err := d.client.Command(ctx, ip, "summary")
+	if err != nil {
+		return nil, err
+	}
+	summary, err := cgminer.DecodeSection(body, "SUMMARY")
+	if err != nil {
+		return nil, err
+	}
+
+	ms := &model.MinerStats{
+		Source:    model.DriverBraiins,
+		MinerType: d.model(ctx, ip),
+		Elapsed:   int64(cgminer.Num(summary["Elapsed"])),
+		Rate5s:    cgminer.GHS(summary, "5s"),
+		Rate30m:   cgminer.GHS(summary, "15m"), // BOSminer has no 30 minute window
+		RateAvg:   cgminer.GHS(summary, "av"),
+		RateUnit:  "GH/s",
+		HwpTotal:  cgminer.Num(summary["Device Hardware%"]),
+	}
+	if version, err := d.version(ctx, ip); err == nil {
+		ms.MinerVersion = cgminer.FirstStr(version, "BOSer", "BOSminer")
+	}
+
+	body, err = d.client.Command(ctx, ip, "devs")
+	if err != nil {
+		return nil, err
+	}
+	ms.Chains = cgminer.DevsToChains(body)
+	if devs, err := cgminer.DecodeList(body, "DEVS"); err == nil {
+		for i := range devs {
+			if i < len(ms.Chains) {
+				ms.Chains[i].RateIdeal = cgminer.Num(devs[i]["Nominal MHS"]) / 1000
+				ms.RateIdeal += ms.Chains[i].RateIdeal
+			}
+		}
+	}
+
+	// temps lists one board and one chip sensor per hashboard, in devs order
+	if body, err := d.client.Command(ctx, ip, "temps"); err == nil {
+		if temps, err := cgminer.DecodeList(body, "TEMPS"); err == nil {
+			for i := range temps {
+				if i < len(ms.Chains) {
+					ms.Chains[i].SetTemperatures(nil, cgminer.Temps(temps[i]["Board"]), cgminer.Temps(temps[i]["Chip"]))
+				}
+			}
+		}
+	}
+
+	if body, err := d.client.Command(ctx, ip, "fans"); err == nil {
+		if fans, err := cgminer.DecodeList(body, "FANS"); err == nil {
+			ms.FanNum = len(fans)
+			var rpms []float64
+			for _, fan := range fans {
+				rpms = append(rpms, cgminer.Num(fan["RPM"]))
+			}
+			ms.SetFanSpeeds(rpms)
+		}
+	}
+
+	if cgminer.IsEmpty(ms) {
+		return nil, cgminer.ErrNoStats
+	}
+	return ms, nil
+}
+
+func (d *driver) version(ctx context.Context, ip string) (map[string]interface{}, error) {
+	body, err := d.client.Command(ctx, ip, "version")
+	if err != nil {
+		return nil, err
+	}
+	return cgminer.DecodeSection(body, "VERSION")
+}
+
+// model reads the hardware model from devdetails; empty when unavailable
+func (d *driver) model(ctx context.Context, ip string) string {
+	body, err := d.client.Command(ctx, ip, "devdetails")
+	if err != nil {
+		return ""
+	}
+	details, err := cgminer.DecodeSection(body, "DEVDETAILS")
+	if err != nil {
+		return ""
+	}
+	return cgminer.Str(details["Model"])
+}
@@ -0,0 +1,69 @@
+package braiins
+
+import (
+	"context"
+	"testing"
+
+	"github.com/beatyman/scan-miners/internal/domain/model"
+	"github.com/beatyman/scan-miners/internal/repository/cgminer"
+	"github.com/beatyman/scan-miners/pkg/transcript"
+)
+
+// replay returns a context that answers BOSminer commands from the given raw replies
+func replay(responses map[string]string) context.Context {
+	ctx, tr := transcript.Record(context.Background())
+	for command, raw := range responses {
+		tr.Add(command, []byte(raw))
+	}
+	return transcript.Replay(ctx, tr)
+}
+
+// Replies of an S19j Pro on Braiins OS+ 23.03, rates in MH/s
+var bosReplies = map[string]string{
+	"summary":    `{"STATUS":[{"STATUS":"S","Msg":"Summary","Description":"BOSminer bosminer-plus-tuner 0.2.0"}],"SUMMARY":[{"Elapsed":5400,"MHS av":99612345.1,"MHS 5s":100103000.5,"MHS 15m":99800000.0,"Device Hardware%":0.0}],"id":1}`,
+	"version":    `{"STATUS":[{"STATUS":"S","Msg":"BOSminer versions"}],"VERSION":[{"BOSminer":"bosminer-plus-tuner 0.2.0-9dd3a1b4d","API":"3.7","BOSer":"boser-buildroot 0.1.0"}],"id":1}`,
+	"devdetails": `{"STATUS":[{"STATUS":"S","Msg":"Device Details"}],"DEVDETAILS":[{"DEVDETAILS":0,"Name":"Hashchain","ID":0,"Model":"Antminer S19j Pro"}],"id":1}`,
+	"devs": `{"STATUS":[{"STATUS":"S","Msg":"3 ASC(s)"}],"DEVS":[` +
+		`{"ASC":0,"Name":"Hashchain","ID":6,"MHS 5s":33400000.0,"Nominal MHS":34000000.0,"Hardware Errors":5,"Device Hardware%":0.01},` +
+		`{"ASC":1,"Name":"Hashchain","ID":7,"MHS 5s":33300000.0,"Nominal MHS":34000000.0,"Hardware Errors":0,"Device Hardware%":0.0},` +
+		`{"ASC":2,"Name":"Hashchain","ID":8,"MHS 5s":33403000.5,"Nominal MHS":34000000.0,"Hardware Errors":1,"Device Hardware%":0.0}],"id":1}`,
+	"temps": `{"STATUS":[{"STATUS":"S","Msg":"3 Temp(s)"}],"TEMPS":[{"TEMPS":0,"ID":6,"Board":55.0,"Chip":71.5},{"TEMPS":1,"ID":7,"Board":56.0,"Chip":73.0},{"TEMPS":2,"ID":8,"Board":54.5,"Chip":70.0}],"id":1}`,
+	"fans":  `{"STATUS":[{"STATUS":"S","Msg":"4 Fan(s)"}],"FANS":[{"FANS":0,"ID":0,"RPM":4020,"Speed":60},{"FANS":1,"ID":1,"RPM":4080,"Speed":60},{"FANS":2,"ID":2,"RPM":3990,"Speed":60},{"FANS":3,"ID":3,"RPM":4050,"Speed":60}],"id":1}`,
+}
+
+func TestFetchStats(t *testing.T) {
+	ms, err := NewDriver(cgminer.NewClient(4028, 0, 0)).FetchStats(replay(bosReplies), "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ms.Source != model.DriverBraiins || ms.MinerType != "Antminer S19j Pro" || ms.MinerVersion != "boser-buildroot 0.1.0" {
+		t.Errorf("source %q, type %q, version %q", ms.Source, ms.MinerType, ms.MinerVersion)
+	}
+	if ms.Elapsed != 5400 || ms.Rate30m != 99800 || ms.RateIdeal != 102000 {
+		t.Errorf("unexpected rates: %+v", ms)
+	}
+	if ms.FanNum != 4 || ms.FanSpeeds != "4020,4080,3990,4050" {
+		t.Errorf("fans = %q of %d", ms.FanSpeeds, ms.FanNum)
+	}
+	if len(ms.Chains) != 3 {
+		t.Fatalf("%d chains, want 3", len(ms.Chains))
+	}
+	c := ms.Chains[1]
+	if c.ChainIndex != 7 || c.RateReal != 33300 || c.RateIdeal != 34000 || c.Hw != 0 {
+		t.Errorf("unexpected second chain: %+v", c)
+	}
+	if c.TempPcbMax != 56 || c.TempChipMax != 73 {
+		t.Errorf("second chain board %v, chip %v, want 56 and 73", c.TempPcbMax, c.TempChipMax)
+	}
+}
+
+func TestFetchStatsWithoutTemps(t *testing.T) {
+	replies := map[string]string{"summary": bosReplies["summary"], "devs": bosReplies["devs"]}
+	ms, err := NewDriver(cgminer.NewClient(4028, 0, 0)).FetchStats(replay(replies), "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms.Chains) != 3 || ms.Chains[0].TempChipMax != 0 || ms.MinerType != "" {
+		t.Errorf("unexpected snapshot without the optional commands: %+v", ms)
+	}
+}
@@ -0,0 +1,186 @@
+// Package cgminer speaks the JSON-over-TCP API that cgminer, bmminer and
+// most of their forks expose on port 4028.
+package cgminer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+	"github.com/beatyman/scan-miners/pkg/transcript"
+)
+
+// maxResponseSize guards against a miner that never stops talking
+const maxResponseSize = 4 << 20
+
+type Client struct {
+	port           int
+	connectTimeout time.Duration
+	readTimeout    time.Duration
+}
+
+// NewClient gives each connection connectTimeout to be established and each
+// command readTimeout to be answered once connected
+func NewClient(port int, connectTimeout, readTimeout time.Duration) *Client {
+	return &Client{port: port, connectTimeout: connectTimeout, readTimeout: readTimeout}
+}
+
+// APIError is a STATUS block with status "E" (error) or "F" (fatal)
+type APIError struct {
+	Command string
+	Code    int
+	Msg     string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("cgminer %s: %s (code %d)", e.Command, e.Msg, e.Code)
+}
+
+func (e *APIError) Unwrap() error {
+	return repository.ErrMinerBadStatus
+}
+
+type statusBlock struct {
+	Status string          `json:"STATUS"`
+	Code   int             `json:"Code"`
+	Msg    json.RawMessage `json:"Msg"`
+}
+
+// Command sends one API command and returns the sanitized JSON response.
+// A fresh connection is used per command: the API closes it after answering.
+func (c *Client) Command(ctx context.Context, ip, command string) ([]byte, error) {
+	t := transcript.FromContext(ctx)
+	if t.Replaying() {
+		raw, err := t.Get(command)
+		if err != nil {
+			return nil, fmt.Errorf("cgminer %s: %w", command, err)
+		}
+		return parseResponse(command, raw)
+	}
+
+	dialer := net.Dialer{Timeout: c.connectTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip, strconv.Itoa(c.port)))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(c.readTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	req, _ := json.Marshal(map[string]string{"command": command})
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+
+	raw, err := readResponse(conn)
+	if err != nil {
+		return nil, fmt.Errorf("cgminer %s: %w", command, err)
+	}
+	t.Add(command, raw)
+
+	return parseResponse(command, raw)
+}
+
+func parseResponse(command string, raw []byte) ([]byte, error) {
+	body := Sanitize(raw)
+	if err := checkStatus(command, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// readResponse reads until the terminating NUL byte or until the miner closes
+// the connection; some forks do one, some the other
+func readResponse(r io.Reader) ([]byte, error) {
+	var buf bytes.Buffer
+	chunk := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(chunk)
+		if n > 0 {
+			if i := bytes.IndexByte(chunk[:n], 0); i >= 0 {
+				buf.Write(chunk[:i])
+				return buf.Bytes(), nil
+			}
+			buf.Write(chunk[:n])
+			if buf.Len() > maxResponseSize {
+				return nil, fmt.Errorf("response larger than %d bytes", maxResponseSize)
+			}
+		}
+		if err == io.EOF {
+			return buf.Bytes(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+var (
+	// bmminer glues objects together: {"STATS":0,...}{"STATS":1,...}
+	reGluedObjects = regexp.MustCompile(`}\s*{`)
+	// Trailing or leading commas inside objects and arrays
+	reTrailingComma = regexp.MustCompile(`,\s*([}\]])`)
+	reLeadingComma  = regexp.MustCompile(`([\[{])\s*,`)
+	// Unquoted nan/inf emitted by printf for broken sensors
+	reNonFinite = regexp.MustCompile(`:\s*-?(?i:nan|inf)\b`)
+)
+
+// Sanitize repairs the known malformed-JSON quirks of cgminer forks so the
+// result can be fed to encoding/json
+func Sanitize(raw []byte) []byte {
+	body := bytes.TrimRight(raw, "\x00 \r\n\t")
+	body = bytes.ReplaceAll(body, []byte{0}, nil)
+	body = reGluedObjects.ReplaceAll(body, []byte("},{"))
+	body = reTrailingComma.ReplaceAll(body, []byte("$1"))
+	body = reLeadingComma.ReplaceAll(body, []byte("$1"))
+	body = reNonFinite.ReplaceAll(body, []byte(":0"))
+	return body
+}
+
+// checkStatus accepts both the cgminer form {"STATUS":[{...}]} and the flat
+// {"STATUS":"S","Msg":{...}} form newer btminer commands use
+func checkStatus(command string, body []byte) error {
+	var envelope struct {
+		Status json.RawMessage `json:"STATUS"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return fmt.Errorf("cgminer %s: bad JSON: %w", command, err)
+	}
+
+	var s statusBlock
+	switch {
+	case bytes.HasPrefix(envelope.Status, []byte("[")):
+		var blocks []statusBlock
+		if err := json.Unmarshal(envelope.Status, &blocks); err != nil || len(blocks) == 0 {
+			return nil
+		}
+		s = blocks[0]
+	case bytes.HasPrefix(envelope.Status, []byte(`"`)):
+		if err := json.Unmarshal(body, &s); err != nil {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	if s.Status == "E" || s.Status == "F" {
+		msg := string(s.Msg)
+		var text string
+		if json.Unmarshal(s.Msg, &text) == nil {
+			msg = text
+		}
+		return &APIError{Command: command, Code: s.Code, Msg: msg}
+	}
+	return nil
+}
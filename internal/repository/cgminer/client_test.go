@@ -0,0 +1,128 @@
+package cgminer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+)
+
+// chunkReader hands out its data a few bytes per Read, like a slow socket
+type chunkReader struct {
+	data []byte
+	size int
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p[:min(len(p), r.size)], r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestReadResponse(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"terminated by NUL", `{"STATUS":[{"STATUS":"S"}]}` + "\x00", `{"STATUS":[{"STATUS":"S"}]}`},
+		{"bytes after NUL ignored", `{"a":1}` + "\x00garbage", `{"a":1}`},
+		{"closed at EOF", `{"STATUS":[{"STATUS":"S"}]}`, `{"STATUS":[{"STATUS":"S"}]}`},
+		{"empty", ``, ``},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readResponse(&chunkReader{data: []byte(tt.raw), size: 5})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("readResponse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadResponseTooLarge(t *testing.T) {
+	r := bytes.NewReader(bytes.Repeat([]byte("x"), maxResponseSize+64*1024))
+	if _, err := readResponse(r); err == nil {
+		t.Fatal("readResponse() accepted a reply above maxResponseSize")
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"valid untouched", `{"STATS":[{"a":1}]}`, `{"STATS":[{"a":1}]}`},
+		{"trailing NUL and newline", "{\"a\":1}\x00\n", `{"a":1}`},
+		{"glued objects", `{"STATS":[{"STATS":0,"ID":"BMM0"}{"STATS":1,"Elapsed":5}]}`, `{"STATS":[{"STATS":0,"ID":"BMM0"},{"STATS":1,"Elapsed":5}]}`},
+		{"glued objects with space", "{\"STATS\":[{\"a\":1} \n{\"b\":2}]}", `{"STATS":[{"a":1},{"b":2}]}`},
+		{"trailing commas", `{"STATS":[{"a":1,},],"id":1,}`, `{"STATS":[{"a":1}],"id":1}`},
+		{"stray leading commas", `{"DEVS":[,{,"a":1}]}`, `{"DEVS":[{"a":1}]}`},
+		{"nan and inf", `{"temp":nan,"rate":-inf,"hw":INF,"x":1}`, `{"temp":0,"rate":0,"hw":0,"x":1}`},
+		{"nan inside a string kept", `{"Type":"nano"}`, `{"Type":"nano"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Sanitize([]byte(tt.raw))
+			if string(got) != tt.want {
+				t.Errorf("Sanitize() = %s, want %s", got, tt.want)
+			}
+			if !json.Valid(got) {
+				t.Errorf("Sanitize() = %s, not valid JSON", got)
+			}
+		})
+	}
+}
+
+func TestCheckStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr bool
+		wantMsg string
+	}{
+		{"success", `{"STATUS":[{"STATUS":"S","Code":70,"Msg":"CGMiner stats"}],"STATS":[]}`, false, ""},
+		{"error", `{"STATUS":[{"STATUS":"E","Code":14,"Msg":"Invalid command"}],"id":1}`, true, "Invalid command"},
+		{"fatal", `{"STATUS":[{"STATUS":"F","Code":45,"Msg":"Access denied"}]}`, true, "Access denied"},
+		{"flat success", `{"STATUS":"S","Code":131,"Msg":{"api_ver":"2.0.5"}}`, false, ""},
+		{"flat error", `{"STATUS":"E","Code":132,"Msg":"invalid cmd"}`, true, "invalid cmd"},
+		{"no status block", `{"STATS":[{"a":1}]}`, false, ""},
+		{"empty status list", `{"STATUS":[]}`, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkStatus("stats", []byte(tt.body))
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("checkStatus() = %v, want nil", err)
+				}
+				return
+			}
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.Msg != tt.wantMsg || apiErr.Command != "stats" {
+				t.Fatalf("checkStatus() = %v, want an APIError with %q", err, tt.wantMsg)
+			}
+			if !errors.Is(err, repository.ErrMinerBadStatus) {
+				t.Errorf("checkStatus() = %v, not an ErrMinerBadStatus", err)
+			}
+		})
+	}
+}
+
+func TestCheckStatusBadJSON(t *testing.T) {
+	err := checkStatus("summary", []byte(`{"STATUS":`))
+	if err == nil || !strings.Contains(err.Error(), "bad JSON") {
+		t.Fatalf("checkStatus() = %v, want a bad JSON error", err)
+	}
+}
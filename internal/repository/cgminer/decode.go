@@ -0,0 +1,117 @@
+package cgminer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DecodeList returns the objects of the named top-level array ("STATS", "DEVS", ...)
+func DecodeList(body []byte, section string) ([]map[string]interface{}, error) {
+	var envelope map[string]json.RawMessage
+	if err := decode(body, &envelope); err != nil {
+		return nil, fmt.Errorf("cgminer: bad JSON: %w", err)
+	}
+	var items []map[string]interface{}
+	if raw, ok := envelope[section]; ok {
+		if err := decode(raw, &items); err != nil {
+			return nil, fmt.Errorf("cgminer: bad %s: %w", section, err)
+		}
+	}
+	return items, nil
+}
+
+// DecodeSection merges every object of the named array into one map; bmminer
+// splits version info and counters over two STATS objects
+func DecodeSection(body []byte, section string) (map[string]interface{}, error) {
+	items, err := DecodeList(body, section)
+	if err != nil {
+		return nil, err
+	}
+	merged := make(map[string]interface{})
+	for _, item := range items {
+		for k, v := range item {
+			merged[k] = v
+		}
+	}
+	return merged, nil
+}
+
+func decode(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// GHS reads "GHS <window>", falling back to "MHS <window>" converted to GH/s
+func GHS(m map[string]interface{}, window string) float64 {
+	if v, ok := m["GHS "+window]; ok {
+		return Num(v)
+	}
+	if v, ok := m["MHS "+window]; ok {
+		return Num(v) / 1000
+	}
+	return 0
+}
+
+// Num accepts numbers and numeric strings, as forks disagree on which to send
+func Num(v interface{}) float64 {
+	switch t := v.(type) {
+	case json.Number:
+		f, _ := t.Float64()
+		return f
+	case string:
+		// Some forks format with thousands separators: "13,500.12"
+		f, _ := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(t), ",", ""), 64)
+		return f
+	case bool:
+		if t {
+			return 1
+		}
+	}
+	return 0
+}
+
+func Str(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	}
+	return ""
+}
+
+// FirstStr returns the first non-empty string among keys
+func FirstStr(m map[string]interface{}, keys ...string) string {
+	for _, k := range keys {
+		if s := Str(m[k]); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// Temps reads a sensor array sent as a JSON array, a single number or a
+// "44-44-62-62" string; nil when the key is absent
+func Temps(v interface{}) []float64 {
+	switch t := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		temps := make([]float64, 0, len(t))
+		for _, item := range t {
+			temps = append(temps, Num(item))
+		}
+		return temps
+	case string:
+		var temps []float64
+		for _, f := range strings.FieldsFunc(t, func(r rune) bool { return r == '-' || r == ',' || r == ' ' }) {
+			temps = append(temps, Num(f))
+		}
+		return temps
+	}
+	return []float64{Num(v)}
+}
@@ -0,0 +1,54 @@
+package cgminer
+
+import (
+	"context"
+
+	"github.com/beatyman/scan-miners/internal/domain/model"
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+)
+
+// driver is the catch-all for cgminer and bmminer forks without a dedicated
+// driver; it should be tried after the vendor-specific ones
+type driver struct {
+	client *Client
+}
+
+func NewDriver(client *Client) repository.MinerDriver {
+	return &driver{client: client}
+}
+
+func (d *driver) Name() string {
+	return model.DriverCGMiner
+}
+
+func (d *driver) Detect(ctx context.Context, ip string) (bool, error) {
+	body, err := d.client.Command(ctx, ip, "version")
+	if err != nil {
+		return false, err
+	}
+	version, err := DecodeSection(body, "VERSION")
+	if err != nil {
+		return false, err
+	}
+	return FirstStr(version, "CGMiner", "BMMiner") != "", nil
+}
+
+func (d *driver) FetchInfo(ctx context.Context, ip string) (*model.DeviceInfo, error) {
+	body, err := d.client.Command(ctx, ip, "version")
+	if err != nil {
+		return nil, err
+	}
+	version, err := DecodeSection(body, "VERSION")
+	if err != nil {
+		return nil, err
+	}
+	return &model.DeviceInfo{
+		Driver:   model.DriverCGMiner,
+		Model:    FirstStr(version, "Type", "PROD"),
+		Firmware: FirstStr(version, "Miner", "BMMiner", "CGMiner"),
+	}, nil
+}
+
+func (d *driver) FetchStats(ctx context.Context, ip string) (*model.MinerStats, error) {
+	return d.client.FetchStats(ctx, ip)
+}
@@ -0,0 +1,213 @@
+package cgminer
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/beatyman/scan-miners/internal/domain/model"
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+)
+
+// ErrNoStats is returned when neither stats, summary nor devs carry hashrate data
+var ErrNoStats = fmt.Errorf("cgminer: no stats data found: %w", repository.ErrMinerNoStats)
+
+// FetchStats collects "stats", "summary" and "pools" (plus "devs" when stats
+// has no per-chain keys) and flattens them into the same snapshot the CGI API gives
+func (c *Client) FetchStats(ctx context.Context, ip string) (*model.MinerStats, error) {
+	body, err := c.Command(ctx, ip, "stats")
+	if err != nil {
+		return nil, err
+	}
+	stats, err := DecodeSection(body, "STATS")
+	if err != nil {
+		return nil, err
+	}
+
+	// summary is authoritative for totals on forks whose stats lack them; it is optional
+	var summary map[string]interface{}
+	if body, err := c.Command(ctx, ip, "summary"); err == nil {
+		summary, _ = DecodeSection(body, "SUMMARY")
+	}
+
+	minerStats := ToMinerStats(stats, summary)
+
+	// pools tells which pool and user the miner actually mines on; it is optional as well
+	if body, err := c.Command(ctx, ip, "pools"); err == nil {
+		minerStats.PoolURL, minerStats.PoolUser = ActivePool(body)
+	}
+
+	if len(minerStats.Chains) == 0 {
+		if body, err := c.Command(ctx, ip, "devs"); err == nil {
+			minerStats.Chains = DevsToChains(body)
+		}
+	}
+
+	if IsEmpty(minerStats) {
+		return nil, fmt.Errorf("%s: %w", ip, ErrNoStats)
+	}
+	return minerStats, nil
+}
+
+// IsEmpty reports whether a snapshot carries no hashrate data at all
+func IsEmpty(ms *model.MinerStats) bool {
+	return ms.Elapsed == 0 && ms.RateAvg == 0 && ms.Rate5s == 0 && len(ms.Chains) == 0
+}
+
+var reChainACN = regexp.MustCompile(`^chain_acn(\d+)$`)
+
+// ToMinerStats maps merged STATS and SUMMARY objects onto MinerStats.
+// Rates are reported in GH/s ("GHS ..."), or MH/s on older forks.
+func ToMinerStats(stats, summary map[string]interface{}) *model.MinerStats {
+	ms := &model.MinerStats{
+		Source:       model.DriverCGMiner,
+		MinerType:    Str(stats["Type"]),
+		MinerVersion: FirstStr(stats, "BMMiner", "CGMiner", "Miner"),
+		CompileTime:  Str(stats["CompileTime"]),
+		Elapsed:      int64(Num(stats["Elapsed"])),
+		Rate5s:       GHS(stats, "5s"),
+		Rate30m:      GHS(stats, "30m"),
+		RateAvg:      GHS(stats, "av"),
+		RateIdeal:    Num(stats["total_rateideal"]),
+		RateUnit:     "GH/s",
+		FanNum:       int(Num(stats["fan_num"])),
+		HwpTotal:     Num(stats["Device Hardware%"]),
+		MinerMode:    int(Num(stats["miner-mode"])),
+		FreqLevel:    int(Num(stats["freq-level"])),
+	}
+	ms.SetFanSpeeds(fanSpeeds(stats))
+
+	if summary != nil {
+		if ms.Elapsed == 0 {
+			ms.Elapsed = int64(Num(summary["Elapsed"]))
+		}
+		if ms.Rate5s == 0 {
+			ms.Rate5s = GHS(summary, "5s")
+		}
+		if ms.Rate30m == 0 {
+			ms.Rate30m = GHS(summary, "30m")
+		}
+		if ms.RateAvg == 0 {
+			ms.RateAvg = GHS(summary, "av")
+		}
+		if ms.HwpTotal == 0 {
+			ms.HwpTotal = Num(summary["Device Hardware%"])
+		}
+	}
+
+	// Chains are flattened into numbered keys: chain_acn1, chain_rate1, freq_avg1, ...
+	var slots []int
+	for k := range stats {
+		if m := reChainACN.FindStringSubmatch(k); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			// S9-era firmware lists all 16 slots, empty ones with zero chips
+			if Num(stats[k]) > 0 {
+				slots = append(slots, n)
+			}
+		}
+	}
+	sort.Ints(slots)
+
+	for _, n := range slots {
+		s := strconv.Itoa(n)
+		chain := model.MinerChain{
+			// Keys are 1-based, the CGI API's chain index is 0-based
+			ChainIndex: n - 1,
+			FreqAvg:    int(Num(stats["freq_avg"+s])),
+			RateIdeal:  Num(stats["chain_rateideal"+s]),
+			RateReal:   Num(stats["chain_rate"+s]),
+			AsicNum:    int(Num(stats["chain_acn"+s])),
+			Hw:         int(Num(stats["chain_hw"+s])),
+		}
+
+		// Newer bmminer: "temp_pcb1":"44-44-62-62"; S9-era: "temp6" (PCB) and "temp2_6" (chip)
+		pcb := Temps(stats["temp_pcb"+s])
+		if pcb == nil {
+			pcb = Temps(stats["temp"+s])
+		}
+		chip := Temps(stats["temp_chip"+s])
+		if chip == nil {
+			chip = Temps(stats["temp2_"+s])
+		}
+		chain.SetTemperatures(Temps(stats["temp_pic"+s]), pcb, chip)
+		chain.SetAsicStatus(Str(stats["chain_acs"+s]))
+
+		ms.Chains = append(ms.Chains, chain)
+	}
+
+	return ms
+}
+
+var reFanKey = regexp.MustCompile(`^fan(\d+)$`)
+
+// fanSpeeds returns the fanN readings in fan order. S9-era firmware lists
+// all 8 fan ports with 0 for unused ones, so only spinning fans are kept;
+// a dead fan still shows up as fan_num above their count.
+func fanSpeeds(stats map[string]interface{}) []float64 {
+	var ports []int
+	for k := range stats {
+		if m := reFanKey.FindStringSubmatch(k); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			ports = append(ports, n)
+		}
+	}
+	sort.Ints(ports)
+
+	var rpms []float64
+	for _, n := range ports {
+		if rpm := Num(stats["fan"+strconv.Itoa(n)]); rpm > 0 {
+			rpms = append(rpms, rpm)
+		}
+	}
+	return rpms
+}
+
+// ActivePool returns the URL and user of the pool in use from a POOLS reply:
+// the one flagged "Stratum Active", else the alive pool of best priority
+func ActivePool(body []byte) (url, user string) {
+	pools, err := DecodeList(body, "POOLS")
+	if err != nil {
+		return "", ""
+	}
+	var best map[string]interface{}
+	for _, pool := range pools {
+		if b, ok := pool["Stratum Active"].(bool); ok && b {
+			best = pool
+			break
+		}
+		if Str(pool["Status"]) != "Alive" {
+			continue
+		}
+		if best == nil || Num(pool["Priority"]) < Num(best["Priority"]) {
+			best = pool
+		}
+	}
+	if best == nil {
+		return "", ""
+	}
+	return Str(best["URL"]), Str(best["User"])
+}
+
+// DevsToChains maps the DEVS list of generic cgminer forks, one entry per board
+func DevsToChains(body []byte) []model.MinerChain {
+	devs, err := DecodeList(body, "DEVS")
+	if err != nil {
+		return nil
+	}
+	var chains []model.MinerChain
+	for i, dev := range devs {
+		index := i
+		if v, ok := dev["ID"]; ok {
+			index = int(Num(v))
+		}
+		chains = append(chains, model.MinerChain{
+			ChainIndex: index,
+			RateReal:   GHS(dev, "5s"),
+			Hw:         int(Num(dev["Hardware Errors"])),
+			Hwp:        Num(dev["Device Hardware%"]),
+		})
+	}
+	return chains
+}
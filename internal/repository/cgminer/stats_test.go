@@ -0,0 +1,96 @@
+package cgminer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+	"github.com/beatyman/scan-miners/pkg/transcript"
+)
+
+// replay returns a context that answers cgminer commands from the given raw replies
+func replay(responses map[string]string) context.Context {
+	ctx, tr := transcript.Record(context.Background())
+	for command, raw := range responses {
+		tr.Add(command, []byte(raw))
+	}
+	return transcript.Replay(ctx, tr)
+}
+
+// An S9 on bmminer 2.0: glued STATS objects, a trailing comma and NUL terminators
+const s9Stats = `{"STATUS":[{"STATUS":"S","When":1700000000,"Code":70,"Msg":"CGMiner stats","Description":"cgminer 4.9.0"}],"STATS":[{"CGMiner":"4.9.0","Miner":"16.8.1.3","CompileTime":"Fri Nov 17 17:37:49 CST 2017","Type":"Antminer S9"}{"STATS":0,"ID":"BC50","Elapsed":3600,"GHS 5s":"13,512.34","GHS av":13498.5,"total_rateideal":13500,"fan_num":2,"fan3":5640,"fan6":5880,"fan1":0,"temp6":62,"temp2_6":78,"chain_acn6":63,"chain_rate6":"4,510.10","chain_rateideal6":4500,"freq_avg6":650,"chain_hw6":12,"chain_acs6":" oooooooo oooooooo","temp7":60,"temp2_7":nan,"chain_acn7":63,"chain_rate7":"4,495.02","chain_acn8":0,},]}` + "\x00"
+
+const s9Pools = `{"STATUS":[{"STATUS":"S","Code":7,"Msg":"3 Pool(s)"}],"POOLS":[{"POOL":0,"URL":"stratum+tcp://btc.ss.poolin.com:443","Status":"Alive","Priority":0,"Stratum Active":false,"User":"backup.1x1"},{"POOL":1,"URL":"stratum+tcp://ss.antpool.com:3333","Status":"Alive","Priority":1,"Stratum Active":true,"User":"observer.1x1"},{"POOL":2,"URL":"stratum+tcp://dead:3333","Status":"Dead","Priority":2,"Stratum Active":false,"User":"x"}]}` + "\x00"
+
+func TestFetchStats(t *testing.T) {
+	ctx := replay(map[string]string{"stats": s9Stats, "pools": s9Pools})
+	ms, err := NewClient(4028, 0, 0).FetchStats(ctx, "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ms.MinerType != "Antminer S9" || ms.Elapsed != 3600 || ms.Rate5s != 13512.34 || ms.RateAvg != 13498.5 {
+		t.Errorf("unexpected totals: %+v", ms)
+	}
+	if ms.FanSpeeds != "5640,5880" || ms.FanNum != 2 {
+		t.Errorf("fans = %q of %d, want the two spinning fans", ms.FanSpeeds, ms.FanNum)
+	}
+	if ms.PoolURL != "stratum+tcp://ss.antpool.com:3333" || ms.PoolUser != "observer.1x1" {
+		t.Errorf("pool = %q %q, want the stratum active pool", ms.PoolURL, ms.PoolUser)
+	}
+	if len(ms.Chains) != 2 {
+		t.Fatalf("%d chains, want the two populated slots", len(ms.Chains))
+	}
+	c := ms.Chains[0]
+	if c.ChainIndex != 5 || c.RateReal != 4510.10 || c.AsicNum != 63 || c.Hw != 12 || c.FreqAvg != 650 {
+		t.Errorf("unexpected first chain: %+v", c)
+	}
+	if c.TempPcbMax != 62 || c.TempChipMax != 78 || c.AsicOK != 16 {
+		t.Errorf("first chain: pcb %v, chip %v, %d chips ok", c.TempPcbMax, c.TempChipMax, c.AsicOK)
+	}
+	if c := ms.Chains[1]; c.ChainIndex != 6 || c.TempPcbMax != 60 || c.TempChipMax != 0 {
+		t.Errorf("second chain with a broken chip sensor: %+v", c)
+	}
+}
+
+func TestFetchStatsWithoutOptionalCommands(t *testing.T) {
+	// summary and pools were never answered: the snapshot still comes from stats
+	ctx := replay(map[string]string{"stats": s9Stats})
+	ms, err := NewClient(4028, 0, 0).FetchStats(ctx, "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ms.PoolURL != "" || len(ms.Chains) != 2 {
+		t.Errorf("unexpected snapshot: pool %q, %d chains", ms.PoolURL, len(ms.Chains))
+	}
+}
+
+func TestFetchStatsEmpty(t *testing.T) {
+	ctx := replay(map[string]string{"stats": `{"STATUS":[{"STATUS":"S"}],"STATS":[{"Type":"Antminer S9"}]}`})
+	_, err := NewClient(4028, 0, 0).FetchStats(ctx, "10.0.0.1")
+	if !errors.Is(err, repository.ErrMinerNoStats) {
+		t.Fatalf("FetchStats() = %v, want ErrMinerNoStats", err)
+	}
+}
+
+func TestActivePool(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		wantURL  string
+		wantUser string
+	}{
+		{"stratum active", s9Pools, "stratum+tcp://ss.antpool.com:3333", "observer.1x1"},
+		{"best alive priority", `{"POOLS":[{"URL":"a","Status":"Dead","Priority":0,"User":"u0"},{"URL":"b","Status":"Alive","Priority":2,"User":"u2"},{"URL":"c","Status":"Alive","Priority":1,"User":"u1"}]}`, "c", "u1"},
+		{"none alive", `{"POOLS":[{"URL":"a","Status":"Dead","User":"u"}]}`, "", ""},
+		{"no pools", `{"STATUS":[{"STATUS":"S"}]}`, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url, user := ActivePool(Sanitize([]byte(tt.body)))
+			if url != tt.wantURL || user != tt.wantUser {
+				t.Errorf("ActivePool() = %q, %q, want %q, %q", url, user, tt.wantURL, tt.wantUser)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package mysql
+
+import (
+	"context"
+
+	"github.com/beatyman/scan-miners/internal/domain/model"
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type chipLayoutRepository struct {
+	db *gorm.DB
+}
+
+func NewChipLayoutRepository(db *gorm.DB) repository.ChipLayoutRepository {
+	return &chipLayoutRepository{db: db}
+}
+
+func (r *chipLayoutRepository) Save(ctx context.Context, layout *model.ChipLayout) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "miner_type"}},
+		DoUpdates: clause.AssignmentColumns([]string{"num_rows", "num_cols", "layout", "updated_at"}),
+	}).Create(layout).Error
+}
+
+func (r *chipLayoutRepository) FindByMinerType(ctx context.Context, minerType string) (*model.ChipLayout, error) {
+	var layout model.ChipLayout
+	err := r.db.WithContext(ctx).Where("miner_type = ?", minerType).Limit(1).Find(&layout).Error
+	if err != nil {
+		return nil, err
+	}
+	if layout.ID == 0 {
+		return nil, nil
+	}
+	return &layout, nil
+}
@@ -0,0 +1,28 @@
+package mysql
+
+import (
+	"context"
+
+	"github.com/beatyman/scan-miners/internal/domain/model"
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type discoveredDeviceRepository struct {
+	db *gorm.DB
+}
+
+func NewDiscoveredDeviceRepository(db *gorm.DB) repository.DiscoveredDeviceRepository {
+	return &discoveredDeviceRepository{db: db}
+}
+
+func (r *discoveredDeviceRepository) Save(ctx context.Context, device *model.DiscoveredDevice) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "ip"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"driver", "miner_type", "miner_version", "auth_failed",
+			"worker_id", "observer_user_id", "last_seen_at",
+		}),
+	}).Create(device).Error
+}
@@ -0,0 +1,44 @@
+package mysql
+
+import (
+	"context"
+	"time"
+
+	"github.com/beatyman/scan-miners/internal/domain/model"
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+	"gorm.io/gorm"
+)
+
+type hashboardRepository struct {
+	db *gorm.DB
+}
+
+func NewHashboardRepository(db *gorm.DB) repository.HashboardRepository {
+	return &hashboardRepository{db: db}
+}
+
+func (r *hashboardRepository) FindBySN(ctx context.Context, sn string) (*model.Hashboard, error) {
+	var board model.Hashboard
+	err := r.db.WithContext(ctx).Where("sn = ?", sn).Limit(1).Find(&board).Error
+	if err != nil {
+		return nil, err
+	}
+	if board.ID == 0 {
+		return nil, nil
+	}
+	return &board, nil
+}
+
+func (r *hashboardRepository) Save(ctx context.Context, board *model.Hashboard) error {
+	return r.db.WithContext(ctx).Save(board).Error
+}
+
+func (r *hashboardRepository) CreateMove(ctx context.Context, move *model.HashboardMove) error {
+	return r.db.WithContext(ctx).Create(move).Error
+}
+
+func (r *hashboardRepository) FindMovesSince(ctx context.Context, since time.Time) ([]*model.HashboardMove, error) {
+	var moves []*model.HashboardMove
+	err := r.db.WithContext(ctx).Where("detected_at >= ?", since).Order("detected_at").Find(&moves).Error
+	return moves, err
+}
@@ -2,6 +2,7 @@ package mysql
 
 import (
 	"context"
+	"time"
 
 	"github.com/beatyman/scan-miners/internal/domain/model"
 	"github.com/beatyman/scan-miners/internal/domain/repository"
@@ -20,10 +21,38 @@ func (r *minerStatsRepository) Save(ctx context.Context, stats *model.MinerStats
 	return r.db.WithContext(ctx).Create(stats).Error
 }
 
-func (r *minerStatsRepository) FindLatestByWorkerID(ctx context.Context, workerID string) (*model.MinerStats, error) {
+func (r *minerStatsRepository) Replace(ctx context.Context, stats *model.MinerStats) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		chainIDs := tx.Model(&model.MinerChain{}).Select("id").Where("miner_stats_id = ?", stats.ID)
+		if err := tx.Where("miner_chain_id IN (?)", chainIDs).Delete(&model.MinerChainTemp{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("miner_stats_id = ?", stats.ID).Delete(&model.MinerChain{}).Error; err != nil {
+			return err
+		}
+		return tx.Save(stats).Error
+	})
+}
+
+func (r *minerStatsRepository) FindLatestByWorkerID(ctx context.Context, observerUserID, workerID string) (*model.MinerStats, error) {
 	var stats model.MinerStats
 	// Use Limit(1).Find to optimize query and avoid GORM's default PK ordering which might cause redundant sorting
-	err := r.db.WithContext(ctx).Where("worker_id = ?", workerID).Order("id desc").Limit(1).Find(&stats).Error
+	err := r.db.WithContext(ctx).Where("observer_user_id = ? AND worker_id = ?", observerUserID, workerID).Order("id desc").Limit(1).Find(&stats).Error
+	if err != nil {
+		return nil, err
+	}
+	if stats.ID == 0 {
+		return nil, nil
+	}
+	return &stats, nil
+}
+
+func (r *minerStatsRepository) FindLatestByIP(ctx context.Context, ip string) (*model.MinerStats, error) {
+	var stats model.MinerStats
+	err := r.db.WithContext(ctx).
+		Preload("Chains", func(db *gorm.DB) *gorm.DB { return db.Order("chain_index") }).
+		Preload("Chains.Temps").
+		Where("ip = ?", ip).Order("id desc").Limit(1).Find(&stats).Error
 	if err != nil {
 		return nil, err
 	}
@@ -32,3 +61,65 @@ func (r *minerStatsRepository) FindLatestByWorkerID(ctx context.Context, workerI
 	}
 	return &stats, nil
 }
+
+func (r *minerStatsRepository) FindHottestChains(ctx context.Context, since time.Time, limit int) ([]*model.ChainTemperature, error) {
+	latest := r.db.Model(&model.MinerStats{}).Select("MAX(id)").Where("created_at >= ?", since).Group("observer_user_id, worker_id")
+
+	var chains []*model.ChainTemperature
+	err := r.db.WithContext(ctx).Table("miner_chains AS c").
+		Select("s.worker_id, s.ip, s.miner_type, c.chain_index, c.temp_chip_max, c.temp_chip_avg, c.temp_pcb_max, c.temp_pic_max, s.created_at AS scanned_at").
+		Joins("JOIN miner_stats AS s ON s.id = c.miner_stats_id").
+		Where("s.id IN (?)", latest).
+		Order("c.temp_chip_max DESC").
+		Limit(limit).
+		Scan(&chains).Error
+	return chains, err
+}
+
+func (r *minerStatsRepository) FindEEPROMNotLoaded(ctx context.Context, since time.Time) ([]*model.ChainLocation, error) {
+	latest := r.db.Model(&model.MinerStats{}).Select("MAX(id)").Where("created_at >= ?", since).Group("observer_user_id, worker_id")
+
+	var chains []*model.ChainLocation
+	err := r.db.WithContext(ctx).Table("miner_chains AS c").
+		Select("s.worker_id, s.ip, s.miner_type, c.chain_index, c.sn, s.created_at AS scanned_at").
+		Joins("JOIN miner_stats AS s ON s.id = c.miner_stats_id").
+		Where("s.id IN (?) AND c.eeprom_loaded = ?", latest, false).
+		Order("s.worker_id, c.chain_index").
+		Scan(&chains).Error
+	return chains, err
+}
+
+func (r *minerStatsRepository) FindFailedAsics(ctx context.Context, since time.Time) ([]*model.ChainAsicHealth, error) {
+	latest := r.db.Model(&model.MinerStats{}).Select("MAX(id)").Where("created_at >= ?", since).Group("observer_user_id, worker_id")
+
+	var chains []*model.ChainAsicHealth
+	err := r.db.WithContext(ctx).Table("miner_chains AS c").
+		Select("s.worker_id, s.ip, s.miner_type, c.chain_index, c.sn, s.created_at AS scanned_at, "+
+			"c.asic_num, c.asic_ok, c.asic_failed, c.asic_failed_positions").
+		Joins("JOIN miner_stats AS s ON s.id = c.miner_stats_id").
+		Where("s.id IN (?) AND c.asic_failed > 0", latest).
+		Order("c.asic_failed DESC, s.worker_id, c.chain_index").
+		Scan(&chains).Error
+	return chains, err
+}
+
+func (r *minerStatsRepository) FindFanAlerts(ctx context.Context, since time.Time) ([]*model.MinerStats, error) {
+	latest := r.db.Model(&model.MinerStats{}).Select("MAX(id)").Where("created_at >= ?", since).Group("observer_user_id, worker_id")
+
+	var stats []*model.MinerStats
+	err := r.db.WithContext(ctx).
+		Where("id IN (?) AND fan_alert <> ''", latest).
+		Order("fan_alert, worker_id").
+		Find(&stats).Error
+	return stats, err
+}
+
+func (r *minerStatsRepository) FindArchived(ctx context.Context, since time.Time, ip string, afterID uint, limit int) ([]*model.MinerStats, error) {
+	query := r.db.WithContext(ctx).Where("id > ? AND created_at >= ? AND raw_sha256 <> ''", afterID, since)
+	if ip != "" {
+		query = query.Where("ip = ?", ip)
+	}
+	var stats []*model.MinerStats
+	err := query.Order("id").Limit(limit).Find(&stats).Error
+	return stats, err
+}
@@ -0,0 +1,50 @@
+package mysql
+
+import (
+	"context"
+
+	"github.com/beatyman/scan-miners/internal/domain/model"
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+	"gorm.io/gorm"
+)
+
+type scanRunRepository struct {
+	db *gorm.DB
+}
+
+func NewScanRunRepository(db *gorm.DB) repository.ScanRunRepository {
+	return &scanRunRepository{db: db}
+}
+
+func (r *scanRunRepository) Create(ctx context.Context, run *model.ScanRun) error {
+	return r.db.WithContext(ctx).Create(run).Error
+}
+
+func (r *scanRunRepository) Update(ctx context.Context, run *model.ScanRun) error {
+	return r.db.WithContext(ctx).Save(run).Error
+}
+
+func (r *scanRunRepository) SaveOutcomes(ctx context.Context, outcomes []*model.ScanOutcome) error {
+	if len(outcomes) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).CreateInBatches(outcomes, 100).Error
+}
+
+func (r *scanRunRepository) FindLatestFinished(ctx context.Context) (*model.ScanRun, error) {
+	var run model.ScanRun
+	err := r.db.WithContext(ctx).Where("finished_at IS NOT NULL").Order("id desc").Limit(1).Find(&run).Error
+	if err != nil {
+		return nil, err
+	}
+	if run.ID == 0 {
+		return nil, nil
+	}
+	return &run, nil
+}
+
+func (r *scanRunRepository) FindOutcomes(ctx context.Context, scanRunID uint, reason string) ([]*model.ScanOutcome, error) {
+	var outcomes []*model.ScanOutcome
+	err := r.db.WithContext(ctx).Where("scan_run_id = ? AND reason = ?", scanRunID, reason).Order("worker_id").Find(&outcomes).Error
+	return outcomes, err
+}
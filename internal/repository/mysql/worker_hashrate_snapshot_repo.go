@@ -0,0 +1,32 @@
+package mysql
+
+import (
+	"context"
+	"time"
+
+	"github.com/beatyman/scan-miners/internal/domain/model"
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+	"gorm.io/gorm"
+)
+
+type workerHashrateSnapshotRepository struct {
+	db *gorm.DB
+}
+
+func NewWorkerHashrateSnapshotRepository(db *gorm.DB) repository.WorkerHashrateSnapshotRepository {
+	return &workerHashrateSnapshotRepository{db: db}
+}
+
+func (r *workerHashrateSnapshotRepository) SaveBatch(ctx context.Context, snapshots []*model.WorkerHashrateSnapshot) error {
+	return r.db.WithContext(ctx).CreateInBatches(snapshots, 100).Error
+}
+
+func (r *workerHashrateSnapshotRepository) FindByWorkerID(ctx context.Context, observerUserID, coinType, workerID string, from, to time.Time) ([]*model.WorkerHashrateSnapshot, error) {
+	var snapshots []*model.WorkerHashrateSnapshot
+	err := r.db.WithContext(ctx).
+		Where("observer_user_id = ? AND coin_type = ? AND worker_id = ?", observerUserID, coinType, workerID).
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Order("created_at").
+		Find(&snapshots).Error
+	return snapshots, err
+}
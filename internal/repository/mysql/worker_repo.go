@@ -2,6 +2,8 @@ package mysql
 
 import (
 	"context"
+	"time"
+
 	"github.com/beatyman/scan-miners/internal/domain/model"
 	"github.com/beatyman/scan-miners/internal/domain/repository"
 	"gorm.io/gorm"
@@ -16,16 +18,19 @@ func NewWorkerRepository(db *gorm.DB) repository.WorkerRepository {
 	return &workerRepository{db: db}
 }
 
+// minerSideColumns are written by scan-miners only; upserts from the pool list must keep them
+var minerSideColumns = []string{"miner_driver", "miner_model", "stats_endpoint", "driver_checked_at", "miner_credential"}
+
 func (r *workerRepository) Save(ctx context.Context, worker *model.Worker) error {
-	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "worker_id"}},
+	return r.db.WithContext(ctx).Omit(minerSideColumns...).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "worker_id"}, {Name: "observer_user_id"}, {Name: "coin_type"}},
 		UpdateAll: true,
 	}).Create(worker).Error
 }
 
 func (r *workerRepository) SaveBatch(ctx context.Context, workers []*model.Worker) error {
-	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "worker_id"}},
+	return r.db.WithContext(ctx).Omit(minerSideColumns...).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "worker_id"}, {Name: "observer_user_id"}, {Name: "coin_type"}},
 		UpdateAll: true,
 	}).CreateInBatches(workers, 100).Error
 }
@@ -36,11 +41,42 @@ func (r *workerRepository) FindAll(ctx context.Context) ([]*model.Worker, error)
 	return workers, err
 }
 
-func (r *workerRepository) FindByWorkerID(ctx context.Context, workerID string) (*model.Worker, error) {
-	var worker model.Worker
-	err := r.db.WithContext(ctx).Where("worker_id = ?", workerID).First(&worker).Error
-	if err != nil {
-		return nil, err
+func (r *workerRepository) FindActive(ctx context.Context) ([]*model.Worker, error) {
+	var workers []*model.Worker
+	err := r.db.WithContext(ctx).Where("active = ?", true).Find(&workers).Error
+	return workers, err
+}
+
+func (r *workerRepository) FindByAccount(ctx context.Context, observerUserID, coinType string) ([]*model.Worker, error) {
+	var workers []*model.Worker
+	err := r.db.WithContext(ctx).Where("observer_user_id = ? AND coin_type = ?", observerUserID, coinType).Find(&workers).Error
+	return workers, err
+}
+
+func (r *workerRepository) MarkInactive(ctx context.Context, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
 	}
-	return &worker, nil
+	return r.db.WithContext(ctx).Model(&model.Worker{}).Where("id IN ?", ids).Update("active", false).Error
+}
+
+func (r *workerRepository) UpdateIP(ctx context.Context, id uint, ip string) error {
+	return r.db.WithContext(ctx).Model(&model.Worker{}).Where("id = ?", id).Update("ip", ip).Error
+}
+
+func (r *workerRepository) UpdateMinerDriver(ctx context.Context, id uint, driver, minerModel string) error {
+	return r.db.WithContext(ctx).Model(&model.Worker{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"miner_driver":      driver,
+		"miner_model":       minerModel,
+		"stats_endpoint":    "",
+		"driver_checked_at": time.Now(),
+	}).Error
+}
+
+func (r *workerRepository) UpdateStatsEndpoint(ctx context.Context, id uint, endpoint string) error {
+	return r.db.WithContext(ctx).Model(&model.Worker{}).Where("id = ?", id).Update("stats_endpoint", endpoint).Error
+}
+
+func (r *workerRepository) UpdateMinerCredential(ctx context.Context, id uint, fingerprint string) error {
+	return r.db.WithContext(ctx).Model(&model.Worker{}).Where("id = ?", id).Update("miner_credential", fingerprint).Error
 }
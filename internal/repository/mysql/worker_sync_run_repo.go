@@ -0,0 +1,25 @@
+package mysql
+
+import (
+	"context"
+
+	"github.com/beatyman/scan-miners/internal/domain/model"
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+	"gorm.io/gorm"
+)
+
+type workerSyncRunRepository struct {
+	db *gorm.DB
+}
+
+func NewWorkerSyncRunRepository(db *gorm.DB) repository.WorkerSyncRunRepository {
+	return &workerSyncRunRepository{db: db}
+}
+
+func (r *workerSyncRunRepository) Create(ctx context.Context, run *model.WorkerSyncRun) error {
+	return r.db.WithContext(ctx).Create(run).Error
+}
+
+func (r *workerSyncRunRepository) Update(ctx context.Context, run *model.WorkerSyncRun) error {
+	return r.db.WithContext(ctx).Save(run).Error
+}
@@ -0,0 +1,114 @@
+// Package rawarchive stores raw miner responses in a content-addressed
+// directory: <dir>/<first 2 hex digits>/<sha256>.json, or .json.gz when
+// compressed.
+package rawarchive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+)
+
+type fileArchive struct {
+	dir      string
+	compress bool
+}
+
+// NewFileArchive stores blobs under dir, gzip-compressed when compress is set.
+// Get reads both forms, so compression can be switched at any time.
+func NewFileArchive(dir string, compress bool) repository.RawArchive {
+	return &fileArchive{dir: dir, compress: compress}
+}
+
+func (a *fileArchive) Put(ctx context.Context, data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	key := hex.EncodeToString(sum[:])
+
+	for _, path := range a.paths(key) {
+		if _, err := os.Stat(path); err == nil {
+			return key, nil
+		}
+	}
+
+	path, content := a.paths(key)[0], data
+	if a.compress {
+		path = a.paths(key)[1]
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return "", err
+		}
+		if err := zw.Close(); err != nil {
+			return "", err
+		}
+		content = buf.Bytes()
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	// Write to a temp file first: a crash must not leave a truncated blob under a valid key
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return key, nil
+}
+
+func (a *fileArchive) Get(ctx context.Context, key string) ([]byte, error) {
+	if len(key) != sha256.Size*2 {
+		return nil, fmt.Errorf("invalid archive key %q", key)
+	}
+	paths := a.paths(key)
+
+	data, err := os.ReadFile(paths[0])
+	if err == nil {
+		return data, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	f, err := os.Open(paths[1])
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s: %w", key, repository.ErrRawNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// paths returns the plain and the compressed location of key
+func (a *fileArchive) paths(key string) [2]string {
+	base := filepath.Join(a.dir, key[:2], key+".json")
+	return [2]string{base, base + ".gz"}
+}
@@ -0,0 +1,66 @@
+// Package vnish reads Antminers running the VNish aftermarket firmware. Its
+// bmminer build keeps the stock stats layout on port 4028 but tags its version.
+package vnish
+
+import (
+	"context"
+	"strings"
+
+	"github.com/beatyman/scan-miners/internal/domain/model"
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+	"github.com/beatyman/scan-miners/internal/repository/cgminer"
+)
+
+type driver struct {
+	client *cgminer.Client
+}
+
+func NewDriver(client *cgminer.Client) repository.MinerDriver {
+	return &driver{client: client}
+}
+
+func (d *driver) Name() string {
+	return model.DriverVNish
+}
+
+func (d *driver) Detect(ctx context.Context, ip string) (bool, error) {
+	body, err := d.client.Command(ctx, ip, "version")
+	if err != nil {
+		return false, err
+	}
+	version, err := cgminer.DecodeSection(body, "VERSION")
+	if err != nil {
+		return false, err
+	}
+	for _, v := range version {
+		if strings.Contains(strings.ToLower(cgminer.Str(v)), "vnish") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (d *driver) FetchInfo(ctx context.Context, ip string) (*model.DeviceInfo, error) {
+	body, err := d.client.Command(ctx, ip, "version")
+	if err != nil {
+		return nil, err
+	}
+	version, err := cgminer.DecodeSection(body, "VERSION")
+	if err != nil {
+		return nil, err
+	}
+	return &model.DeviceInfo{
+		Driver:   model.DriverVNish,
+		Model:    cgminer.Str(version["Type"]),
+		Firmware: cgminer.FirstStr(version, "Miner", "BMMiner"),
+	}, nil
+}
+
+func (d *driver) FetchStats(ctx context.Context, ip string) (*model.MinerStats, error) {
+	ms, err := d.client.FetchStats(ctx, ip)
+	if err != nil {
+		return nil, err
+	}
+	ms.Source = model.DriverVNish
+	return ms, nil
+}
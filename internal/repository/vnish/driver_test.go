@@ -0,0 +1,61 @@
+package vnish
+
+import (
+	"context"
+	"testing"
+
+	"github.com/beatyman/scan-miners/internal/domain/model"
+	"github.com/beatyman/scan-miners/internal/repository/cgminer"
+	"github.com/beatyman/scan-miners/pkg/transcript"
+)
+
+// replay returns a context that answers cgminer commands from the given raw replies
+func replay(responses map[string]string) context.Context {
+	ctx, tr := transcript.Record(context.Background())
+	for command, raw := range responses {
+		tr.Add(command, []byte(raw))
+	}
+	return transcript.Replay(ctx, tr)
+}
+
+// Replies of an S19 on VNish 1.2.0: the stock bmminer layout with chain_* keys
+var vnishReplies = map[string]string{
+	"version": `{"STATUS":[{"STATUS":"S","Msg":"BMMiner versions"}],"VERSION":[{"BMMiner":"2.0.0 vnish 1.2.0","API":"3.1","Miner":"vnish 1.2.0","CompileTime":"Mon Apr 3 2023","Type":"Antminer S19"}],"id":1}` + "\x00",
+	"stats": `{"STATUS":[{"STATUS":"S","Msg":"CGMiner stats"}],"STATS":[{"BMMiner":"2.0.0 vnish 1.2.0","Miner":"vnish 1.2.0","Type":"Antminer S19"}{"STATS":0,"ID":"BTM_SOC0","Elapsed":1800,"GHS 5s":"95,102.55","GHS av":95010.12,"GHS 30m":94990.00,"total_rateideal":95000,"fan_num":4,"fan1":5400,"fan2":5460,"fan3":5520,"fan4":5490,` +
+		`"chain_acn1":76,"chain_rate1":"31,700.10","chain_rateideal1":31666,"freq_avg1":675,"chain_hw1":0,"temp_pcb1":"44-44-62-62","temp_chip1":"59-59-77-77","temp_pic1":"44-44-62-62",` +
+		`"chain_acn2":76,"chain_rate2":"31,650.00","chain_rateideal2":31666,"freq_avg2":675,"chain_hw2":3,"temp_pcb2":"45-45-63-63","temp_chip2":"60-60-79-79","temp_pic2":"45-45-63-63",` +
+		`"chain_acn3":76,"chain_rate3":"31,752.45","chain_rateideal3":31666,"freq_avg3":675,"chain_hw3":1,"temp_pcb3":"43-43-61-61","temp_chip3":"58-58-76-76","temp_pic3":"43-43-61-61"}],"id":1}` + "\x00",
+}
+
+func TestFetchStats(t *testing.T) {
+	ms, err := NewDriver(cgminer.NewClient(4028, 0, 0)).FetchStats(replay(vnishReplies), "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ms.Source != model.DriverVNish || ms.MinerType != "Antminer S19" || ms.MinerVersion != "2.0.0 vnish 1.2.0" {
+		t.Errorf("source %q, type %q, version %q", ms.Source, ms.MinerType, ms.MinerVersion)
+	}
+	if ms.Elapsed != 1800 || ms.Rate5s != 95102.55 || ms.Rate30m != 94990 || ms.RateIdeal != 95000 {
+		t.Errorf("unexpected rates: %+v", ms)
+	}
+	if ms.FanNum != 4 || ms.FanSpeeds != "5400,5460,5520,5490" {
+		t.Errorf("fans = %q of %d", ms.FanSpeeds, ms.FanNum)
+	}
+	if len(ms.Chains) != 3 {
+		t.Fatalf("%d chains, want 3", len(ms.Chains))
+	}
+	c := ms.Chains[1]
+	if c.ChainIndex != 1 || c.RateReal != 31650 || c.AsicNum != 76 || c.Hw != 3 {
+		t.Errorf("unexpected second chain: %+v", c)
+	}
+	if c.TempPcbMax != 63 || c.TempChipMin != 60 || c.TempChipMax != 79 || len(c.Temps) != 12 {
+		t.Errorf("second chain pcb max %v, chip %v-%v, %d readings", c.TempPcbMax, c.TempChipMin, c.TempChipMax, len(c.Temps))
+	}
+}
+
+func TestDetect(t *testing.T) {
+	ok, err := NewDriver(cgminer.NewClient(4028, 0, 0)).Detect(replay(vnishReplies), "10.0.0.1")
+	if err != nil || !ok {
+		t.Fatalf("Detect() = %t, %v, want the vnish version recognised", ok, err)
+	}
+}
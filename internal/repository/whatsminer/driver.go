@@ -0,0 +1,144 @@
+// Package whatsminer reads MicroBT Whatsminers through the btminer API, a
+// cgminer-style JSON protocol on port 4028 that reports rates in MH/s.
+package whatsminer
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/beatyman/scan-miners/internal/domain/model"
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+	"github.com/beatyman/scan-miners/internal/repository/cgminer"
+)
+
+type driver struct {
+	client *cgminer.Client
+}
+
+func NewDriver(client *cgminer.Client) repository.MinerDriver {
+	return &driver{client: client}
+}
+
+func (d *driver) Name() string {
+	return model.DriverWhatsminer
+}
+
+// Detect looks for the "btminer" description btminer puts in every STATUS block
+func (d *driver) Detect(ctx context.Context, ip string) (bool, error) {
+	body, err := d.client.Command(ctx, ip, "summary")
+	if err != nil {
+		return false, err
+	}
+	status, err := cgminer.DecodeSection(body, "STATUS")
+	if err != nil {
+		return false, err
+	}
+	return strings.Contains(strings.ToLower(cgminer.Str(status["Description"])), "btminer"), nil
+}
+
+func (d *driver) FetchInfo(ctx context.Context, ip string) (*model.DeviceInfo, error) {
+	info := &model.DeviceInfo{Driver: model.DriverWhatsminer}
+
+	body, err := d.client.Command(ctx, ip, "devdetails")
+	if err != nil {
+		return nil, err
+	}
+	details, err := cgminer.DecodeSection(body, "DEVDETAILS")
+	if err != nil {
+		return nil, err
+	}
+	info.Model = modelName(details)
+
+	// get_version answers in the flat {"STATUS":"S","Msg":{...}} form
+	if body, err := d.client.Command(ctx, ip, "get_version"); err == nil {
+		var version struct {
+			Msg struct {
+				FwVer string `json:"fw_ver"`
+			} `json:"Msg"`
+		}
+		if json.Unmarshal(body, &version) == nil {
+			info.Firmware = version.Msg.FwVer
+		}
+	}
+	return info, nil
+}
+
+func (d *driver) FetchStats(ctx context.Context, ip string) (*model.MinerStats, error) {
+	body, err := d.client.Command(ctx, ip, "summary")
+	if err != nil {
+		return nil, err
+	}
+	summary, err := cgminer.DecodeSection(body, "SUMMARY")
+	if err != nil {
+		return nil, err
+	}
+
+	ms := &model.MinerStats{
+		Source:    model.DriverWhatsminer,
+		Elapsed:   int64(cgminer.Num(summary["Elapsed"])),
+		Rate5s:    cgminer.GHS(summary, "5s"),
+		Rate30m:   cgminer.GHS(summary, "15m"), // btminer has no 30 minute window
+		RateAvg:   cgminer.GHS(summary, "av"),
+		RateIdeal: cgminer.Num(summary["Factory GHS"]),
+		RateUnit:  "GH/s",
+		HwpTotal:  cgminer.Num(summary["Device Hardware%"]),
+	}
+	var fans []float64
+	for _, key := range []string{"Fan Speed In", "Fan Speed Out"} {
+		if v, ok := summary[key]; ok {
+			ms.FanNum++
+			fans = append(fans, cgminer.Num(v))
+		}
+	}
+	ms.SetFanSpeeds(fans)
+
+	if body, err := d.client.Command(ctx, ip, "devdetails"); err == nil {
+		if details, err := cgminer.DecodeSection(body, "DEVDETAILS"); err == nil {
+			ms.MinerType = modelName(details)
+		}
+	}
+
+	body, err = d.client.Command(ctx, ip, "devs")
+	if err != nil {
+		return nil, err
+	}
+	devs, err := cgminer.DecodeList(body, "DEVS")
+	if err != nil {
+		return nil, err
+	}
+	for i, dev := range devs {
+		index := i
+		if v, ok := dev["Slot"]; ok {
+			index = int(cgminer.Num(v))
+		}
+		chain := model.MinerChain{
+			ChainIndex: index,
+			FreqAvg:    int(cgminer.Num(dev["Chip Frequency"])),
+			RateIdeal:  cgminer.Num(dev["Factory GHS"]),
+			RateReal:   cgminer.GHS(dev, "av"),
+			AsicNum:    int(cgminer.Num(dev["Effective Chips"])),
+			Hw:         int(cgminer.Num(dev["Hardware Errors"])),
+			SN:         cgminer.Str(dev["PCB SN"]),
+		}
+		// btminer reports the board sensor and a chip summary, not per-chip readings
+		chain.SetTemperatures(nil, cgminer.Temps(dev["Temperature"]), nil)
+		chain.TempChipMin = cgminer.Num(dev["Chip Temp Min"])
+		chain.TempChipMax = cgminer.Num(dev["Chip Temp Max"])
+		chain.TempChipAvg = cgminer.Num(dev["Chip Temp Avg"])
+		ms.Chains = append(ms.Chains, chain)
+	}
+
+	if cgminer.IsEmpty(ms) {
+		return nil, cgminer.ErrNoStats
+	}
+	return ms, nil
+}
+
+func modelName(details map[string]interface{}) string {
+	name := cgminer.Str(details["Model"])
+	if name == "" || strings.HasPrefix(name, "Whatsminer") {
+		return name
+	}
+	return "Whatsminer " + name
+}
@@ -0,0 +1,58 @@
+package whatsminer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/beatyman/scan-miners/internal/domain/model"
+	"github.com/beatyman/scan-miners/internal/repository/cgminer"
+	"github.com/beatyman/scan-miners/pkg/transcript"
+)
+
+// replay returns a context that answers btminer commands from the given raw replies
+func replay(responses map[string]string) context.Context {
+	ctx, tr := transcript.Record(context.Background())
+	for command, raw := range responses {
+		tr.Add(command, []byte(raw))
+	}
+	return transcript.Replay(ctx, tr)
+}
+
+// Replies of a Whatsminer M30S+ on btminer 2.0.5, rates in MH/s
+var m30Replies = map[string]string{
+	"summary":    `{"STATUS":[{"STATUS":"S","Msg":"Summary","Description":"btminer"}],"SUMMARY":[{"Elapsed":7200,"MHS av":100212345.67,"MHS 5s":101000000.00,"MHS 15m":100500000.00,"Factory GHS":100000,"Fan Speed In":4200,"Fan Speed Out":4350,"Device Hardware%":0.0012}],"id":1}`,
+	"devdetails": `{"STATUS":[{"STATUS":"S","Msg":"Device Details","Description":"btminer"}],"DEVDETAILS":[{"DEVDETAILS":0,"Name":"SM","ID":0,"Model":"M30S+.VE40"}],"id":1}`,
+	"devs": `{"STATUS":[{"STATUS":"S","Msg":"3 ASC(s)","Description":"btminer"}],"DEVS":[` +
+		`{"ASC":0,"Slot":0,"Temperature":68.5,"Chip Frequency":590,"MHS av":33400000.12,"Factory GHS":33333,"Effective Chips":156,"Hardware Errors":3,"PCB SN":"HEM1ES0123","Chip Temp Min":70.1,"Chip Temp Max":88.4,"Chip Temp Avg":79.9},` +
+		`{"ASC":1,"Slot":1,"Temperature":69.0,"Chip Frequency":590,"MHS av":33500000.00,"Factory GHS":33333,"Effective Chips":156,"Hardware Errors":0,"PCB SN":"HEM1ES0124","Chip Temp Min":71.0,"Chip Temp Max":87.0,"Chip Temp Avg":80.2},` +
+		`{"ASC":2,"Slot":2,"Temperature":nan,"Chip Frequency":590,"MHS av":33300000.00,"Factory GHS":33333,"Effective Chips":150,"Hardware Errors":12,"PCB SN":"HEM1ES0125","Chip Temp Min":70.0,"Chip Temp Max":90.3,"Chip Temp Avg":81.0},]}`,
+}
+
+func TestFetchStats(t *testing.T) {
+	ms, err := NewDriver(cgminer.NewClient(4028, 0, 0)).FetchStats(replay(m30Replies), "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ms.Source != model.DriverWhatsminer || ms.MinerType != "Whatsminer M30S+.VE40" {
+		t.Errorf("source %q, type %q", ms.Source, ms.MinerType)
+	}
+	if ms.Elapsed != 7200 || ms.Rate5s != 101000 || ms.Rate30m != 100500 || ms.RateIdeal != 100000 || ms.RateUnit != "GH/s" {
+		t.Errorf("rates not converted from MH/s: %+v", ms)
+	}
+	if ms.FanNum != 2 || ms.FanSpeeds != "4200,4350" {
+		t.Errorf("fans = %q of %d", ms.FanSpeeds, ms.FanNum)
+	}
+	if len(ms.Chains) != 3 {
+		t.Fatalf("%d chains, want 3", len(ms.Chains))
+	}
+	c := ms.Chains[2]
+	if c.ChainIndex != 2 || c.RateReal != 33300 || c.AsicNum != 150 || c.Hw != 12 || c.SN != "HEM1ES0125" || c.FreqAvg != 590 {
+		t.Errorf("unexpected third chain: %+v", c)
+	}
+	if c.TempChipMin != 70 || c.TempChipMax != 90.3 || c.TempChipAvg != 81 {
+		t.Errorf("chip temps %v/%v/%v, want 70/90.3/81", c.TempChipMin, c.TempChipMax, c.TempChipAvg)
+	}
+	if c := ms.Chains[0]; c.TempPcbMax != 68.5 || c.TempChipMax != 88.4 {
+		t.Errorf("first chain pcb %v, chip %v", c.TempPcbMax, c.TempChipMax)
+	}
+}
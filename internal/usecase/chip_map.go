@@ -0,0 +1,327 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/beatyman/scan-miners/internal/domain/model"
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+	"github.com/beatyman/scan-miners/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// Output formats of chip-map
+const (
+	ChipMapText = "text"
+	ChipMapSVG  = "svg"
+	ChipMapHTML = "html"
+)
+
+type ChipMapUseCase struct {
+	minerStatsRepo repository.MinerStatsRepository
+	chipLayoutRepo repository.ChipLayoutRepository
+}
+
+func NewChipMapUseCase(minerStatsRepo repository.MinerStatsRepository, chipLayoutRepo repository.ChipLayoutRepository) *ChipMapUseCase {
+	return &ChipMapUseCase{
+		minerStatsRepo: minerStatsRepo,
+		chipLayoutRepo: chipLayoutRepo,
+	}
+}
+
+// chipCell is one cell of a rendered chain grid
+type chipCell struct {
+	pos    int  // Position in the asic string, model.EmptyCell for no chip
+	failed bool // Chip shows "x" or "-"
+	known  bool // Chip has a status character at all
+	temp   float64
+}
+
+type chainMap struct {
+	chain model.MinerChain
+	grid  [][]chipCell
+}
+
+// Execute renders the chips of every chain of the latest snapshot of the miner
+// at ip. Text goes to stdout; SVG and HTML are written to a file.
+func (uc *ChipMapUseCase) Execute(ctx context.Context, ip, format string) error {
+	switch format {
+	case ChipMapText, ChipMapSVG, ChipMapHTML:
+	default:
+		return fmt.Errorf("unknown format %q, want %s, %s or %s", format, ChipMapText, ChipMapSVG, ChipMapHTML)
+	}
+
+	stats, err := uc.minerStatsRepo.FindLatestByIP(ctx, ip)
+	if err != nil {
+		return err
+	}
+	if stats == nil {
+		return fmt.Errorf("no stats stored for %s, run scan-miners first", ip)
+	}
+
+	layout, err := uc.chipLayoutRepo.FindByMinerType(ctx, stats.MinerType)
+	if err != nil {
+		return err
+	}
+	var grid [][]int
+	if layout != nil {
+		if grid, err = layout.Grid(); err != nil {
+			return err
+		}
+	} else {
+		logger.Log.Warn("No chip layout stored for miner type, using asic string groups as rows", zap.String("minerType", stats.MinerType))
+	}
+
+	var maps []chainMap
+	for _, chain := range stats.Chains {
+		maps = append(maps, chainMap{chain: chain, grid: buildChipGrid(chain, grid)})
+	}
+
+	if format == ChipMapText {
+		renderChipMapText(os.Stdout, stats, maps)
+		return nil
+	}
+
+	filename := fmt.Sprintf("chip_map_%s_%s.%s", strings.ReplaceAll(ip, ".", "_"), time.Now().Format("20060102_150405"), format)
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if format == ChipMapSVG {
+		renderChipMapSVG(file, stats, maps)
+	} else {
+		renderChipMapHTML(file, stats, maps)
+	}
+
+	absPath, _ := filepath.Abs(filename)
+	logger.Log.Info("Chip map written", zap.String("file", absPath))
+	return nil
+}
+
+// buildChipGrid places the chain's chips on the layout grid, or on one row per
+// space separated group of the asic string when there is no layout
+func buildChipGrid(chain model.MinerChain, layout [][]int) [][]chipCell {
+	var status []rune
+	for _, r := range chain.AsicStatus {
+		if r != ' ' {
+			status = append(status, r)
+		}
+	}
+
+	// Per-chip temperatures only when the firmware reports one reading per chip
+	var chipTemps []float64
+	for _, t := range chain.Temps {
+		if t.Sensor == model.TempSensorChip {
+			chipTemps = append(chipTemps, t.Value)
+		}
+	}
+	if len(chipTemps) != len(status) {
+		chipTemps = nil
+	}
+
+	if layout == nil {
+		pos := 0
+		for _, group := range strings.Fields(chain.AsicStatus) {
+			row := make([]int, 0, len(group))
+			for range group {
+				row = append(row, pos)
+				pos++
+			}
+			layout = append(layout, row)
+		}
+	}
+
+	grid := make([][]chipCell, len(layout))
+	for r, row := range layout {
+		grid[r] = make([]chipCell, len(row))
+		for c, pos := range row {
+			cell := chipCell{pos: pos}
+			if pos >= 0 && pos < len(status) {
+				cell.known = true
+				cell.failed = status[pos] == 'x' || status[pos] == '-'
+				if chipTemps != nil {
+					cell.temp = chipTemps[pos]
+				}
+			}
+			grid[r][c] = cell
+		}
+	}
+	return grid
+}
+
+func chainTitle(chain model.MinerChain) string {
+	title := fmt.Sprintf("Chain %d", chain.ChainIndex)
+	if chain.SN != "" {
+		title += "  SN " + chain.SN
+	}
+	title += fmt.Sprintf("  chips %d ok / %d failed", chain.AsicOK, chain.AsicFailed)
+	if chain.TempChipMax > 0 {
+		title += fmt.Sprintf("  chip %.0f-%.0f°C", chain.TempChipMin, chain.TempChipMax)
+	}
+	return title
+}
+
+// renderChipMapText draws each chain as a grid of symbols; when the firmware
+// reports one reading per chip, working chips show their temperature instead of o
+func renderChipMapText(w io.Writer, stats *model.MinerStats, maps []chainMap) {
+	fmt.Fprintf(w, "%s  %s  scanned %s\n", stats.IP, stats.MinerType, stats.CreatedAt.Format(time.DateTime))
+	fmt.Fprintln(w, "o ok (or its chip temperature in °C), X failed, ? unknown, . no chip")
+	for _, m := range maps {
+		fmt.Fprintf(w, "\n%s\n", chainTitle(m.chain))
+		width := 2
+		if hasChipTemps(m.grid) {
+			width = 4
+		}
+
+		var failed []string
+		var hottest *chipCell
+		hottestAt := ""
+		for r, row := range m.grid {
+			var sb strings.Builder
+			for c, cell := range row {
+				switch {
+				case cell.pos == model.EmptyCell:
+					fmt.Fprintf(&sb, "%*s", width, ".")
+				case !cell.known:
+					fmt.Fprintf(&sb, "%*s", width, "?")
+				case cell.failed:
+					fmt.Fprintf(&sb, "%*s", width, "X")
+					failed = append(failed, fmt.Sprintf("chip %d at row %d col %d", cell.pos, r+1, c+1))
+				case cell.temp > 0:
+					fmt.Fprintf(&sb, "%*.0f", width, cell.temp)
+					if hottest == nil || cell.temp > hottest.temp {
+						hottest = &row[c]
+						hottestAt = fmt.Sprintf("row %d col %d", r+1, c+1)
+					}
+				default:
+					fmt.Fprintf(&sb, "%*s", width, "o")
+				}
+			}
+			fmt.Fprintf(w, "%3d |%s\n", r+1, sb.String())
+		}
+		for _, f := range failed {
+			fmt.Fprintf(w, "  failed: %s\n", f)
+		}
+		if hottest != nil {
+			fmt.Fprintf(w, "  hottest: chip %d at %s, %.0f°C\n", hottest.pos, hottestAt, hottest.temp)
+		}
+	}
+}
+
+// hasChipTemps reports whether any chip of the grid has a temperature reading
+func hasChipTemps(grid [][]chipCell) bool {
+	for _, row := range grid {
+		for _, cell := range row {
+			if cell.temp > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+const (
+	chipCellSize  = 26
+	chipMapMargin = 10
+	chipMapTitleH = 24
+)
+
+// chainSVG renders one chain as an SVG group at vertical offset y and returns its height
+func chainSVG(w io.Writer, m chainMap, y int) int {
+	fmt.Fprintf(w, `<g transform="translate(%d,%d)">`+"\n", chipMapMargin, y)
+	fmt.Fprintf(w, `<text x="0" y="16" font-family="monospace" font-size="14">%s</text>`+"\n", html.EscapeString(chainTitle(m.chain)))
+	for r, row := range m.grid {
+		for c, cell := range row {
+			if cell.pos == model.EmptyCell {
+				continue
+			}
+			x, cy := c*chipCellSize, chipMapTitleH+r*chipCellSize
+			fill := "#cccccc"
+			if cell.known {
+				fill = tempColor(cell.temp)
+			}
+			stroke, width := "#666666", 1
+			if cell.failed {
+				fill, stroke, width = "#e53935", "#000000", 2
+			}
+			fmt.Fprintf(w, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s" stroke="%s" stroke-width="%d"><title>chip %d%s</title></rect>`+"\n",
+				x, cy, chipCellSize-2, chipCellSize-2, fill, stroke, width, cell.pos, tempLabel(cell))
+			fmt.Fprintf(w, `<text x="%d" y="%d" font-family="monospace" font-size="9" text-anchor="middle">%d</text>`+"\n",
+				x+(chipCellSize-2)/2, cy+chipCellSize/2+2, cell.pos)
+		}
+	}
+	fmt.Fprintln(w, "</g>")
+	return chipMapTitleH + len(m.grid)*chipCellSize + chipMapMargin
+}
+
+// tempColor shades working chips from green (<=50°C) to orange (>=90°C); 0 means no reading
+func tempColor(temp float64) string {
+	if temp <= 0 {
+		return "#66bb6a"
+	}
+	f := (temp - 50) / 40
+	f = max(0, min(1, f))
+	r := int(102 + f*(255-102))
+	g := int(187 - f*(187-152))
+	b := int(106 - f*106)
+	return fmt.Sprintf("#%02x%02x%02x", r, g, b)
+}
+
+func tempLabel(cell chipCell) string {
+	label := ""
+	if cell.failed {
+		label = " failed"
+	}
+	if cell.temp > 0 {
+		label += fmt.Sprintf(" %.0f°C", cell.temp)
+	}
+	return label
+}
+
+func chipMapSize(maps []chainMap) (int, int) {
+	width, height := 0, chipMapMargin
+	for _, m := range maps {
+		for _, row := range m.grid {
+			width = max(width, len(row)*chipCellSize)
+		}
+		height += chipMapTitleH + len(m.grid)*chipCellSize + chipMapMargin
+	}
+	// Leave room for the longest title
+	return max(width, 520) + 2*chipMapMargin, height
+}
+
+func writeChipMapSVG(w io.Writer, maps []chainMap) {
+	width, height := chipMapSize(maps)
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d">`+"\n", width, height)
+	y := chipMapMargin
+	for _, m := range maps {
+		y += chainSVG(w, m, y)
+	}
+	fmt.Fprintln(w, "</svg>")
+}
+
+func renderChipMapSVG(w io.Writer, stats *model.MinerStats, maps []chainMap) {
+	fmt.Fprintf(w, "<!-- %s %s scanned %s -->\n", html.EscapeString(stats.IP), html.EscapeString(stats.MinerType), stats.CreatedAt.Format(time.DateTime))
+	writeChipMapSVG(w, maps)
+}
+
+func renderChipMapHTML(w io.Writer, stats *model.MinerStats, maps []chainMap) {
+	title := fmt.Sprintf("%s %s", stats.IP, stats.MinerType)
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>%s</title></head>\n<body style=\"font-family: sans-serif\">\n", html.EscapeString(title))
+	fmt.Fprintf(w, "<h2>%s</h2>\n<p>Scanned %s. Red cells are failed chips; working chips are shaded by temperature when the firmware reports per-chip readings. Hover a chip for details.</p>\n",
+		html.EscapeString(title), stats.CreatedAt.Format(time.DateTime))
+	if len(maps) == 0 {
+		fmt.Fprintln(w, "<p>No chains in this snapshot.</p>")
+	} else {
+		writeChipMapSVG(w, maps)
+	}
+	fmt.Fprintln(w, "</body></html>")
+}
@@ -0,0 +1,38 @@
+package usecase
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/beatyman/scan-miners/internal/domain/model"
+)
+
+func TestRenderChipMapText(t *testing.T) {
+	layout := [][]int{{0, 1}, {2, model.EmptyCell}}
+	plain := model.MinerChain{ChainIndex: 1, AsicStatus: "ox o"}
+	withTemps := model.MinerChain{ChainIndex: 2, AsicStatus: "oox"}
+	withTemps.SetTemperatures(nil, nil, []float64{61, 74, 58})
+
+	var buf bytes.Buffer
+	renderChipMapText(&buf, &model.MinerStats{IP: "10.0.0.1"}, []chainMap{
+		{chain: plain, grid: buildChipGrid(plain, layout)},
+		{chain: withTemps, grid: buildChipGrid(withTemps, layout)},
+	})
+	out := buf.String()
+
+	for _, want := range []string{
+		"  1 | o X\n  2 | o .\n",
+		"  1 |  61  74\n  2 |   X   .\n",
+		"failed: chip 1 at row 1 col 2",
+		"failed: chip 2 at row 2 col 1",
+		"hottest: chip 1 at row 1 col 2, 74°C",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "hottest:") != 1 {
+		t.Errorf("only the chain with per-chip readings should list its hottest chip:\n%s", out)
+	}
+}
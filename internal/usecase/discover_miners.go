@@ -0,0 +1,290 @@
+package usecase
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/beatyman/scan-miners/config"
+	"github.com/beatyman/scan-miners/internal/domain/model"
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+	"github.com/beatyman/scan-miners/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// Findings written to the discovery report
+const (
+	findingDeviceWithoutWorker = "device_without_worker"
+	findingWorkerWithoutDevice = "worker_without_device"
+)
+
+type DiscoverMinersUseCase struct {
+	cfg        *config.Config
+	workerRepo repository.WorkerRepository
+	deviceRepo repository.DiscoveredDeviceRepository
+	drivers    []repository.MinerDriver
+}
+
+// NewDiscoverMinersUseCase takes the miner drivers in the same detection order
+// as NewScanMinersUseCase
+func NewDiscoverMinersUseCase(cfg *config.Config, workerRepo repository.WorkerRepository, deviceRepo repository.DiscoveredDeviceRepository, drivers []repository.MinerDriver) *DiscoverMinersUseCase {
+	return &DiscoverMinersUseCase{
+		cfg:        cfg,
+		workerRepo: workerRepo,
+		deviceRepo: deviceRepo,
+		drivers:    drivers,
+	}
+}
+
+// Execute sweeps every configured CIDR, records the hosts that answer on a
+// miner port, with the driver that recognises them if any, and writes a CSV of devices without a pool worker and of
+// pool workers inside the swept ranges without a device.
+func (uc *DiscoverMinersUseCase) Execute(ctx context.Context) error {
+	if len(uc.cfg.Discovery.CIDRs) == 0 {
+		return errors.New("no ranges to sweep: set discovery.cidrs")
+	}
+
+	prefixes, hosts, err := sweepHosts(uc.cfg.Discovery.CIDRs)
+	if err != nil {
+		return err
+	}
+	logger.Log.Info("Starting LAN discovery", zap.Strings("cidrs", uc.cfg.Discovery.CIDRs), zap.Int("hosts", len(hosts)))
+
+	workers, err := uc.workerRepo.FindActive(ctx)
+	if err != nil {
+		return err
+	}
+	workersByIP := make(map[string]*model.Worker, len(workers))
+	for _, w := range workers {
+		if w.IP != "" {
+			workersByIP[w.IP] = w
+		}
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		devices []*model.DiscoveredDevice
+	)
+	semaphore := make(chan struct{}, uc.cfg.Discovery.Concurrency)
+
+	for _, addr := range hosts {
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		semaphore <- struct{}{}
+
+		go func(ip string) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			if device := uc.probe(ctx, ip, workersByIP[ip]); device != nil {
+				mu.Lock()
+				devices = append(devices, device)
+				mu.Unlock()
+			}
+		}(addr.String())
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	sort.Slice(devices, func(i, j int) bool {
+		return netip.MustParseAddr(devices[i].IP).Less(netip.MustParseAddr(devices[j].IP))
+	})
+
+	now := time.Now()
+	responding := make(map[string]bool, len(devices))
+	for _, device := range devices {
+		responding[device.IP] = true
+		if w, ok := workersByIP[device.IP]; ok {
+			device.WorkerID = w.WorkerID
+			device.ObserverUserID = w.ObserverUserID
+		}
+		device.FirstSeenAt = now
+		device.LastSeenAt = now
+		if err := uc.deviceRepo.Save(ctx, device); err != nil {
+			return err
+		}
+	}
+
+	return uc.writeReport(devices, workers, prefixes, responding)
+}
+
+// probe returns the device at ip, or nil when no miner port answers. A host
+// that answers but that no driver recognises is returned with an empty Driver.
+// worker is the pool worker mapped to ip, nil when there is none.
+func (uc *DiscoverMinersUseCase) probe(ctx context.Context, ip string, worker *model.Worker) *model.DiscoveredDevice {
+	// A plain TCP connect weeds out empty addresses far quicker than the drivers'
+	// timeouts; the stock HTTP API and the cgminer API cover every driver
+	ports := []int{80, uc.cfg.App.CGMinerPort}
+	if err := probeTCPAny(ctx, ip, ports, uc.cfg.Discovery.ProbeTimeout); err != nil {
+		return nil
+	}
+
+	workerID := ""
+	if worker != nil {
+		workerID = worker.WorkerID
+	}
+	ctx = repository.WithMinerAuth(ctx, minerAuthFor(uc.cfg, workerID, ip))
+	driver, info, err := detectMiner(ctx, uc.drivers, ip)
+	if errors.Is(err, repository.ErrMinerUnauthorized) {
+		return &model.DiscoveredDevice{IP: ip, AuthFailed: true}
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		logger.Log.Debug("No driver recognises the host", zap.String("ip", ip), zap.Error(err))
+		return &model.DiscoveredDevice{IP: ip}
+	}
+
+	device := &model.DiscoveredDevice{IP: ip, Driver: driver.Name()}
+	if info != nil {
+		device.MinerType = info.Model
+		device.MinerVersion = info.Firmware
+	}
+	return device
+}
+
+func (uc *DiscoverMinersUseCase) writeReport(devices []*model.DiscoveredDevice, workers []*model.Worker, prefixes []netip.Prefix, responding map[string]bool) error {
+	filename := fmt.Sprintf("discovery_report_%s.csv", time.Now().Format("20060102_150405"))
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Add BOM for Excel compatibility
+	file.Write([]byte{0xEF, 0xBB, 0xBF})
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{
+		"Finding",
+		"IP",
+		"Driver",
+		"Worker ID",
+		"User Worker ID",
+		"Observer User ID",
+		"Miner Type",
+		"Miner Version",
+		"Auth Failed",
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	orphanDevices := 0
+	for _, device := range devices {
+		if device.WorkerID != "" {
+			continue
+		}
+		record := []string{
+			findingDeviceWithoutWorker,
+			device.IP,
+			device.Driver,
+			"",
+			"",
+			"",
+			device.MinerType,
+			device.MinerVersion,
+			fmt.Sprintf("%t", device.AuthFailed),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+		orphanDevices++
+	}
+
+	silentWorkers := 0
+	for _, w := range workers {
+		if w.IP == "" || responding[w.IP] {
+			continue
+		}
+		// Workers outside the swept ranges were not looked for
+		addr, err := netip.ParseAddr(w.IP)
+		if err != nil || !containsAddr(prefixes, addr) {
+			continue
+		}
+		record := []string{
+			findingWorkerWithoutDevice,
+			w.IP,
+			"",
+			w.WorkerID,
+			w.UserWorkerID,
+			w.ObserverUserID,
+			"",
+			"",
+			"",
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+		silentWorkers++
+	}
+
+	absPath, _ := filepath.Abs(filename)
+	logger.Log.Info("Discovery completed",
+		zap.String("file", absPath),
+		zap.Int("devices", len(devices)),
+		zap.Int("devicesWithoutWorker", orphanDevices),
+		zap.Int("workersWithoutDevice", silentWorkers))
+	return nil
+}
+
+// sweepHosts parses the CIDRs and lists their hosts, each once even when the
+// ranges overlap
+func sweepHosts(cidrs []string) ([]netip.Prefix, []netip.Addr, error) {
+	var prefixes []netip.Prefix
+	var hosts []netip.Addr
+	seen := make(map[netip.Addr]bool)
+	for _, cidr := range cidrs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+		for _, addr := range hostsOf(prefix) {
+			if !seen[addr] {
+				seen[addr] = true
+				hosts = append(hosts, addr)
+			}
+		}
+	}
+	return prefixes, hosts, nil
+}
+
+// hostsOf lists the usable host addresses of an IPv4 prefix, leaving out the
+// network and broadcast addresses unless the prefix is a /31 or /32
+func hostsOf(prefix netip.Prefix) []netip.Addr {
+	prefix = prefix.Masked()
+	var hosts []netip.Addr
+	for addr := prefix.Addr(); prefix.Contains(addr); addr = addr.Next() {
+		hosts = append(hosts, addr)
+	}
+	if prefix.Bits() < 31 && len(hosts) > 2 {
+		hosts = hosts[1 : len(hosts)-1]
+	}
+	return hosts
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package usecase
+
+import (
+	"context"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/beatyman/scan-miners/config"
+	"github.com/beatyman/scan-miners/internal/domain/model"
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+)
+
+// listen opens a port on 127.0.0.1 that accepts the discovery TCP probe
+func listen(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func newDiscoverFixture(t *testing.T, drivers ...repository.MinerDriver) *DiscoverMinersUseCase {
+	t.Helper()
+	cfg := config.Default()
+	// Only the cgminer port answers, as on a miner with its web UI disabled
+	cfg.App.CGMinerPort = listen(t)
+	cfg.Discovery.ProbeTimeout = time.Second
+	cfg.App.MinerCredentials = []config.MinerCredentialSet{
+		{Workers: []string{"1x1"}, Credentials: []config.MinerCredential{{User: "worker-user"}}},
+	}
+	return NewDiscoverMinersUseCase(cfg, &fakeWorkerRepo{}, nil, drivers)
+}
+
+func TestDiscoverProbeUsesDriversAndWorkerCredentials(t *testing.T) {
+	vnish := &fakeDriver{name: model.DriverVNish}
+	cgminer := &fakeDriver{name: model.DriverCGMiner, detect: true, info: &model.DeviceInfo{Model: "Antminer S19", Firmware: "bmminer 1.0"}}
+	uc := newDiscoverFixture(t, vnish, cgminer)
+
+	device := uc.probe(context.Background(), "127.0.0.1", &model.Worker{WorkerID: "1x1", IP: "127.0.0.1"})
+	if device == nil {
+		t.Fatal("probe() = nil, want the device recognised by the cgminer driver")
+	}
+	if device.Driver != model.DriverCGMiner || device.MinerType != "Antminer S19" || device.MinerVersion != "bmminer 1.0" {
+		t.Errorf("unexpected device: %+v", device)
+	}
+	if want := []string{"worker-user", "root"}; !slices.Equal(cgminer.users, want) {
+		t.Errorf("credentials tried = %q, want %q", cgminer.users, want)
+	}
+}
+
+func TestDiscoverProbeAuthFailed(t *testing.T) {
+	antminer := &fakeDriver{name: model.DriverAntminer, detectErr: repository.ErrMinerUnauthorized}
+	uc := newDiscoverFixture(t, antminer, &fakeDriver{name: model.DriverCGMiner})
+
+	device := uc.probe(context.Background(), "127.0.0.1", nil)
+	if device == nil || !device.AuthFailed {
+		t.Fatalf("probe() = %+v, want an auth failure", device)
+	}
+}
+
+func TestDiscoverProbeUnrecognised(t *testing.T) {
+	uc := newDiscoverFixture(t, &fakeDriver{name: model.DriverCGMiner})
+	device := uc.probe(context.Background(), "127.0.0.1", nil)
+	if device == nil || device.Driver != "" || device.AuthFailed {
+		t.Errorf("probe() = %+v, want a device without a driver when none recognises the host", device)
+	}
+}
+
+func TestDiscoverProbeNoAnswer(t *testing.T) {
+	uc := newDiscoverFixture(t, &fakeDriver{name: model.DriverCGMiner, detect: true})
+	uc.cfg.App.CGMinerPort = 1 // nothing listens there
+	if device := uc.probe(context.Background(), "127.0.0.1", nil); device != nil {
+		t.Errorf("probe() = %+v, want nil when no port answers", device)
+	}
+}
+
+func TestSweepHostsOverlap(t *testing.T) {
+	_, hosts, err := sweepHosts([]string{"10.0.0.0/30", "10.0.0.2/31", "10.0.0.8/32"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, addr := range hosts {
+		got = append(got, addr.String())
+	}
+	if want := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.8"}; !slices.Equal(got, want) {
+		t.Errorf("sweepHosts() = %q, want %q", got, want)
+	}
+}
@@ -31,7 +31,7 @@ func (uc *ExportHashrateAnalysisUseCase) Execute(ctx context.Context) error {
 	logger.Log.Info("Starting hashrate analysis export")
 
 	// 1. Fetch all workers
-	workers, err := uc.workerRepo.FindAll(ctx)
+	workers, err := uc.workerRepo.FindActive(ctx)
 	if err != nil {
 		return err
 	}
@@ -76,7 +76,7 @@ func (uc *ExportHashrateAnalysisUseCase) Execute(ctx context.Context) error {
 		
 		// If worker has IP, try to fetch local stats from DB
 		// Since we stored them by WorkerID, we use that
-		stats, err := uc.minerStatsRepo.FindLatestByWorkerID(ctx, worker.WorkerID)
+		stats, err := uc.minerStatsRepo.FindLatestByWorkerID(ctx, worker.ObserverUserID, worker.WorkerID)
 		if err == nil && stats != nil {
 			minerType = stats.MinerType
 			rateAvg = convertToTHs(stats.RateAvg, stats.RateUnit)
@@ -0,0 +1,87 @@
+package usecase
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+	"github.com/beatyman/scan-miners/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type ExportAsicHealthUseCase struct {
+	minerStatsRepo repository.MinerStatsRepository
+}
+
+func NewExportAsicHealthUseCase(minerStatsRepo repository.MinerStatsRepository) *ExportAsicHealthUseCase {
+	return &ExportAsicHealthUseCase{
+		minerStatsRepo: minerStatsRepo,
+	}
+}
+
+// Execute writes every chain with failed chips in the latest snapshot of each
+// miner scanned after since to CSV, worst chains first
+func (uc *ExportAsicHealthUseCase) Execute(ctx context.Context, since time.Time) error {
+	logger.Log.Info("Starting asic health export", zap.Time("since", since))
+
+	chains, err := uc.minerStatsRepo.FindFailedAsics(ctx, since)
+	if err != nil {
+		return err
+	}
+
+	filename := fmt.Sprintf("asic_health_%s.csv", time.Now().Format("20060102_150405"))
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Add BOM for Excel compatibility
+	file.Write([]byte{0xEF, 0xBB, 0xBF})
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{
+		"Worker ID",
+		"IP",
+		"Miner Type",
+		"Chain",
+		"SN",
+		"Asic Num",
+		"OK Chips",
+		"Failed Chips",
+		"Failed Positions",
+		"Scanned At",
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, c := range chains {
+		record := []string{
+			c.WorkerID,
+			c.IP,
+			c.MinerType,
+			strconv.Itoa(c.ChainIndex),
+			c.SN,
+			strconv.Itoa(c.AsicNum),
+			strconv.Itoa(c.AsicOK),
+			strconv.Itoa(c.AsicFailed),
+			c.AsicFailedPositions,
+			c.ScannedAt.Format(time.DateTime),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	absPath, _ := filepath.Abs(filename)
+	logger.Log.Info("Export completed successfully", zap.String("file", absPath), zap.Int("chains", len(chains)))
+	return nil
+}
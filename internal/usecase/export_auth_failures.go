@@ -0,0 +1,88 @@
+package usecase
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/beatyman/scan-miners/internal/domain/model"
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+	"github.com/beatyman/scan-miners/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type ExportAuthFailuresUseCase struct {
+	scanRunRepo repository.ScanRunRepository
+}
+
+func NewExportAuthFailuresUseCase(scanRunRepo repository.ScanRunRepository) *ExportAuthFailuresUseCase {
+	return &ExportAuthFailuresUseCase{
+		scanRunRepo: scanRunRepo,
+	}
+}
+
+// Execute writes every miner that rejected all of its configured credentials
+// in the latest finished scan run to CSV, with the users that scan tried
+func (uc *ExportAuthFailuresUseCase) Execute(ctx context.Context) error {
+	logger.Log.Info("Starting auth failures export")
+
+	run, err := uc.scanRunRepo.FindLatestFinished(ctx)
+	if err != nil {
+		return err
+	}
+	if run == nil {
+		return errors.New("no finished scan run yet, run scan-miners first")
+	}
+	outcomes, err := uc.scanRunRepo.FindOutcomes(ctx, run.ID, model.ScanReasonUnauthorized)
+	if err != nil {
+		return err
+	}
+
+	filename := fmt.Sprintf("auth_failures_%s.csv", time.Now().Format("20060102_150405"))
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Add BOM for Excel compatibility
+	file.Write([]byte{0xEF, 0xBB, 0xBF})
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{
+		"Worker ID",
+		"IP",
+		"Users Tried",
+		"Error",
+		"Scan Run",
+		"Scanned At",
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, o := range outcomes {
+		record := []string{
+			o.WorkerID,
+			o.IP,
+			o.UsersTried,
+			o.Error,
+			strconv.FormatUint(uint64(run.ID), 10),
+			o.CreatedAt.Format(time.DateTime),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	absPath, _ := filepath.Abs(filename)
+	logger.Log.Info("Export completed successfully", zap.String("file", absPath), zap.Uint("scanRunId", run.ID), zap.Int("miners", len(outcomes)))
+	return nil
+}
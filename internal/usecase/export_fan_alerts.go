@@ -0,0 +1,83 @@
+package usecase
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+	"github.com/beatyman/scan-miners/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type ExportFanAlertsUseCase struct {
+	minerStatsRepo repository.MinerStatsRepository
+}
+
+func NewExportFanAlertsUseCase(minerStatsRepo repository.MinerStatsRepository) *ExportFanAlertsUseCase {
+	return &ExportFanAlertsUseCase{
+		minerStatsRepo: minerStatsRepo,
+	}
+}
+
+// Execute writes every miner whose latest snapshot after since has a missing
+// or slow fan to CSV
+func (uc *ExportFanAlertsUseCase) Execute(ctx context.Context, since time.Time) error {
+	logger.Log.Info("Starting fan alerts export", zap.Time("since", since))
+
+	stats, err := uc.minerStatsRepo.FindFanAlerts(ctx, since)
+	if err != nil {
+		return err
+	}
+
+	filename := fmt.Sprintf("fan_alerts_%s.csv", time.Now().Format("20060102_150405"))
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Add BOM for Excel compatibility
+	file.Write([]byte{0xEF, 0xBB, 0xBF})
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{
+		"Worker ID",
+		"IP",
+		"Miner Type",
+		"Alert",
+		"Fan Num",
+		"Fan Speeds (RPM)",
+		"Min RPM",
+		"Scanned At",
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, s := range stats {
+		record := []string{
+			s.WorkerID,
+			s.IP,
+			s.MinerType,
+			s.FanAlert,
+			strconv.Itoa(s.FanNum),
+			s.FanSpeeds,
+			strconv.Itoa(s.FanMinRPM),
+			s.CreatedAt.Format(time.DateTime),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	absPath, _ := filepath.Abs(filename)
+	logger.Log.Info("Export completed successfully", zap.String("file", absPath), zap.Int("miners", len(stats)))
+	return nil
+}
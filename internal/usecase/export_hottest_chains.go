@@ -0,0 +1,85 @@
+package usecase
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+	"github.com/beatyman/scan-miners/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type ExportHottestChainsUseCase struct {
+	minerStatsRepo repository.MinerStatsRepository
+}
+
+func NewExportHottestChainsUseCase(minerStatsRepo repository.MinerStatsRepository) *ExportHottestChainsUseCase {
+	return &ExportHottestChainsUseCase{
+		minerStatsRepo: minerStatsRepo,
+	}
+}
+
+// Execute writes the limit hottest chains of the fleet to CSV, taken from each
+// miner's latest snapshot newer than since
+func (uc *ExportHottestChainsUseCase) Execute(ctx context.Context, since time.Time, limit int) error {
+	logger.Log.Info("Starting hottest chains export", zap.Time("since", since), zap.Int("limit", limit))
+
+	chains, err := uc.minerStatsRepo.FindHottestChains(ctx, since, limit)
+	if err != nil {
+		return err
+	}
+
+	filename := fmt.Sprintf("hottest_chains_%s.csv", time.Now().Format("20060102_150405"))
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Add BOM for Excel compatibility
+	file.Write([]byte{0xEF, 0xBB, 0xBF})
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{
+		"Worker ID",
+		"IP",
+		"Miner Type",
+		"Chain",
+		"Chip Max (°C)",
+		"Chip Avg (°C)",
+		"PCB Max (°C)",
+		"PIC Max (°C)",
+		"Scanned At",
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, c := range chains {
+		record := []string{
+			c.WorkerID,
+			c.IP,
+			c.MinerType,
+			strconv.Itoa(c.ChainIndex),
+			fmt.Sprintf("%.1f", c.TempChipMax),
+			fmt.Sprintf("%.1f", c.TempChipAvg),
+			fmt.Sprintf("%.1f", c.TempPcbMax),
+			fmt.Sprintf("%.1f", c.TempPicMax),
+			c.ScannedAt.Format(time.DateTime),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	absPath, _ := filepath.Abs(filename)
+	logger.Log.Info("Export completed successfully", zap.String("file", absPath), zap.Int("chains", len(chains)))
+	return nil
+}
@@ -6,9 +6,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/beatyman/scan-miners/internal/domain/model"
 	"github.com/beatyman/scan-miners/internal/domain/repository"
 	"github.com/beatyman/scan-miners/pkg/logger"
 	"go.uber.org/zap"
@@ -42,7 +44,7 @@ func (uc *ExportUnderperformingMinersUseCase) Execute(ctx context.Context) error
 	logger.Log.Info("Starting underperforming miners export")
 
 	// 1. Fetch all workers
-	workers, err := uc.workerRepo.FindAll(ctx)
+	workers, err := uc.workerRepo.FindActive(ctx)
 	if err != nil {
 		return err
 	}
@@ -72,6 +74,9 @@ func (uc *ExportUnderperformingMinersUseCase) Execute(ctx context.Context) error
 		"Rate Ideal (TH/s)",
 		"Rated Hashrate (TH/s)",
 		"Difference (TH/s)",
+		"Miner Mode",
+		"Freq Level",
+		"Cause",
 	}
 	if err := writer.Write(header); err != nil {
 		return err
@@ -80,7 +85,7 @@ func (uc *ExportUnderperformingMinersUseCase) Execute(ctx context.Context) error
 	count := 0
 	// 4. Process each worker
 	for _, worker := range workers {
-		stats, err := uc.minerStatsRepo.FindLatestByWorkerID(ctx, worker.WorkerID)
+		stats, err := uc.minerStatsRepo.FindLatestByWorkerID(ctx, worker.ObserverUserID, worker.WorkerID)
 		if err != nil || stats == nil {
 			continue
 		}
@@ -112,6 +117,9 @@ func (uc *ExportUnderperformingMinersUseCase) Execute(ctx context.Context) error
 				fmt.Sprintf("%.2f", rateIdeal),
 				fmt.Sprintf("%.2f", rated),
 				fmt.Sprintf("%.2f", diff),
+				strconv.Itoa(stats.MinerMode),
+				strconv.Itoa(stats.FreqLevel),
+				underperformingCause(stats),
 			}
 			if err := writer.Write(record); err != nil {
 				return err
@@ -124,3 +132,16 @@ func (uc *ExportUnderperformingMinersUseCase) Execute(ctx context.Context) error
 	logger.Log.Info("Export completed successfully", zap.String("file", absPath), zap.Int("underperforming_count", count))
 	return nil
 }
+
+// underperformingCause tells a miner that was deliberately slept or
+// underclocked (non-zero miner-mode, freq-level below 100) from one that
+// falls short at full settings
+func underperformingCause(stats *model.MinerStats) string {
+	switch {
+	case stats.MinerMode != 0:
+		return "miner_mode"
+	case stats.FreqLevel > 0 && stats.FreqLevel < 100:
+		return "underclocked"
+	}
+	return "fault"
+}
@@ -0,0 +1,116 @@
+package usecase
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/beatyman/scan-miners/config"
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+	"github.com/beatyman/scan-miners/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type ExportWorkerHistoryUseCase struct {
+	cfg          *config.Config
+	snapshotRepo repository.WorkerHashrateSnapshotRepository
+}
+
+func NewExportWorkerHistoryUseCase(cfg *config.Config, snapshotRepo repository.WorkerHashrateSnapshotRepository) *ExportWorkerHistoryUseCase {
+	return &ExportWorkerHistoryUseCase{
+		cfg:          cfg,
+		snapshotRepo: snapshotRepo,
+	}
+}
+
+// Execute writes the pool-side hashrate series of one worker in [from, to) to CSV.
+// Worker IDs are only unique within an account, so observerUserID and coinType
+// pick the account; either may be left empty when the configuration leaves a
+// single candidate.
+func (uc *ExportWorkerHistoryUseCase) Execute(ctx context.Context, observerUserID, coinType, workerID string, from, to time.Time) error {
+	observerUserID, coinType, err := uc.resolveAccount(observerUserID, coinType)
+	if err != nil {
+		return err
+	}
+	logger.Log.Info("Starting worker history export",
+		zap.String("observerUserId", observerUserID), zap.String("coinType", coinType),
+		zap.String("workerID", workerID), zap.Time("from", from), zap.Time("to", to))
+
+	snapshots, err := uc.snapshotRepo.FindByWorkerID(ctx, observerUserID, coinType, workerID, from, to)
+	if err != nil {
+		return err
+	}
+
+	filename := fmt.Sprintf("worker_history_%s_%s_%s.csv", observerUserID, workerID, time.Now().Format("20060102_150405"))
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Add BOM for Excel compatibility
+	file.Write([]byte{0xEF, 0xBB, 0xBF})
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{
+		"Time",
+		"Sync Run",
+		"Worker Status",
+		"Hs Last 10Min (TH/s)",
+		"Hs Last 1H (TH/s)",
+		"Hs Last 1D (TH/s)",
+		"Reject Ratio",
+		"Reconnect Last 24h",
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, snap := range snapshots {
+		record := []string{
+			snap.CreatedAt.Format(time.DateTime),
+			strconv.FormatUint(uint64(snap.SyncRunID), 10),
+			strconv.Itoa(snap.WorkerStatus),
+			fmt.Sprintf("%.2f", convertToTHs(snap.HsLast10Min, snap.HsLast10MinUnit)),
+			fmt.Sprintf("%.2f", convertToTHs(snap.HsLast1H, snap.HsLast1HUnit)),
+			fmt.Sprintf("%.2f", convertToTHs(snap.HsLast1D, snap.HsLast1DUnit)),
+			snap.RejectRatio,
+			strconv.Itoa(snap.ReconnectLast24h),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	absPath, _ := filepath.Abs(filename)
+	logger.Log.Info("Export completed successfully", zap.String("file", absPath), zap.Int("snapshots", len(snapshots)))
+	return nil
+}
+
+// resolveAccount fills in whatever part of the account the caller left out from
+// the configured accounts, and refuses to guess when more than one matches
+func (uc *ExportWorkerHistoryUseCase) resolveAccount(observerUserID, coinType string) (string, string, error) {
+	if observerUserID != "" && coinType != "" {
+		return observerUserID, coinType, nil
+	}
+	var matches []config.AntpoolAccount
+	for _, acc := range uc.cfg.App.AntpoolAccounts {
+		if (observerUserID == "" || acc.ObserverUserID == observerUserID) && (coinType == "" || acc.CoinType == coinType) {
+			matches = append(matches, acc)
+		}
+	}
+	switch len(matches) {
+	case 1:
+		return matches[0].ObserverUserID, matches[0].CoinType, nil
+	case 0:
+		return "", "", fmt.Errorf("no antpool account configured for observer user %q and coin %q, pass both -account and -coin", observerUserID, coinType)
+	default:
+		return "", "", fmt.Errorf("%d configured accounts match observer user %q and coin %q, pass -account and -coin", len(matches), observerUserID, coinType)
+	}
+}
@@ -0,0 +1,41 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/beatyman/scan-miners/config"
+)
+
+func TestExportWorkerHistoryResolveAccount(t *testing.T) {
+	cfg := config.Default()
+	cfg.App.AntpoolAccounts = []config.AntpoolAccount{
+		{ObserverUserID: "site-a", CoinType: "BTC"},
+		{ObserverUserID: "site-b", CoinType: "BTC"},
+		{ObserverUserID: "site-b", CoinType: "LTC"},
+	}
+	uc := NewExportWorkerHistoryUseCase(cfg, &fakeSnapshotRepo{})
+
+	tests := []struct {
+		account, coin         string
+		wantAccount, wantCoin string
+		wantErr               bool
+	}{
+		{"site-a", "", "site-a", "BTC", false},
+		{"site-b", "LTC", "site-b", "LTC", false},
+		{"", "LTC", "site-b", "LTC", false},
+		{"retired", "BTC", "retired", "BTC", false},
+		{"site-b", "", "", "", true},
+		{"", "", "", "", true},
+		{"retired", "", "", "", true},
+	}
+	for _, tt := range tests {
+		account, coin, err := uc.resolveAccount(tt.account, tt.coin)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("resolveAccount(%q, %q) error = %v, wantErr %v", tt.account, tt.coin, err, tt.wantErr)
+			continue
+		}
+		if account != tt.wantAccount || coin != tt.wantCoin {
+			t.Errorf("resolveAccount(%q, %q) = %q, %q, want %q, %q", tt.account, tt.coin, account, coin, tt.wantAccount, tt.wantCoin)
+		}
+	}
+}
@@ -0,0 +1,377 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/beatyman/scan-miners/internal/domain/model"
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+)
+
+// fakeWorkerRepo keeps workers in memory, unique per worker ID, account and coin like the workers table
+type fakeWorkerRepo struct {
+	mu      sync.Mutex
+	workers []*model.Worker
+	nextID  uint
+}
+
+func (r *fakeWorkerRepo) find(w *model.Worker) *model.Worker {
+	for _, stored := range r.workers {
+		if stored.WorkerID == w.WorkerID && stored.ObserverUserID == w.ObserverUserID && stored.CoinType == w.CoinType {
+			return stored
+		}
+	}
+	return nil
+}
+
+func (r *fakeWorkerRepo) Save(ctx context.Context, worker *model.Worker) error {
+	return r.SaveBatch(ctx, []*model.Worker{worker})
+}
+
+func (r *fakeWorkerRepo) SaveBatch(ctx context.Context, workers []*model.Worker) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, w := range workers {
+		if stored := r.find(w); stored != nil {
+			// Like the upsert, keep what scan-miners wrote
+			w.ID = stored.ID
+			w.MinerDriver, w.MinerModel, w.StatsEndpoint = stored.MinerDriver, stored.MinerModel, stored.StatsEndpoint
+			w.DriverCheckedAt, w.MinerCredential = stored.DriverCheckedAt, stored.MinerCredential
+			*stored = *w
+			continue
+		}
+		r.nextID++
+		w.ID = r.nextID
+		copied := *w
+		r.workers = append(r.workers, &copied)
+	}
+	return nil
+}
+
+func (r *fakeWorkerRepo) FindAll(ctx context.Context) ([]*model.Worker, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var workers []*model.Worker
+	for _, w := range r.workers {
+		copied := *w
+		workers = append(workers, &copied)
+	}
+	return workers, nil
+}
+
+func (r *fakeWorkerRepo) FindActive(ctx context.Context) ([]*model.Worker, error) {
+	all, _ := r.FindAll(ctx)
+	var workers []*model.Worker
+	for _, w := range all {
+		if w.Active {
+			workers = append(workers, w)
+		}
+	}
+	return workers, nil
+}
+
+func (r *fakeWorkerRepo) FindByAccount(ctx context.Context, observerUserID, coinType string) ([]*model.Worker, error) {
+	all, _ := r.FindAll(ctx)
+	var workers []*model.Worker
+	for _, w := range all {
+		if w.ObserverUserID == observerUserID && w.CoinType == coinType {
+			workers = append(workers, w)
+		}
+	}
+	return workers, nil
+}
+
+func (r *fakeWorkerRepo) update(id uint, fn func(w *model.Worker)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, w := range r.workers {
+		if w.ID == id {
+			fn(w)
+			return nil
+		}
+	}
+	return errors.New("worker not found")
+}
+
+func (r *fakeWorkerRepo) MarkInactive(ctx context.Context, ids []uint) error {
+	for _, id := range ids {
+		if err := r.update(id, func(w *model.Worker) { w.Active = false }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *fakeWorkerRepo) UpdateIP(ctx context.Context, id uint, ip string) error {
+	return r.update(id, func(w *model.Worker) { w.IP = ip })
+}
+
+func (r *fakeWorkerRepo) UpdateMinerDriver(ctx context.Context, id uint, driver, minerModel string) error {
+	return r.update(id, func(w *model.Worker) {
+		now := time.Now()
+		w.MinerDriver, w.MinerModel, w.StatsEndpoint, w.DriverCheckedAt = driver, minerModel, "", &now
+	})
+}
+
+func (r *fakeWorkerRepo) UpdateStatsEndpoint(ctx context.Context, id uint, endpoint string) error {
+	return r.update(id, func(w *model.Worker) { w.StatsEndpoint = endpoint })
+}
+
+func (r *fakeWorkerRepo) UpdateMinerCredential(ctx context.Context, id uint, fingerprint string) error {
+	return r.update(id, func(w *model.Worker) { w.MinerCredential = fingerprint })
+}
+
+// byWorkerID returns the first stored worker with workerID, for assertions
+func (r *fakeWorkerRepo) byWorkerID(workerID string) *model.Worker {
+	all, _ := r.FindAll(context.Background())
+	for _, w := range all {
+		if w.WorkerID == workerID {
+			return w
+		}
+	}
+	return nil
+}
+
+type fakeSyncRunRepo struct {
+	runs []*model.WorkerSyncRun
+}
+
+func (r *fakeSyncRunRepo) Create(ctx context.Context, run *model.WorkerSyncRun) error {
+	run.ID = uint(len(r.runs) + 1)
+	r.runs = append(r.runs, run)
+	return nil
+}
+
+func (r *fakeSyncRunRepo) Update(ctx context.Context, run *model.WorkerSyncRun) error {
+	return nil
+}
+
+type fakeSnapshotRepo struct {
+	snapshots []*model.WorkerHashrateSnapshot
+}
+
+// SaveBatch enforces the (sync_run_id, worker_id) unique index
+func (r *fakeSnapshotRepo) SaveBatch(ctx context.Context, snapshots []*model.WorkerHashrateSnapshot) error {
+	for _, s := range snapshots {
+		for _, existing := range r.snapshots {
+			if existing.SyncRunID == s.SyncRunID && existing.WorkerID == s.WorkerID {
+				return fmt.Errorf("duplicate entry '%d-%s' for key 'idx_snapshot_run_worker'", s.SyncRunID, s.WorkerID)
+			}
+		}
+		r.snapshots = append(r.snapshots, s)
+	}
+	return nil
+}
+
+func (r *fakeSnapshotRepo) FindByWorkerID(ctx context.Context, observerUserID, coinType, workerID string, from, to time.Time) ([]*model.WorkerHashrateSnapshot, error) {
+	var snapshots []*model.WorkerHashrateSnapshot
+	for _, s := range r.snapshots {
+		if s.ObserverUserID == observerUserID && s.CoinType == coinType && s.WorkerID == workerID {
+			snapshots = append(snapshots, s)
+		}
+	}
+	return snapshots, nil
+}
+
+// fakePool serves pages of workers; failAt makes the walk fail when it reaches that page
+type fakePool struct {
+	pages      [][]model.PoolWorker
+	status     *model.PoolWorkerStatus
+	failAt     int
+	startPages []int
+}
+
+var errPoolDown = errors.New("pool down")
+
+func (p *fakePool) ListWorkers(ctx context.Context, account model.PoolAccount, startPage int, fn func(page *model.PoolWorkerPage) error) error {
+	p.startPages = append(p.startPages, startPage)
+	total := 0
+	for _, page := range p.pages {
+		total += len(page)
+	}
+	for n := startPage; n <= len(p.pages); n++ {
+		if n == p.failAt {
+			return errPoolDown
+		}
+		page := &model.PoolWorkerPage{PageNum: n, TotalPage: len(p.pages), TotalRecord: total, Status: p.status, Workers: p.pages[n-1]}
+		if err := fn(page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fakeDriver recognises every miner when detect is set; detectErr and statsErr
+// make the probe or the read fail. It records the calls and the credentials it got.
+type fakeDriver struct {
+	name      string
+	detect    bool
+	detectErr error
+	info      *model.DeviceInfo
+	statsErr  error
+	// fetch, when set, runs at the start of every FetchStats
+	fetch func(ctx context.Context) error
+
+	mu      sync.Mutex
+	detects int
+	reads   int
+	users   []string
+}
+
+func (d *fakeDriver) Name() string {
+	return d.name
+}
+
+func (d *fakeDriver) Detect(ctx context.Context, ip string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.detects++
+	if auth := repository.MinerAuthFrom(ctx); auth != nil {
+		for _, cred := range auth.Credentials {
+			d.users = append(d.users, cred.User)
+		}
+	}
+	return d.detect, d.detectErr
+}
+
+func (d *fakeDriver) FetchInfo(ctx context.Context, ip string) (*model.DeviceInfo, error) {
+	if d.info == nil {
+		return nil, errors.New("no info")
+	}
+	return d.info, nil
+}
+
+func (d *fakeDriver) FetchStats(ctx context.Context, ip string) (*model.MinerStats, error) {
+	if d.fetch != nil {
+		if err := d.fetch(ctx); err != nil {
+			return nil, err
+		}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.reads++
+	if d.statsErr != nil {
+		return nil, d.statsErr
+	}
+	return &model.MinerStats{Source: d.name}, nil
+}
+
+type fakeScanRunRepo struct {
+	mu       sync.Mutex
+	runs     []*model.ScanRun
+	outcomes []*model.ScanOutcome
+}
+
+func (r *fakeScanRunRepo) Create(ctx context.Context, run *model.ScanRun) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	run.ID = uint(len(r.runs) + 1)
+	r.runs = append(r.runs, run)
+	return nil
+}
+
+func (r *fakeScanRunRepo) Update(ctx context.Context, run *model.ScanRun) error {
+	return nil
+}
+
+func (r *fakeScanRunRepo) SaveOutcomes(ctx context.Context, outcomes []*model.ScanOutcome) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.outcomes = append(r.outcomes, outcomes...)
+	return nil
+}
+
+func (r *fakeScanRunRepo) FindLatestFinished(ctx context.Context) (*model.ScanRun, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := len(r.runs) - 1; i >= 0; i-- {
+		if r.runs[i].FinishedAt != nil {
+			return r.runs[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeScanRunRepo) FindOutcomes(ctx context.Context, scanRunID uint, reason string) ([]*model.ScanOutcome, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var outcomes []*model.ScanOutcome
+	for _, o := range r.outcomes {
+		if o.ScanRunID == scanRunID && (reason == "" || o.Reason == reason) {
+			outcomes = append(outcomes, o)
+		}
+	}
+	return outcomes, nil
+}
+
+// fakeMinerStatsRepo only stores snapshots; the queries of other use cases are not implemented
+type fakeMinerStatsRepo struct {
+	repository.MinerStatsRepository
+
+	mu    sync.Mutex
+	saved []*model.MinerStats
+}
+
+func (r *fakeMinerStatsRepo) Save(ctx context.Context, stats *model.MinerStats) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stats.ID = uint(len(r.saved) + 1)
+	r.saved = append(r.saved, stats)
+	return nil
+}
+
+type fakeHashboardRepo struct {
+	boards map[string]*model.Hashboard
+	moves  []*model.HashboardMove
+}
+
+func (r *fakeHashboardRepo) FindBySN(ctx context.Context, sn string) (*model.Hashboard, error) {
+	if board, ok := r.boards[sn]; ok {
+		copied := *board
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (r *fakeHashboardRepo) Save(ctx context.Context, board *model.Hashboard) error {
+	if r.boards == nil {
+		r.boards = make(map[string]*model.Hashboard)
+	}
+	copied := *board
+	r.boards[board.SN] = &copied
+	return nil
+}
+
+func (r *fakeHashboardRepo) CreateMove(ctx context.Context, move *model.HashboardMove) error {
+	r.moves = append(r.moves, move)
+	return nil
+}
+
+func (r *fakeHashboardRepo) FindMovesSince(ctx context.Context, since time.Time) ([]*model.HashboardMove, error) {
+	return r.moves, nil
+}
+
+type fakeChipLayoutRepo struct {
+	saveErr error // returned by Save while set
+	saves   int
+	layouts map[string]*model.ChipLayout
+}
+
+func (r *fakeChipLayoutRepo) Save(ctx context.Context, layout *model.ChipLayout) error {
+	r.saves++
+	if r.saveErr != nil {
+		return r.saveErr
+	}
+	if r.layouts == nil {
+		r.layouts = make(map[string]*model.ChipLayout)
+	}
+	r.layouts[layout.MinerType] = layout
+	return nil
+}
+
+func (r *fakeChipLayoutRepo) FindByMinerType(ctx context.Context, minerType string) (*model.ChipLayout, error) {
+	return r.layouts[minerType], nil
+}
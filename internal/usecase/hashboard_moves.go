@@ -0,0 +1,122 @@
+package usecase
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+	"github.com/beatyman/scan-miners/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// Findings written to the hashboard report
+const (
+	findingMovedMiner      = "moved_miner"
+	findingMovedSlot       = "moved_slot"
+	findingEEPROMNotLoaded = "eeprom_not_loaded"
+)
+
+type HashboardMovesUseCase struct {
+	hashboardRepo  repository.HashboardRepository
+	minerStatsRepo repository.MinerStatsRepository
+}
+
+func NewHashboardMovesUseCase(hashboardRepo repository.HashboardRepository, minerStatsRepo repository.MinerStatsRepository) *HashboardMovesUseCase {
+	return &HashboardMovesUseCase{
+		hashboardRepo:  hashboardRepo,
+		minerStatsRepo: minerStatsRepo,
+	}
+}
+
+// Execute writes the hashboards that changed miner or slot since the given
+// time, and the chains whose latest snapshot reports eeprom_loaded false, to CSV
+func (uc *HashboardMovesUseCase) Execute(ctx context.Context, since time.Time) error {
+	logger.Log.Info("Starting hashboard moves report", zap.Time("since", since))
+
+	moves, err := uc.hashboardRepo.FindMovesSince(ctx, since)
+	if err != nil {
+		return err
+	}
+	unloaded, err := uc.minerStatsRepo.FindEEPROMNotLoaded(ctx, since)
+	if err != nil {
+		return err
+	}
+
+	filename := fmt.Sprintf("hashboard_moves_%s.csv", time.Now().Format("20060102_150405"))
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Add BOM for Excel compatibility
+	file.Write([]byte{0xEF, 0xBB, 0xBF})
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{
+		"Finding",
+		"SN",
+		"From Worker ID",
+		"From IP",
+		"From Slot",
+		"To Worker ID",
+		"To IP",
+		"To Slot",
+		"Time",
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, m := range moves {
+		finding := findingMovedMiner
+		if m.FromIP == m.ToIP {
+			finding = findingMovedSlot
+		}
+		record := []string{
+			finding,
+			m.SN,
+			m.FromWorkerID,
+			m.FromIP,
+			strconv.Itoa(m.FromChainIndex),
+			m.ToWorkerID,
+			m.ToIP,
+			strconv.Itoa(m.ToChainIndex),
+			m.DetectedAt.Format(time.DateTime),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	for _, c := range unloaded {
+		record := []string{
+			findingEEPROMNotLoaded,
+			c.SN,
+			"",
+			"",
+			"",
+			c.WorkerID,
+			c.IP,
+			strconv.Itoa(c.ChainIndex),
+			c.ScannedAt.Format(time.DateTime),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	absPath, _ := filepath.Abs(filename)
+	logger.Log.Info("Export completed successfully",
+		zap.String("file", absPath),
+		zap.Int("moves", len(moves)),
+		zap.Int("eepromNotLoaded", len(unloaded)))
+	return nil
+}
@@ -0,0 +1,14 @@
+package usecase
+
+import (
+	"os"
+	"testing"
+
+	"github.com/beatyman/scan-miners/pkg/logger"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	logger.Log = zap.NewNop()
+	os.Exit(m.Run())
+}
@@ -0,0 +1,111 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/beatyman/scan-miners/config"
+	"github.com/beatyman/scan-miners/internal/domain/model"
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+	"github.com/beatyman/scan-miners/pkg/logger"
+	"github.com/beatyman/scan-miners/pkg/transcript"
+	"go.uber.org/zap"
+)
+
+// reparseBatchSize is the number of snapshots loaded per query
+const reparseBatchSize = 500
+
+type ReparseUseCase struct {
+	cfg            *config.Config
+	minerStatsRepo repository.MinerStatsRepository
+	rawArchive     repository.RawArchive
+	driversByName  map[string]repository.MinerDriver
+}
+
+func NewReparseUseCase(cfg *config.Config, minerStatsRepo repository.MinerStatsRepository, rawArchive repository.RawArchive, drivers []repository.MinerDriver) *ReparseUseCase {
+	byName := make(map[string]repository.MinerDriver, len(drivers))
+	for _, d := range drivers {
+		byName[d.Name()] = d
+	}
+	return &ReparseUseCase{
+		cfg:            cfg,
+		minerStatsRepo: minerStatsRepo,
+		rawArchive:     rawArchive,
+		driversByName:  byName,
+	}
+}
+
+// Execute parses the archived raw responses of every snapshot taken after
+// since (of the miner at ip, when set) again with the driver that read them,
+// and overwrites the derived columns and chains of the stored snapshot
+func (uc *ReparseUseCase) Execute(ctx context.Context, since time.Time, ip string) error {
+	if uc.rawArchive == nil {
+		return fmt.Errorf("app.raw_archive_dir is not set, there is nothing to reparse")
+	}
+	logger.Log.Info("Starting reparse of archived miner responses", zap.Time("since", since), zap.String("ip", ip))
+
+	var afterID uint
+	reparsed, failed := 0, 0
+	for {
+		batch, err := uc.minerStatsRepo.FindArchived(ctx, since, ip, afterID, reparseBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		for _, stored := range batch {
+			afterID = stored.ID
+			if err := uc.reparse(ctx, stored); err != nil {
+				logger.Log.Warn("Failed to reparse snapshot", zap.Uint("id", stored.ID), zap.String("ip", stored.IP), zap.Error(err))
+				failed++
+				continue
+			}
+			reparsed++
+		}
+		logger.Log.Info("Reparse progress", zap.Uint("lastID", afterID), zap.Int("reparsed", reparsed), zap.Int("failed", failed))
+	}
+
+	logger.Log.Info("Reparse completed", zap.Int("reparsed", reparsed), zap.Int("failed", failed))
+	return nil
+}
+
+func (uc *ReparseUseCase) reparse(ctx context.Context, stored *model.MinerStats) error {
+	driver, ok := uc.driversByName[stored.Source]
+	if !ok {
+		return fmt.Errorf("miner driver %q is not available", stored.Source)
+	}
+
+	raw, err := uc.rawArchive.Get(ctx, stored.RawSHA256)
+	if err != nil {
+		return err
+	}
+	t, err := transcript.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("archive %s: %w", stored.RawSHA256, err)
+	}
+
+	// The driver answers from the transcript; nothing is sent to the miner
+	minerStats, err := driver.FetchStats(transcript.Replay(ctx, t), stored.IP)
+	if err != nil {
+		return err
+	}
+
+	minerStats.ID = stored.ID
+	minerStats.WorkerID = stored.WorkerID
+	minerStats.IP = stored.IP
+	minerStats.RawSHA256 = stored.RawSHA256
+	minerStats.CreatedAt = stored.CreatedAt
+	if minerStats.MinerType == "" {
+		// scan-miners fills it from the worker's detected model
+		minerStats.MinerType = stored.MinerType
+	}
+	minerStats.CheckFans(uc.cfg.App.FanMinRPM)
+	for i := range minerStats.Chains {
+		minerStats.Chains[i].CreatedAt = stored.CreatedAt
+	}
+
+	return uc.minerStatsRepo.Replace(ctx, minerStats)
+}
@@ -0,0 +1,108 @@
+package usecase
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/beatyman/scan-miners/config"
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+	"github.com/beatyman/scan-miners/pkg/ipmap"
+	"github.com/beatyman/scan-miners/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type ResolveIPsUseCase struct {
+	cfg        *config.Config
+	workerRepo repository.WorkerRepository
+	ipMapper   *ipmap.Mapper
+}
+
+func NewResolveIPsUseCase(cfg *config.Config, workerRepo repository.WorkerRepository, ipMapper *ipmap.Mapper) *ResolveIPsUseCase {
+	return &ResolveIPsUseCase{
+		cfg:        cfg,
+		workerRepo: workerRepo,
+		ipMapper:   ipMapper,
+	}
+}
+
+// Execute re-runs the IP mapping over every active worker and writes the ones
+// that could not be mapped to CSV. With apply, changed IPs are saved.
+func (uc *ResolveIPsUseCase) Execute(ctx context.Context, apply bool) error {
+	logger.Log.Info("Starting IP resolution", zap.Bool("apply", apply))
+
+	workers, err := uc.workerRepo.FindActive(ctx)
+	if err != nil {
+		return err
+	}
+
+	filename := fmt.Sprintf("unmapped_workers_%s.csv", time.Now().Format("20060102_150405"))
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Add BOM for Excel compatibility
+	file.Write([]byte{0xEF, 0xBB, 0xBF})
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{
+		"Worker ID",
+		"User Worker ID",
+		"Observer User ID",
+		"Coin Type",
+		"Site",
+		"Stored IP",
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	unmapped, changed := 0, 0
+	for _, worker := range workers {
+		site := uc.cfg.SiteOf(worker.ObserverUserID)
+		ip, ok := uc.ipMapper.Resolve(worker.ObserverUserID, site, worker.WorkerID)
+		if !ok {
+			record := []string{
+				worker.WorkerID,
+				worker.UserWorkerID,
+				worker.ObserverUserID,
+				worker.CoinType,
+				site,
+				worker.IP,
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+			unmapped++
+			continue
+		}
+
+		if ip == worker.IP {
+			continue
+		}
+		changed++
+		logger.Log.Info("Worker IP changed",
+			zap.String("workerID", worker.WorkerID), zap.String("from", worker.IP), zap.String("to", ip))
+		if apply {
+			if err := uc.workerRepo.UpdateIP(ctx, worker.ID, ip); err != nil {
+				return err
+			}
+		}
+	}
+
+	absPath, _ := filepath.Abs(filename)
+	logger.Log.Info("IP resolution completed",
+		zap.String("file", absPath),
+		zap.Int("workers", len(workers)),
+		zap.Int("unmapped", unmapped),
+		zap.Int("changed", changed),
+		zap.Bool("applied", apply))
+	return nil
+}
@@ -0,0 +1,125 @@
+package usecase
+
+import (
+	"context"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/beatyman/scan-miners/pkg/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	// limiterWindow is the number of reachable miners between two adjustments
+	limiterWindow = 20
+	// limiterMaxErrorRate is the share of reachable miners failing to answer
+	// above which the limiter backs off
+	limiterMaxErrorRate = 0.2
+	// limiterStep is the additive increase per healthy window
+	limiterStep = 5
+)
+
+// adaptiveLimiter bounds the number of miners scanned at once and tunes the
+// bound every limiterWindow reachable miners: the limit shrinks by a quarter
+// when their p95 latency exceeds target or too many of them fail, and grows
+// by limiterStep otherwise. Unreachable hosts say nothing about load and are
+// not counted.
+type adaptiveLimiter struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	limit    int
+	min      int
+	max      int
+	inFlight int
+	target   time.Duration
+
+	latencies []time.Duration
+	failures  int
+}
+
+func newAdaptiveLimiter(initial, min, max int, target time.Duration) *adaptiveLimiter {
+	l := &adaptiveLimiter{limit: initial, min: min, max: max, target: target}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// Acquire blocks until fewer than limit miners are being scanned, or returns
+// ctx's error once ctx is done
+func (l *adaptiveLimiter) Acquire(ctx context.Context) error {
+	// The callback takes the lock, so it cannot broadcast between the check and Wait
+	stop := context.AfterFunc(ctx, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.cond.Broadcast()
+	})
+	defer stop()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if l.inFlight < l.limit {
+			l.inFlight++
+			return nil
+		}
+		l.cond.Wait()
+	}
+}
+
+// Release ends a scan; reachable tells whether the miner accepted the
+// connection, failed whether it then could not be read
+func (l *adaptiveLimiter) Release(latency time.Duration, reachable, failed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+	defer l.cond.Broadcast()
+
+	if !reachable {
+		return
+	}
+	l.latencies = append(l.latencies, latency)
+	if failed {
+		l.failures++
+	}
+	if len(l.latencies) < limiterWindow {
+		return
+	}
+
+	p95 := percentile(l.latencies, 0.95)
+	errorRate := float64(l.failures) / float64(len(l.latencies))
+	previous := l.limit
+	if p95 > l.target || errorRate > limiterMaxErrorRate {
+		l.limit = max(l.min, l.limit*3/4)
+	} else {
+		l.limit = min(l.max, l.limit+limiterStep)
+	}
+	if l.limit != previous {
+		logger.Log.Debug("Adjusted scan concurrency",
+			zap.Int("from", previous), zap.Int("to", l.limit),
+			zap.Duration("p95", p95), zap.Float64("errorRate", errorRate))
+	}
+	l.latencies = l.latencies[:0]
+	l.failures = 0
+}
+
+// Limit is the current concurrency bound
+func (l *adaptiveLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+// percentile returns the p-th percentile (0-1) of values by nearest rank
+func percentile(values []time.Duration, p float64) time.Duration {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(0, min(len(sorted)-1, rank))]
+}
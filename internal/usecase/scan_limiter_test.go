@@ -0,0 +1,86 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveLimiterAdjusts(t *testing.T) {
+	tests := []struct {
+		name      string
+		latency   time.Duration
+		reachable bool
+		failed    int // failures among the limiterWindow miners
+		want      int
+	}{
+		{"healthy window grows", 100 * time.Millisecond, true, 0, 25},
+		{"slow window shrinks", 3 * time.Second, true, 0, 15},
+		{"failing window shrinks", 100 * time.Millisecond, true, limiterWindow / 2, 15},
+		{"unreachable hosts are not counted", 3 * time.Second, false, 0, 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newAdaptiveLimiter(20, 10, 30, time.Second)
+			for i := 0; i < limiterWindow; i++ {
+				if err := l.Acquire(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+				l.Release(tt.latency, tt.reachable, i < tt.failed)
+			}
+			if got := l.Limit(); got != tt.want {
+				t.Errorf("Limit() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdaptiveLimiterBounds(t *testing.T) {
+	l := newAdaptiveLimiter(11, 10, 12, time.Second)
+	for i := 0; i < 3*limiterWindow; i++ {
+		l.Acquire(context.Background())
+		l.Release(time.Millisecond, true, false)
+	}
+	if got := l.Limit(); got != 12 {
+		t.Errorf("Limit() = %d after healthy windows, want the maximum 12", got)
+	}
+	for i := 0; i < 3*limiterWindow; i++ {
+		l.Acquire(context.Background())
+		l.Release(time.Minute, true, false)
+	}
+	if got := l.Limit(); got != 10 {
+		t.Errorf("Limit() = %d after slow windows, want the minimum 10", got)
+	}
+}
+
+func TestAdaptiveLimiterAcquire(t *testing.T) {
+	l := newAdaptiveLimiter(1, 1, 1, time.Second)
+	if err := l.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	acquired := make(chan error)
+	go func() { acquired <- l.Acquire(context.Background()) }()
+	select {
+	case <-acquired:
+		t.Fatal("Acquire() returned while the limit was reached")
+	case <-time.After(20 * time.Millisecond):
+	}
+	l.Release(time.Millisecond, true, false)
+	if err := <-acquired; err != nil {
+		t.Fatalf("Acquire() after Release = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() { acquired <- l.Acquire(ctx) }()
+	cancel()
+	select {
+	case err := <-acquired:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Acquire() = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Acquire() kept waiting after ctx was cancelled")
+	}
+}
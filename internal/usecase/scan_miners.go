@@ -2,171 +2,667 @@ package usecase
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"net/http"
+	"net"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/beatyman/scan-miners/config"
 	"github.com/beatyman/scan-miners/internal/domain/model"
 	"github.com/beatyman/scan-miners/internal/domain/repository"
 	"github.com/beatyman/scan-miners/pkg/logger"
-	"github.com/icholy/digest"
+	"github.com/beatyman/scan-miners/pkg/transcript"
 	"go.uber.org/zap"
 )
 
+// errNoDriver is returned when no driver recognises the miner
+var errNoDriver = errors.New("no miner driver recognised the device")
+
 type ScanMinersUseCase struct {
 	cfg            *config.Config
 	workerRepo     repository.WorkerRepository
 	minerStatsRepo repository.MinerStatsRepository
-	client         *http.Client
+	hashboardRepo  repository.HashboardRepository
+	chipLayoutRepo repository.ChipLayoutRepository
+	scanRunRepo    repository.ScanRunRepository
+	rawArchive     repository.RawArchive
+	drivers        []repository.MinerDriver
+	driversByName  map[string]repository.MinerDriver
+
+	// savedLayouts holds the miner types whose chip layout was stored during the current run
+	savedLayouts *sync.Map
 }
 
-func NewScanMinersUseCase(cfg *config.Config, workerRepo repository.WorkerRepository, minerStatsRepo repository.MinerStatsRepository) *ScanMinersUseCase {
-	// Setup digest authentication client
-	t := &digest.Transport{
-		Username: cfg.App.MinerUser,
-		Password: cfg.App.MinerPassword,
+// NewScanMinersUseCase takes the miner drivers in detection order: specific
+// firmwares first, generic protocols last. rawArchive is nil when raw
+// responses are not kept.
+func NewScanMinersUseCase(cfg *config.Config, workerRepo repository.WorkerRepository, minerStatsRepo repository.MinerStatsRepository, hashboardRepo repository.HashboardRepository, chipLayoutRepo repository.ChipLayoutRepository, scanRunRepo repository.ScanRunRepository, rawArchive repository.RawArchive, drivers []repository.MinerDriver) *ScanMinersUseCase {
+	byName := make(map[string]repository.MinerDriver, len(drivers))
+	for _, d := range drivers {
+		byName[d.Name()] = d
 	}
-
 	return &ScanMinersUseCase{
 		cfg:            cfg,
 		workerRepo:     workerRepo,
 		minerStatsRepo: minerStatsRepo,
-		client: &http.Client{
-			Transport: t,
-			Timeout:   5 * time.Second, // Shorter timeout for local network scanning
-		},
+		hashboardRepo:  hashboardRepo,
+		chipLayoutRepo: chipLayoutRepo,
+		scanRunRepo:    scanRunRepo,
+		rawArchive:     rawArchive,
+		drivers:        drivers,
+		driversByName:  byName,
 	}
 }
 
-func (uc *ScanMinersUseCase) Execute(ctx context.Context) error {
+// Execute scans every active worker, recording the run and the outcome of
+// each miner. It returns ErrScanFailureRate when more than
+// app.scan_max_failure_rate of the scanned miners failed.
+func (uc *ScanMinersUseCase) Execute(ctx context.Context) (err error) {
 	logger.Log.Info("Starting to scan miner stats")
 
-	workers, err := uc.workerRepo.FindAll(ctx)
+	workers, err := uc.workerRepo.FindActive(ctx)
 	if err != nil {
 		return err
 	}
 
 	logger.Log.Info("Found workers to scan", zap.Int("count", len(workers)))
+	uc.savedLayouts = &sync.Map{}
+
+	run := &model.ScanRun{
+		Status:    model.ScanRunRunning,
+		Total:     len(workers),
+		StartedAt: time.Now(),
+	}
+	if err := uc.scanRunRepo.Create(ctx, run); err != nil {
+		return fmt.Errorf("create scan run: %w", err)
+	}
+
+	var mu sync.Mutex
+	var outcomes []*model.ScanOutcome
+	defer func() {
+		err = uc.finishScanRun(ctx, run, outcomes, err)
+	}()
 
-	// Worker pool for scanning
+	// On shutdown no new scans start, but the running ones keep going on their
+	// own timeouts so their results are stored rather than recorded as canceled.
+	// A miner still hanging after half of schedule.shutdown_timeout is cut off,
+	// leaving the rest to store the run.
+	scanCtx, cancelScans := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelScans()
+	stopDrain := context.AfterFunc(ctx, func() {
+		select {
+		case <-time.After(uc.cfg.Schedule.ShutdownTimeout / 2):
+			cancelScans()
+		case <-scanCtx.Done():
+		}
+	})
+	defer stopDrain()
+
+	// Worker pool for scanning, sized by observed latency
 	var wg sync.WaitGroup
-	semaphore := make(chan struct{}, 50) // Limit concurrency to 50
+	limiter := newAdaptiveLimiter(uc.cfg.App.ScanConcurrency, uc.cfg.App.ScanConcurrencyMin, uc.cfg.App.ScanConcurrencyMax, uc.cfg.App.ScanTargetLatency)
 
 	for _, worker := range workers {
 		if worker.IP == "" {
+			run.Skipped++
 			continue
 		}
-
+		if err := limiter.Acquire(ctx); err != nil {
+			break
+		}
 		wg.Add(1)
-		semaphore <- struct{}{}
 
 		go func(w *model.Worker) {
 			defer wg.Done()
-			defer func() { <-semaphore }()
 
-			if err := uc.scanSingleMiner(ctx, w); err != nil {
-				// Log error but continue
-				logger.Log.Debug("Failed to scan miner", zap.String("ip", w.IP), zap.Error(err))
+			outcome := &model.ScanOutcome{ScanRunID: run.ID, WorkerID: w.WorkerID, IP: w.IP}
+			started := time.Now()
+			err := uc.scanSingleMiner(scanCtx, w, outcome)
+			latency := time.Since(started)
+			limiter.Release(latency, !isDialError(err), err != nil)
+			outcome.DurationMs = latency.Milliseconds()
+			outcome.Reason = classifyScanError(err)
+			if err != nil {
+				outcome.Error = err.Error()
+				logger.Log.Warn("Failed to scan miner", zap.String("ip", w.IP), zap.String("reason", outcome.Reason), zap.Error(err))
 			}
+
+			mu.Lock()
+			outcomes = append(outcomes, outcome)
+			mu.Unlock()
 		}(worker)
 	}
 
 	wg.Wait()
+	run.FinalConcurrency = limiter.Limit()
 	logger.Log.Info("Finished scanning miner stats")
 	return nil
 }
 
-func (uc *ScanMinersUseCase) scanSingleMiner(ctx context.Context, worker *model.Worker) error {
-	endpoints := []string{
-		fmt.Sprintf("http://%s/cgi-bin/get_stats.cgi", worker.IP),
-		fmt.Sprintf("http://%s/cgi-bin/stats.cgi", worker.IP),
-	}
+// ErrScanFailureRate is returned when too many miners of a scan run failed
+var ErrScanFailureRate = errors.New("scan failure rate above app.scan_max_failure_rate")
 
-	var body []byte
-	var err error
+// errScanDB marks failures to store a snapshot, as opposed to failures to read the miner
+var errScanDB = errors.New("database error")
 
-	for _, url := range endpoints {
-		body, err = uc.fetchURL(ctx, url)
-		if err == nil {
-			break
+// errBadChipLayout marks a tpl chip layout that was read but could not be parsed
+var errBadChipLayout = errors.New("bad chip layout")
+
+// finishScanRun stores the outcomes and the run totals, prints the summary and
+// turns a failure rate above the threshold into ErrScanFailureRate; it must
+// succeed even when ctx was cancelled
+func (uc *ScanMinersUseCase) finishScanRun(ctx context.Context, run *model.ScanRun, outcomes []*model.ScanOutcome, runErr error) error {
+	reasons := map[string]int{}
+	for _, o := range outcomes {
+		reasons[o.Reason]++
+		if o.Reason == model.ScanReasonOK {
+			run.Succeeded++
+		} else {
+			run.Failed++
 		}
 	}
+	if scanned := run.Succeeded + run.Failed; scanned > 0 {
+		run.FailureRate = float64(run.Failed) / float64(scanned)
+	}
+	var latencies []time.Duration
+	for _, o := range outcomes {
+		if o.Reason == model.ScanReasonOK {
+			latencies = append(latencies, time.Duration(o.DurationMs)*time.Millisecond)
+		}
+	}
+	run.LatencyP50Ms = percentile(latencies, 0.50).Milliseconds()
+	run.LatencyP95Ms = percentile(latencies, 0.95).Milliseconds()
+	// An interrupted run says nothing about the miners
+	if runErr == nil && ctx.Err() != nil {
+		runErr = fmt.Errorf("scan interrupted: %w", ctx.Err())
+	}
+	if runErr == nil && run.FailureRate > uc.cfg.App.ScanMaxFailureRate {
+		runErr = fmt.Errorf("%w: %.1f%% of %d miners failed", ErrScanFailureRate, run.FailureRate*100, run.Succeeded+run.Failed)
+	}
 
-	if err != nil {
-		return err
+	finished := time.Now()
+	run.FinishedAt = &finished
+	run.DurationMs = finished.Sub(run.StartedAt).Milliseconds()
+	run.Status = model.ScanRunSuccess
+	if runErr != nil {
+		run.Status = model.ScanRunFailed
+		run.Error = runErr.Error()
 	}
 
-	// Parse JSON
-	var resp model.MinerAPIResponse
-	if err := json.Unmarshal(body, &resp); err != nil {
-		return err
+	ctx = context.WithoutCancel(ctx)
+	if err := uc.scanRunRepo.SaveOutcomes(ctx, outcomes); err != nil {
+		logger.Log.Error("Failed to save scan outcomes", zap.Uint("scanRunId", run.ID), zap.Error(err))
+	}
+	if err := uc.scanRunRepo.Update(ctx, run); err != nil {
+		logger.Log.Error("Failed to save scan run", zap.Uint("scanRunId", run.ID), zap.Error(err))
+	}
+
+	logger.Log.Info("Scan run finished",
+		zap.Uint("scanRunId", run.ID),
+		zap.String("status", run.Status),
+		zap.Int("total", run.Total),
+		zap.Int("skipped", run.Skipped),
+		zap.Int("succeeded", run.Succeeded),
+		zap.Int("failed", run.Failed),
+		zap.Int64("latencyP50Ms", run.LatencyP50Ms),
+		zap.Int64("latencyP95Ms", run.LatencyP95Ms),
+		zap.Int("finalConcurrency", run.FinalConcurrency),
+		zap.Int64("durationMs", run.DurationMs))
+	printScanSummary(os.Stdout, run, reasons)
+	return runErr
+}
+
+func printScanSummary(w io.Writer, run *model.ScanRun, reasons map[string]int) {
+	fmt.Fprintf(w, "\nScan run %d: %d workers, %d without IP, %d scanned in %s\n",
+		run.ID, run.Total, run.Skipped, run.Succeeded+run.Failed, time.Duration(run.DurationMs)*time.Millisecond)
+	fmt.Fprintf(w, "  %-20s %6d\n", model.ScanReasonOK, run.Succeeded)
+
+	var failed []string
+	for reason := range reasons {
+		if reason != model.ScanReasonOK {
+			failed = append(failed, reason)
+		}
+	}
+	// Most frequent reason first
+	sort.Slice(failed, func(i, j int) bool {
+		if reasons[failed[i]] != reasons[failed[j]] {
+			return reasons[failed[i]] > reasons[failed[j]]
+		}
+		return failed[i] < failed[j]
+	})
+	for _, reason := range failed {
+		fmt.Fprintf(w, "  %-20s %6d\n", reason, reasons[reason])
+	}
+	fmt.Fprintf(w, "  failure rate %.1f%%\n", run.FailureRate*100)
+	fmt.Fprintf(w, "  latency of successful miners p50 %dms, p95 %dms; final concurrency %d\n",
+		run.LatencyP50Ms, run.LatencyP95Ms, run.FinalConcurrency)
+}
+
+// classifyScanError maps a scanSingleMiner error to a model.ScanReason*
+func classifyScanError(err error) string {
+	var netErr net.Error
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case err == nil:
+		return model.ScanReasonOK
+	case errors.Is(err, errScanDB):
+		return model.ScanReasonDBError
+	case errors.Is(err, context.Canceled):
+		return model.ScanReasonCanceled
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return model.ScanReasonTimeout
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return model.ScanReasonRefused
+	case errors.As(err, &netErr):
+		return model.ScanReasonUnreachable
+	case errors.Is(err, repository.ErrMinerUnauthorized):
+		return model.ScanReasonUnauthorized
+	case errors.Is(err, repository.ErrMinerBadStatus):
+		return model.ScanReasonBadStatus
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+		return model.ScanReasonBadJSON
+	case errors.Is(err, errBadChipLayout):
+		return model.ScanReasonBadLayout
+	case errors.Is(err, repository.ErrMinerNoStats):
+		return model.ScanReasonEmptyStats
+	case errors.Is(err, errNoDriver):
+		return model.ScanReasonNoDriver
 	}
+	return model.ScanReasonOther
+}
 
-	// Transform to Domain Model
-	// Assuming only one STATS item as per usual Antminer API
-	if len(resp.Stats) == 0 {
-		return fmt.Errorf("no stats data found for %s", worker.IP)
+func (uc *ScanMinersUseCase) scanSingleMiner(ctx context.Context, worker *model.Worker, outcome *model.ScanOutcome) error {
+	// A dead host fails here within the connect timeout instead of going
+	// through the digest handshake or every driver's detection
+	if ports := uc.probePorts(worker); len(ports) > 0 {
+		if err := probeTCPAny(ctx, worker.IP, ports, uc.cfg.App.MinerConnectTimeout); err != nil {
+			return err
+		}
 	}
 
-	statItem := resp.Stats[0]
+	auth := uc.minerAuth(worker)
+	minerStats, err := uc.fetchStats(repository.WithMinerAuth(ctx, auth), worker)
+	if errors.Is(err, repository.ErrMinerUnauthorized) {
+		// Drivers try every credential before giving up; passwords stay out of the outcome
+		var users []string
+		for _, cred := range auth.Credentials {
+			users = append(users, cred.User)
+		}
+		outcome.UsersTried = strings.Join(users, ", ")
+	}
+	if err != nil {
+		return err
+	}
+	outcome.Driver = minerStats.Source
 
-	minerStats := &model.MinerStats{
-		WorkerID:     worker.WorkerID,
-		IP:           worker.IP,
-		MinerType:    resp.Info.Type,
-		MinerVersion: resp.Info.MinerVersion,
-		CompileTime:  resp.Info.CompileTime,
-		Elapsed:      statItem.Elapsed,
-		Rate5s:       statItem.Rate5s,
-		Rate30m:      statItem.Rate30m,
-		RateAvg:      statItem.RateAvg,
-		RateIdeal:    statItem.RateIdeal,
-		RateUnit:     statItem.RateUnit,
-		FanNum:       statItem.FanNum,
-		HwpTotal:     statItem.HwpTotal,
+	minerStats.WorkerID = worker.WorkerID
+	minerStats.ObserverUserID = worker.ObserverUserID
+	minerStats.IP = worker.IP
+	if minerStats.MinerType == "" {
+		minerStats.MinerType = worker.MinerModel
+	}
+	minerStats.CheckFans(uc.cfg.App.FanMinRPM)
+	if minerStats.FanAlert != "" {
+		logger.Log.Warn("Fan alert", zap.String("ip", worker.IP), zap.String("alert", minerStats.FanAlert), zap.String("fans", minerStats.FanSpeeds))
 	}
 
-	// Map Chains
-	for _, chainItem := range statItem.Chain {
-		minerStats.Chains = append(minerStats.Chains, model.MinerChain{
-			ChainIndex: chainItem.Index,
-			FreqAvg:    chainItem.FreqAvg,
-			RateIdeal:  chainItem.RateIdeal,
-			RateReal:   chainItem.RateReal,
-			AsicNum:    chainItem.AsicNum,
-			Hw:         chainItem.Hw,
-			Hwp:        chainItem.Hwp,
-		})
+	if uc.rawArchive != nil && len(minerStats.Raw) > 0 {
+		key, err := uc.rawArchive.Put(ctx, minerStats.Raw)
+		if err != nil {
+			// The parsed snapshot is still worth keeping
+			logger.Log.Warn("Failed to archive raw miner response", zap.String("ip", worker.IP), zap.Error(err))
+		}
+		minerStats.RawSHA256 = key
 	}
 
 	// Save
 	if err := uc.minerStatsRepo.Save(ctx, minerStats); err != nil {
+		return fmt.Errorf("%w: save stats: %w", errScanDB, err)
+	}
+	outcome.MinerStatsID = minerStats.ID
+	if minerStats.StatsEndpoint != "" && minerStats.StatsEndpoint != worker.StatsEndpoint {
+		if err := uc.workerRepo.UpdateStatsEndpoint(ctx, worker.ID, minerStats.StatsEndpoint); err != nil {
+			return fmt.Errorf("%w: update stats endpoint: %w", errScanDB, err)
+		}
+		logger.Log.Info("Remembered stats endpoint", zap.String("ip", worker.IP), zap.String("endpoint", minerStats.StatsEndpoint))
+		worker.StatsEndpoint = minerStats.StatsEndpoint
+	}
+	if auth.Accepted != nil {
+		if fingerprint := credentialFingerprint(*auth.Accepted); fingerprint != worker.MinerCredential {
+			if err := uc.workerRepo.UpdateMinerCredential(ctx, worker.ID, fingerprint); err != nil {
+				return fmt.Errorf("%w: update miner credential: %w", errScanDB, err)
+			}
+			logger.Log.Info("Remembered miner credential", zap.String("ip", worker.IP), zap.String("user", auth.Accepted.User))
+			worker.MinerCredential = fingerprint
+		}
+	}
+	if err := uc.trackHashboards(ctx, minerStats); err != nil {
+		return fmt.Errorf("%w: track hashboards: %w", errScanDB, err)
+	}
+	if err := uc.saveChipLayout(ctx, minerStats); err != nil {
 		return err
 	}
 
-	logger.Log.Info("Successfully scanned miner", zap.String("ip", worker.IP))
+	logger.Log.Info("Successfully scanned miner", zap.String("ip", worker.IP), zap.String("driver", minerStats.Source))
 	return nil
 }
 
-func (uc *ScanMinersUseCase) fetchURL(ctx context.Context, url string) ([]byte, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+// probePorts lists the ports of which one must accept a connection before the
+// worker's miner is read: app.scan_probe_port for the stock Antminer HTTP API,
+// app.cgminer_port for the drivers on the cgminer API, and both while the
+// driver is still to be detected or may fall back to the cgminer API.
+// It is empty when app.scan_probe_port is 0.
+func (uc *ScanMinersUseCase) probePorts(worker *model.Worker) []int {
+	httpPort, apiPort := uc.cfg.App.ScanProbePort, uc.cfg.App.CGMinerPort
+	if httpPort <= 0 {
+		return nil
+	}
+
+	name := uc.cfg.App.MinerDriverFor(worker.WorkerID, worker.IP)
+	if name == config.MinerDriverAuto {
+		if worker.MinerDriver == "" || worker.MinerDriver == model.DriverAntminer || uc.reprobeDue(worker) {
+			return []int{httpPort, apiPort}
+		}
+		name = worker.MinerDriver
+	}
+	if name == model.DriverAntminer {
+		return []int{httpPort}
+	}
+	return []int{apiPort}
+}
+
+// minerAuth lists the credentials to try on the worker's miner, the one it
+// accepted last time first
+func (uc *ScanMinersUseCase) minerAuth(worker *model.Worker) *repository.MinerAuth {
+	auth := minerAuthFor(uc.cfg, worker.WorkerID, worker.IP)
+	i := slices.IndexFunc(auth.Credentials, func(cred repository.MinerCredential) bool {
+		return credentialFingerprint(cred) == worker.MinerCredential
+	})
+	if i > 0 {
+		remembered := auth.Credentials[i]
+		auth.Credentials = slices.Insert(slices.Delete(auth.Credentials, i, i+1), 0, remembered)
+	}
+	return auth
+}
+
+// minerAuthFor lists the configured credentials of a miner (see config.AppConfig.MinerCredentialsFor)
+func minerAuthFor(cfg *config.Config, workerID, ip string) *repository.MinerAuth {
+	auth := &repository.MinerAuth{}
+	for _, cred := range cfg.App.MinerCredentialsFor(workerID, ip) {
+		auth.Credentials = append(auth.Credentials, repository.MinerCredential{User: cred.User, Password: cred.Password})
+	}
+	return auth
+}
+
+// credentialFingerprint identifies a credential on the worker without storing
+// its password
+func credentialFingerprint(cred repository.MinerCredential) string {
+	sum := sha256.Sum256([]byte(cred.User + "\x00" + cred.Password))
+	return hex.EncodeToString(sum[:8])
+}
+
+// fetchStats reads the miner with the configured driver or, in auto mode, with
+// the driver cached on the worker, detecting it again when it no longer fits
+func (uc *ScanMinersUseCase) fetchStats(ctx context.Context, worker *model.Worker) (*model.MinerStats, error) {
+	reprobe := uc.reprobeDue(worker)
+
+	if name := uc.cfg.App.MinerDriverFor(worker.WorkerID, worker.IP); name != config.MinerDriverAuto {
+		driver, ok := uc.driversByName[name]
+		if !ok {
+			return nil, fmt.Errorf("miner driver %q is not available", name)
+		}
+		// Nothing to detect, but the stats endpoint is worked out afresh
+		if reprobe {
+			if err := uc.markDetected(ctx, worker, name, worker.MinerModel); err != nil {
+				return nil, err
+			}
+		}
+		return readStats(ctx, driver, worker)
+	}
+
+	cached := uc.driversByName[worker.MinerDriver]
+	if cached != nil && !reprobe {
+		minerStats, err := uc.readStatsWithFallback(ctx, cached, worker)
+		// An unreachable miner or a cut-off scan says nothing about the firmware
+		if err == nil || isNetworkError(err) || ctx.Err() != nil {
+			return minerStats, err
+		}
+		logger.Log.Info("Cached miner driver failed, detecting again",
+			zap.String("ip", worker.IP), zap.String("driver", cached.Name()), zap.Error(err))
+	}
+
+	driver, err := uc.detectDriver(ctx, worker)
 	if err != nil {
 		return nil, err
 	}
+	return uc.readStatsWithFallback(ctx, driver, worker)
+}
+
+// readStatsWithFallback reads the miner with driver and, when the stock
+// Antminer CGI endpoints both fail for any reason but rejected credentials,
+// with the cgminer TCP API instead. The returned error is the CGI one, so it
+// still decides whether the driver is detected again.
+func (uc *ScanMinersUseCase) readStatsWithFallback(ctx context.Context, driver repository.MinerDriver, worker *model.Worker) (*model.MinerStats, error) {
+	minerStats, err := readStats(ctx, driver, worker)
+	if err == nil || driver.Name() != model.DriverAntminer || errors.Is(err, repository.ErrMinerUnauthorized) {
+		return minerStats, err
+	}
+	fallback := uc.driversByName[model.DriverCGMiner]
+	if fallback == nil {
+		return nil, err
+	}
 
-	resp, err := uc.client.Do(req)
+	minerStats, fallbackErr := readStats(ctx, fallback, worker)
+	if fallbackErr != nil {
+		return nil, fmt.Errorf("%w (cgminer fallback: %v)", err, fallbackErr)
+	}
+	logger.Log.Info("CGI endpoints failed, read stats through the cgminer API",
+		zap.String("ip", worker.IP), zap.Error(err))
+	return minerStats, nil
+}
+
+// reprobeDue reports whether the worker's driver and stats endpoint are older
+// than app.miner_reprobe_interval
+func (uc *ScanMinersUseCase) reprobeDue(worker *model.Worker) bool {
+	interval := uc.cfg.App.MinerReprobeInterval
+	return interval > 0 && (worker.DriverCheckedAt == nil || time.Since(*worker.DriverCheckedAt) > interval)
+}
+
+// readStats takes a snapshot with driver, trying the worker's remembered stats
+// endpoint first, and keeps the raw responses it was parsed from in Raw
+func readStats(ctx context.Context, driver repository.MinerDriver, worker *model.Worker) (*model.MinerStats, error) {
+	ctx = repository.WithStatsEndpoint(ctx, worker.StatsEndpoint)
+	ctx, t := transcript.Record(ctx)
+	minerStats, err := driver.FetchStats(ctx, worker.IP)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	if minerStats.Raw, err = json.Marshal(t); err != nil {
+		return nil, err
+	}
+	return minerStats, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("bad status: %d", resp.StatusCode)
+// detectDriver asks every driver in order and caches the first match on the worker
+func (uc *ScanMinersUseCase) detectDriver(ctx context.Context, worker *model.Worker) (repository.MinerDriver, error) {
+	driver, info, err := detectMiner(ctx, uc.drivers, worker.IP)
+	if err != nil {
+		return nil, err
 	}
 
-	return io.ReadAll(resp.Body)
+	minerModel := ""
+	if info != nil {
+		minerModel = info.Model
+	}
+	if driver.Name() != worker.MinerDriver || minerModel != worker.MinerModel {
+		logger.Log.Info("Detected miner driver",
+			zap.String("ip", worker.IP), zap.String("driver", driver.Name()), zap.String("model", minerModel))
+	}
+	if err := uc.markDetected(ctx, worker, driver.Name(), minerModel); err != nil {
+		return nil, err
+	}
+	return driver, nil
+}
+
+// detectMiner asks every driver in order and returns the first one that
+// recognises the miner at ip, with its device info when the driver could read it
+func detectMiner(ctx context.Context, drivers []repository.MinerDriver, ip string) (repository.MinerDriver, *model.DeviceInfo, error) {
+	var probeErr error
+	for _, driver := range drivers {
+		ok, err := driver.Detect(ctx, ip)
+		// Only a firmware with an authenticated API asks for credentials, so the
+		// miner is this one; the unauthenticated cgminer API further down the
+		// list must not hide that every credential was rejected
+		if errors.Is(err, repository.ErrMinerUnauthorized) {
+			return nil, nil, fmt.Errorf("%s: %s: %w", ip, driver.Name(), err)
+		}
+		if err != nil {
+			logger.Log.Debug("Miner driver probe failed", zap.String("ip", ip), zap.String("driver", driver.Name()), zap.Error(err))
+			probeErr = err
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		info, err := driver.FetchInfo(ctx, ip)
+		if err != nil {
+			info = nil
+		}
+		return driver, info, nil
+	}
+	// When a probe could not be completed, its error says more than errNoDriver (e.g. a timeout)
+	if probeErr != nil {
+		return nil, nil, fmt.Errorf("%s: %w (last probe: %w)", ip, errNoDriver, probeErr)
+	}
+	return nil, nil, fmt.Errorf("%s: %w", ip, errNoDriver)
+}
+
+// markDetected stores a detection result on the worker, which also resets its
+// stats endpoint and re-probe clock
+func (uc *ScanMinersUseCase) markDetected(ctx context.Context, worker *model.Worker, driver, minerModel string) error {
+	if err := uc.workerRepo.UpdateMinerDriver(ctx, worker.ID, driver, minerModel); err != nil {
+		return fmt.Errorf("%w: update miner driver: %w", errScanDB, err)
+	}
+	now := time.Now()
+	worker.MinerDriver = driver
+	worker.MinerModel = minerModel
+	worker.StatsEndpoint = ""
+	worker.DriverCheckedAt = &now
+	return nil
+}
+
+// trackHashboards updates the serial number inventory and records every board
+// found in a different miner or slot than where it was last seen
+func (uc *ScanMinersUseCase) trackHashboards(ctx context.Context, stats *model.MinerStats) error {
+	for _, chain := range stats.Chains {
+		if chain.SN == "" {
+			continue
+		}
+
+		board, err := uc.hashboardRepo.FindBySN(ctx, chain.SN)
+		if err != nil {
+			return err
+		}
+		// The miner is identified by its IP: a worker renamed in the pool keeps
+		// its boards, and a worker ID used by two accounts is not one miner
+		if board == nil {
+			board = &model.Hashboard{SN: chain.SN, FirstSeenAt: stats.CreatedAt}
+		} else if board.IP != stats.IP || board.ChainIndex != chain.ChainIndex {
+			move := &model.HashboardMove{
+				SN:             chain.SN,
+				FromWorkerID:   board.WorkerID,
+				FromIP:         board.IP,
+				FromChainIndex: board.ChainIndex,
+				ToWorkerID:     stats.WorkerID,
+				ToIP:           stats.IP,
+				ToChainIndex:   chain.ChainIndex,
+				DetectedAt:     stats.CreatedAt,
+			}
+			if err := uc.hashboardRepo.CreateMove(ctx, move); err != nil {
+				return err
+			}
+			logger.Log.Info("Hashboard moved",
+				zap.String("sn", chain.SN),
+				zap.String("from", fmt.Sprintf("%s %s/%d", board.WorkerID, board.IP, board.ChainIndex)),
+				zap.String("to", fmt.Sprintf("%s %s/%d", stats.WorkerID, stats.IP, chain.ChainIndex)))
+		}
+
+		board.WorkerID = stats.WorkerID
+		board.IP = stats.IP
+		board.MinerType = stats.MinerType
+		board.ChainIndex = chain.ChainIndex
+		board.LastSeenAt = stats.CreatedAt
+		if err := uc.hashboardRepo.Save(ctx, board); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveChipLayout stores the tpl matrix of the snapshot's miner type, once per
+// run; a layout that failed to parse or save is tried again with the next miner
+func (uc *ScanMinersUseCase) saveChipLayout(ctx context.Context, stats *model.MinerStats) error {
+	if len(stats.ChipLayout) == 0 || stats.MinerType == "" {
+		return nil
+	}
+	if _, done := uc.savedLayouts.Load(stats.MinerType); done {
+		return nil
+	}
+
+	layout, err := model.NewChipLayout(stats.MinerType, stats.ChipLayout)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errBadChipLayout, err)
+	}
+	if err := uc.chipLayoutRepo.Save(ctx, layout); err != nil {
+		return fmt.Errorf("%w: save chip layout: %w", errScanDB, err)
+	}
+	uc.savedLayouts.Store(stats.MinerType, true)
+	return nil
+}
+
+func isNetworkError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// isDialError reports whether err comes from failing to connect, as opposed
+// to a miner that accepted the connection and then misbehaved
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// probeTCPAny checks that ip accepts connections on at least one of ports,
+// returning the error of the last port when none does
+func probeTCPAny(ctx context.Context, ip string, ports []int, timeout time.Duration) error {
+	var err error
+	for _, port := range ports {
+		if err = probeTCP(ctx, ip, port, timeout); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// probeTCP checks that ip accepts connections on port
+func probeTCP(ctx context.Context, ip string, port int, timeout time.Duration) error {
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip, strconv.Itoa(port)))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
 }
@@ -0,0 +1,462 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/beatyman/scan-miners/config"
+	"github.com/beatyman/scan-miners/internal/domain/model"
+	"github.com/beatyman/scan-miners/internal/domain/repository"
+)
+
+var (
+	errTimeout   = &net.OpError{Op: "read", Net: "tcp", Err: timeoutError{}}
+	errBadStatus = &wrappedError{msg: "status 500", err: repository.ErrMinerBadStatus}
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+type wrappedError struct {
+	msg string
+	err error
+}
+
+func (e *wrappedError) Error() string { return e.msg }
+func (e *wrappedError) Unwrap() error { return e.err }
+
+// newScanMinersFixture stores worker and returns a use case in auto mode over drivers
+func newScanMinersFixture(t *testing.T, worker *model.Worker, drivers ...repository.MinerDriver) (*ScanMinersUseCase, *fakeWorkerRepo) {
+	t.Helper()
+	cfg := config.Default()
+	cfg.App.MinerReprobeInterval = 0
+	workers := &fakeWorkerRepo{}
+	if err := workers.SaveBatch(context.Background(), []*model.Worker{worker}); err != nil {
+		t.Fatal(err)
+	}
+	return NewScanMinersUseCase(cfg, workers, nil, nil, nil, nil, nil, drivers), workers
+}
+
+func TestDetectMiner(t *testing.T) {
+	tests := []struct {
+		name        string
+		drivers     []*fakeDriver
+		want        string
+		wantErr     error
+		wantDetects []int
+	}{
+		{
+			name:        "first match wins",
+			drivers:     []*fakeDriver{{name: "vnish"}, {name: "antminer", detect: true}, {name: "cgminer", detect: true}},
+			want:        "antminer",
+			wantDetects: []int{1, 1, 0},
+		},
+		{
+			name:        "failed probe does not stop detection",
+			drivers:     []*fakeDriver{{name: "vnish", detectErr: errTimeout}, {name: "cgminer", detect: true}},
+			want:        "cgminer",
+			wantDetects: []int{1, 1},
+		},
+		{
+			name:        "nothing recognised",
+			drivers:     []*fakeDriver{{name: "vnish"}, {name: "cgminer"}},
+			wantErr:     errNoDriver,
+			wantDetects: []int{1, 1},
+		},
+		{
+			name:        "last probe error is kept",
+			drivers:     []*fakeDriver{{name: "vnish", detectErr: errBadStatus}, {name: "cgminer", detectErr: errTimeout}},
+			wantErr:     errTimeout,
+			wantDetects: []int{1, 1},
+		},
+		{
+			name:        "rejected credentials stop detection",
+			drivers:     []*fakeDriver{{name: "vnish"}, {name: "antminer", detectErr: repository.ErrMinerUnauthorized}, {name: "cgminer", detect: true}},
+			wantErr:     repository.ErrMinerUnauthorized,
+			wantDetects: []int{1, 1, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			drivers := make([]repository.MinerDriver, 0, len(tt.drivers))
+			for _, d := range tt.drivers {
+				drivers = append(drivers, d)
+			}
+			driver, _, err := detectMiner(context.Background(), drivers, "10.0.0.1")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("detectMiner() error = %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil || driver.Name() != tt.want {
+				t.Fatalf("detectMiner() = %v, %v, want %s", driver, err, tt.want)
+			}
+			for i, d := range tt.drivers {
+				if d.detects != tt.wantDetects[i] {
+					t.Errorf("%s probed %d times, want %d", d.name, d.detects, tt.wantDetects[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDetectDriverCachesMatch(t *testing.T) {
+	worker := &model.Worker{WorkerID: "1x1", IP: "10.0.0.1"}
+	antminer := &fakeDriver{name: model.DriverAntminer, detect: true, info: &model.DeviceInfo{Model: "Antminer S19"}}
+	uc, workers := newScanMinersFixture(t, worker, &fakeDriver{name: model.DriverVNish}, antminer)
+
+	if _, err := uc.detectDriver(context.Background(), worker); err != nil {
+		t.Fatal(err)
+	}
+	stored := workers.byWorkerID("1x1")
+	if stored.MinerDriver != model.DriverAntminer || stored.MinerModel != "Antminer S19" || stored.DriverCheckedAt == nil {
+		t.Errorf("detected driver not cached on the worker: %+v", stored)
+	}
+}
+
+func TestFetchStatsCGMinerFallback(t *testing.T) {
+	tests := []struct {
+		name        string
+		driver      string // app.miner_driver
+		antminerErr error
+		cgminerErr  error
+		wantSource  string
+		wantErr     error
+		wantDetects int // detection runs after a non-network failure
+	}{
+		{name: "CGI works", driver: config.MinerDriverAuto, wantSource: model.DriverAntminer},
+		{name: "CGI fails", driver: config.MinerDriverAuto, antminerErr: errBadStatus, wantSource: model.DriverCGMiner},
+		{name: "CGI unreachable", driver: config.MinerDriverAuto, antminerErr: errTimeout, wantSource: model.DriverCGMiner},
+		{name: "rejected credentials", driver: config.MinerDriverAuto, antminerErr: repository.ErrMinerUnauthorized, wantErr: repository.ErrMinerUnauthorized, wantDetects: 1},
+		{name: "both fail", driver: config.MinerDriverAuto, antminerErr: errTimeout, cgminerErr: errBadStatus, wantErr: errTimeout},
+		{name: "fixed driver", driver: model.DriverAntminer, antminerErr: errBadStatus, wantErr: errBadStatus},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			worker := &model.Worker{WorkerID: "1x1", IP: "10.0.0.1", MinerDriver: model.DriverAntminer}
+			antminer := &fakeDriver{name: model.DriverAntminer, detect: true, statsErr: tt.antminerErr}
+			cgminer := &fakeDriver{name: model.DriverCGMiner, statsErr: tt.cgminerErr}
+			uc, _ := newScanMinersFixture(t, worker, antminer, cgminer)
+			uc.cfg.App.MinerDriver = tt.driver
+
+			minerStats, err := uc.fetchStats(context.Background(), worker)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("fetchStats() error = %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil || minerStats.Source != tt.wantSource {
+				t.Fatalf("fetchStats() = %+v, %v, want stats from %s", minerStats, err, tt.wantSource)
+			}
+			if antminer.detects != tt.wantDetects {
+				t.Errorf("detection ran %d times, want %d", antminer.detects, tt.wantDetects)
+			}
+		})
+	}
+}
+
+// newShutdownFixture scans two miners one at a time with antminer, whose reads run fetch
+func newShutdownFixture(t *testing.T, shutdownTimeout time.Duration, fetch func(ctx context.Context) error) (*ScanMinersUseCase, *fakeScanRunRepo) {
+	t.Helper()
+	cfg := config.Default()
+	cfg.App.MinerReprobeInterval = 0
+	cfg.App.ScanProbePort = 0
+	cfg.App.ScanConcurrency, cfg.App.ScanConcurrencyMin, cfg.App.ScanConcurrencyMax = 1, 1, 1
+	cfg.Schedule.ShutdownTimeout = shutdownTimeout
+
+	workers := &fakeWorkerRepo{}
+	workers.SaveBatch(context.Background(), []*model.Worker{
+		{WorkerID: "1x1", IP: "10.0.0.1", Active: true, MinerDriver: model.DriverAntminer},
+		{WorkerID: "1x2", IP: "10.0.0.2", Active: true, MinerDriver: model.DriverAntminer},
+	})
+	runs := &fakeScanRunRepo{}
+	antminer := &fakeDriver{name: model.DriverAntminer, detect: true, fetch: fetch}
+	return NewScanMinersUseCase(cfg, workers, &fakeMinerStatsRepo{}, nil, nil, runs, nil, []repository.MinerDriver{antminer}), runs
+}
+
+func TestScanMinersShutdownFinishesInFlightScans(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	uc, runs := newShutdownFixture(t, time.Minute, func(ctx context.Context) error {
+		close(started)
+		<-release
+		return ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- uc.Execute(ctx) }()
+	<-started
+	cancel()
+	close(release)
+
+	if err := <-done; err == nil {
+		t.Error("Execute() = nil, an interrupted run should fail")
+	}
+	if len(runs.outcomes) != 1 || runs.outcomes[0].Reason != model.ScanReasonOK {
+		t.Fatalf("outcomes = %+v, want the in-flight miner scanned and the other one not started", runs.outcomes)
+	}
+}
+
+func TestScanMinersShutdownCutsOffHangingScans(t *testing.T) {
+	started := make(chan struct{})
+	uc, runs := newShutdownFixture(t, 20*time.Millisecond, func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- uc.Execute(ctx) }()
+	<-started
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Execute() did not cut off the hanging scan")
+	}
+	if len(runs.outcomes) != 1 || runs.outcomes[0].Reason != model.ScanReasonCanceled {
+		t.Fatalf("outcomes = %+v, want the hanging miner recorded as canceled", runs.outcomes)
+	}
+}
+
+func TestProbePorts(t *testing.T) {
+	checked := time.Now()
+	tests := []struct {
+		name    string
+		driver  string // app.miner_driver
+		cached  string
+		probe   int
+		checked *time.Time
+		want    []int
+	}{
+		{name: "undetected", driver: config.MinerDriverAuto, probe: 80, want: []int{80, 4028}},
+		{name: "cached cgminer API driver", driver: config.MinerDriverAuto, cached: model.DriverVNish, probe: 80, checked: &checked, want: []int{4028}},
+		{name: "cached driver due for reprobe", driver: config.MinerDriverAuto, cached: model.DriverVNish, probe: 80, want: []int{80, 4028}},
+		{name: "cached antminer may fall back", driver: config.MinerDriverAuto, cached: model.DriverAntminer, probe: 80, checked: &checked, want: []int{80, 4028}},
+		{name: "fixed antminer", driver: model.DriverAntminer, cached: model.DriverVNish, probe: 8080, want: []int{8080}},
+		{name: "fixed cgminer", driver: model.DriverCGMiner, probe: 80, want: []int{4028}},
+		{name: "probe disabled", driver: config.MinerDriverAuto, probe: 0, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			worker := &model.Worker{WorkerID: "1x1", IP: "10.0.0.1", MinerDriver: tt.cached, DriverCheckedAt: tt.checked}
+			uc, _ := newScanMinersFixture(t, worker)
+			uc.cfg.App.MinerDriver = tt.driver
+			uc.cfg.App.ScanProbePort = tt.probe
+			uc.cfg.App.CGMinerPort = 4028
+			uc.cfg.App.MinerReprobeInterval = time.Hour
+
+			if got := uc.probePorts(worker); !slices.Equal(got, tt.want) {
+				t.Errorf("probePorts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanSingleMinerProbesDriverPort(t *testing.T) {
+	// Only the cgminer API answers; the HTTP port is closed
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed.Close()
+
+	checked := time.Now()
+	worker := &model.Worker{WorkerID: "1x1", IP: "127.0.0.1", MinerDriver: model.DriverVNish, DriverCheckedAt: &checked}
+	vnish := &fakeDriver{name: model.DriverVNish, detect: true}
+	uc, _ := newScanMinersFixture(t, worker, vnish)
+	uc.minerStatsRepo = &fakeMinerStatsRepo{}
+	uc.savedLayouts = &sync.Map{}
+	uc.cfg.App.ScanProbePort = closed.Addr().(*net.TCPAddr).Port
+	uc.cfg.App.CGMinerPort = listen(t)
+
+	outcome := &model.ScanOutcome{}
+	if err := uc.scanSingleMiner(context.Background(), worker, outcome); err != nil {
+		t.Fatalf("scanSingleMiner() = %v, want the miner read through its cgminer API port", err)
+	}
+	if outcome.Driver != model.DriverVNish {
+		t.Errorf("outcome driver = %q, want %q", outcome.Driver, model.DriverVNish)
+	}
+}
+
+func TestScanSingleMinerRecordsUsersTried(t *testing.T) {
+	worker := &model.Worker{WorkerID: "1x1", IP: "10.0.0.1"}
+	antminer := &fakeDriver{name: model.DriverAntminer, detectErr: repository.ErrMinerUnauthorized}
+	cgminer := &fakeDriver{name: model.DriverCGMiner, detect: true}
+	uc, _ := newScanMinersFixture(t, worker, antminer, cgminer)
+	uc.cfg.App.ScanProbePort = 0
+	uc.cfg.App.MinerCredentials = []config.MinerCredentialSet{
+		{Workers: []string{"1x1"}, Credentials: []config.MinerCredential{{User: "ops", Password: "secret"}}},
+	}
+
+	outcome := &model.ScanOutcome{}
+	err := uc.scanSingleMiner(context.Background(), worker, outcome)
+	if reason := classifyScanError(err); reason != model.ScanReasonUnauthorized {
+		t.Fatalf("scanSingleMiner() = %v (%s), want %s", err, reason, model.ScanReasonUnauthorized)
+	}
+	if outcome.UsersTried != "ops, root" {
+		t.Errorf("UsersTried = %q, want %q", outcome.UsersTried, "ops, root")
+	}
+	if cgminer.detects != 0 || cgminer.reads != 0 {
+		t.Error("the cgminer API was used on a miner that rejected every credential")
+	}
+}
+
+func TestMinerAuthOrder(t *testing.T) {
+	cfg := config.Default()
+	cfg.App.MinerUser, cfg.App.MinerPassword = "root", "root"
+	cfg.App.MinerCredentials = []config.MinerCredentialSet{
+		{CIDRs: []string{"10.0.0.0/24"}, Credentials: []config.MinerCredential{{User: "site", Password: "s1"}}},
+		{Workers: []string{"1x1"}, Credentials: []config.MinerCredential{{User: "worker", Password: "w1"}, {User: "root", Password: "root"}}},
+		{CIDRs: []string{"10.0.1.0/24"}, Credentials: []config.MinerCredential{{User: "other", Password: "o1"}}},
+	}
+	site := repository.MinerCredential{User: "site", Password: "s1"}
+
+	tests := []struct {
+		name       string
+		workerID   string
+		ip         string
+		remembered string
+		want       []string
+	}{
+		{"worker sets, then CIDR sets, then the default", "1x1", "10.0.0.5", "", []string{"worker", "root", "site"}},
+		{"CIDR sets only", "1x2", "10.0.0.5", "", []string{"site", "root"}},
+		{"default only", "", "192.168.0.5", "", []string{"root"}},
+		{"remembered credential first", "1x1", "10.0.0.5", credentialFingerprint(site), []string{"site", "worker", "root"}},
+		{"unknown fingerprint ignored", "1x1", "10.0.0.5", "0000000000000000", []string{"worker", "root", "site"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc := NewScanMinersUseCase(cfg, nil, nil, nil, nil, nil, nil, nil)
+			auth := uc.minerAuth(&model.Worker{WorkerID: tt.workerID, IP: tt.ip, MinerCredential: tt.remembered})
+			var users []string
+			for _, cred := range auth.Credentials {
+				users = append(users, cred.User)
+			}
+			if !slices.Equal(users, tt.want) {
+				t.Errorf("credentials = %q, want %q", users, tt.want)
+			}
+		})
+	}
+}
+
+func TestMinerAuthAccept(t *testing.T) {
+	auth := &repository.MinerAuth{Credentials: []repository.MinerCredential{{User: "a"}, {User: "b"}, {User: "c"}}}
+	auth.Accept(2)
+	if auth.Accepted == nil || auth.Accepted.User != "c" {
+		t.Fatalf("Accepted = %+v, want c", auth.Accepted)
+	}
+	var users []string
+	for _, cred := range auth.Credentials {
+		users = append(users, cred.User)
+	}
+	if want := []string{"c", "a", "b"}; !slices.Equal(users, want) {
+		t.Errorf("credentials after Accept = %q, want %q", users, want)
+	}
+}
+
+func TestTrackHashboards(t *testing.T) {
+	snapshot := func(observer, workerID, ip string, sns ...string) *model.MinerStats {
+		stats := &model.MinerStats{ObserverUserID: observer, WorkerID: workerID, IP: ip, CreatedAt: time.Now()}
+		for i, sn := range sns {
+			stats.Chains = append(stats.Chains, model.MinerChain{ChainIndex: i, SN: sn})
+		}
+		return stats
+	}
+
+	tests := []struct {
+		name      string
+		snapshots []*model.MinerStats
+		wantMoves []string // SN from IP/slot to IP/slot
+	}{
+		{
+			name: "worker renamed in the pool",
+			snapshots: []*model.MinerStats{
+				snapshot("acct", "1x1", "10.0.1.1", "SN-A", "SN-B"),
+				snapshot("acct", "rack1-01", "10.0.1.1", "SN-A", "SN-B"),
+			},
+		},
+		{
+			name: "same worker ID under two accounts",
+			snapshots: []*model.MinerStats{
+				snapshot("acct-a", "1x1", "10.0.1.1", "SN-A"),
+				snapshot("acct-b", "1x1", "10.0.1.1", "SN-A"),
+				snapshot("acct-a", "1x1", "10.0.1.1", "SN-A"),
+			},
+		},
+		{
+			name: "boards swapped between miners",
+			snapshots: []*model.MinerStats{
+				snapshot("acct", "1x1", "10.0.1.1", "SN-A"),
+				snapshot("acct", "1x2", "10.0.1.2", "SN-B"),
+				snapshot("acct", "1x1", "10.0.1.1", "SN-B"),
+				snapshot("acct", "1x2", "10.0.1.2", "SN-A"),
+			},
+			wantMoves: []string{"SN-B 10.0.1.2/0 -> 10.0.1.1/0", "SN-A 10.0.1.1/0 -> 10.0.1.2/0"},
+		},
+		{
+			name: "board moved to another slot",
+			snapshots: []*model.MinerStats{
+				snapshot("acct", "1x1", "10.0.1.1", "SN-A", "SN-B"),
+				snapshot("acct", "1x1", "10.0.1.1", "SN-B", "SN-A"),
+			},
+			wantMoves: []string{"SN-B 10.0.1.1/1 -> 10.0.1.1/0", "SN-A 10.0.1.1/0 -> 10.0.1.1/1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			boards := &fakeHashboardRepo{}
+			uc := NewScanMinersUseCase(config.Default(), nil, nil, boards, nil, nil, nil, nil)
+			for _, stats := range tt.snapshots {
+				if err := uc.trackHashboards(context.Background(), stats); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var got []string
+			for _, m := range boards.moves {
+				got = append(got, fmt.Sprintf("%s %s/%d -> %s/%d", m.SN, m.FromIP, m.FromChainIndex, m.ToIP, m.ToChainIndex))
+			}
+			if !slices.Equal(got, tt.wantMoves) {
+				t.Errorf("moves = %q, want %q", got, tt.wantMoves)
+			}
+		})
+	}
+}
+
+func TestSaveChipLayoutRetriesAfterFailure(t *testing.T) {
+	layouts := &fakeChipLayoutRepo{saveErr: errors.New("deadlock")}
+	uc := NewScanMinersUseCase(config.Default(), nil, nil, nil, layouts, nil, nil, nil)
+	uc.savedLayouts = &sync.Map{}
+	stats := &model.MinerStats{MinerType: "Antminer S19", ChipLayout: [][]int{{1, 2}, {4, 3}}}
+
+	err := uc.saveChipLayout(context.Background(), stats)
+	if !errors.Is(err, errScanDB) || classifyScanError(err) != model.ScanReasonDBError {
+		t.Fatalf("saveChipLayout() = %v, want a database error", err)
+	}
+
+	layouts.saveErr = nil
+	for range 2 {
+		if err := uc.saveChipLayout(context.Background(), stats); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if layouts.saves != 2 || layouts.layouts["Antminer S19"] == nil {
+		t.Errorf("%d saves, want the failed one and one retry", layouts.saves)
+	}
+}
+
+func TestClassifyBadChipLayout(t *testing.T) {
+	err := fmt.Errorf("%w: %w", errBadChipLayout, errors.New("json: unsupported value"))
+	if got := classifyScanError(err); got != model.ScanReasonBadLayout {
+		t.Errorf("classifyScanError() = %q, want %q", got, model.ScanReasonBadLayout)
+	}
+}
@@ -3,142 +3,380 @@ package usecase
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
+	"os"
 	"time"
 
 	"github.com/beatyman/scan-miners/config"
 	"github.com/beatyman/scan-miners/internal/domain/model"
 	"github.com/beatyman/scan-miners/internal/domain/repository"
+	"github.com/beatyman/scan-miners/pkg/ipmap"
 	"github.com/beatyman/scan-miners/pkg/logger"
-	"github.com/beatyman/scan-miners/pkg/utils"
 	"go.uber.org/zap"
 )
 
 type ScanWorkersUseCase struct {
-	cfg        *config.Config
-	workerRepo repository.WorkerRepository
-	client     *http.Client
+	cfg          *config.Config
+	workerRepo   repository.WorkerRepository
+	syncRunRepo  repository.WorkerSyncRunRepository
+	snapshotRepo repository.WorkerHashrateSnapshotRepository
+	poolClient   repository.PoolClient
+	ipMapper     *ipmap.Mapper
 }
 
-func NewScanWorkersUseCase(cfg *config.Config, repo repository.WorkerRepository) *ScanWorkersUseCase {
+func NewScanWorkersUseCase(cfg *config.Config, repo repository.WorkerRepository, syncRunRepo repository.WorkerSyncRunRepository, snapshotRepo repository.WorkerHashrateSnapshotRepository, poolClient repository.PoolClient, ipMapper *ipmap.Mapper) *ScanWorkersUseCase {
 	return &ScanWorkersUseCase{
-		cfg:        cfg,
-		workerRepo: repo,
-		client: &http.Client{
-			Timeout: cfg.App.RequestTimeout,
-		},
+		cfg:          cfg,
+		workerRepo:   repo,
+		syncRunRepo:  syncRunRepo,
+		snapshotRepo: snapshotRepo,
+		poolClient:   poolClient,
+		ipMapper:     ipMapper,
 	}
 }
 
-func (uc *ScanWorkersUseCase) Execute(ctx context.Context) error {
+// Execute fetches the worker list of every configured account (or only
+// observerUserID when set). With resume, each account continues from the page
+// recorded in the checkpoint file by a previous, interrupted run.
+func (uc *ScanWorkersUseCase) Execute(ctx context.Context, observerUserID string, resume bool) error {
 	logger.Log.Info("Starting to scan workers from Antpool")
 
-	// Prepare request
-	// url := "https://www.antpool.com/auth/v3/observer/api/worker/list?search=&workerStatus=0&accessKey=tHRWhY0DJFTLgfPhE9tC&coinType=BTC&observerUserId=sam001sz&pageNum=1&pageSize=2000" // Increased pageSize to fetch more, assuming pagination logic if needed but user provided specific curl
-	// Note: User provided pageSize=10 in curl, but we likely want all. Let's stick to the URL structure but maybe increase page size or loop pages.
-	// For this task, I will fetch page 1 with a large page size to try to get many, or implement simple pagination if "totalPage" is returned.
-	// The response shows "totalPage": 194, "totalRecord": 1932. So pageSize=10 is small.
-	// I will implement loop to fetch all pages.
-
-	page := 1
-	pageSize := 100 // Reasonable batch size
-
-	for {
-		logger.Log.Info("Fetching workers page", zap.Int("page", page))
+	accounts := uc.cfg.App.AntpoolAccounts
+	if observerUserID != "" {
+		accounts = nil
+		for _, acc := range uc.cfg.App.AntpoolAccounts {
+			if acc.ObserverUserID == observerUserID {
+				accounts = append(accounts, acc)
+			}
+		}
+	}
+	if len(accounts) == 0 {
+		if observerUserID != "" {
+			return fmt.Errorf("no antpool account configured for observer user %q", observerUserID)
+		}
+		return fmt.Errorf("app.antpool_accounts is empty, configure at least one observer account (or set %s)", config.EnvName("app.antpool_accounts"))
+	}
 
-		reqURL := fmt.Sprintf("https://www.antpool.com/auth/v3/observer/api/worker/list?search=&workerStatus=0&accessKey=tHRWhY0DJFTLgfPhE9tC&coinType=BTC&observerUserId=sam001sz&pageNum=%d&pageSize=%d", page, pageSize)
+	cp, err := loadCheckpoint(uc.cfg.App.CheckpointFile)
+	if err != nil {
+		return err
+	}
 
-		req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
+	var diffs []*workerDiff
+	for _, acc := range accounts {
+		account := model.PoolAccount{
+			AccessKey:      acc.AccessKey,
+			ObserverUserID: acc.ObserverUserID,
+			CoinType:       acc.CoinType,
+			APIKey:         acc.APIKey,
+			APISecret:      acc.APISecret,
+			APIUserID:      acc.APIUserID,
+		}
+		startPage := 1
+		if resume {
+			startPage = cp.next(account)
+		}
+		diff, err := uc.scanAccount(ctx, account, startPage, cp)
 		if err != nil {
-			return err
+			return fmt.Errorf("account %s/%s: %w", acc.ObserverUserID, acc.CoinType, err)
 		}
+		diffs = append(diffs, diff)
+	}
 
-		// Set headers from requirement
-		req.Header.Set("accept", "application/json, text/plain, */*")
-		req.Header.Set("accept-language", "en,zh-CN;q=0.9,zh;q=0.8")
-		req.Header.Set("cache-control", "no-cache")
-		req.Header.Set("cookie", uc.cfg.App.AntpoolCookie)
-		req.Header.Set("user-agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/144.0.0.0 Safari/537.36")
-		// Add other headers if strictly necessary, but usually UA and Cookie are key.
+	logger.Log.Info("Finished scanning workers", zap.Int("accounts", len(accounts)))
 
-		resp, err := uc.client.Do(req)
-		if err != nil {
-			logger.Log.Error("Failed to fetch workers", zap.Error(err))
-			return err
-		}
-		defer resp.Body.Close()
+	fmt.Println("\nWorker changes since the previous sync:")
+	for _, d := range diffs {
+		d.print(os.Stdout)
+	}
+	return nil
+}
 
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return err
-		}
+func (uc *ScanWorkersUseCase) scanAccount(ctx context.Context, account model.PoolAccount, startPage int, cp *checkpoint) (diff *workerDiff, err error) {
+	log := logger.Log.With(zap.String("observerUserId", account.ObserverUserID), zap.String("coinType", account.CoinType))
+	log.Info("Scanning workers for account", zap.Int("startPage", startPage))
 
-		// Parse Response
-		var result struct {
-			Code string `json:"code"`
-			Msg  string `json:"msg"`
-			Data struct {
-				Items       []model.AntpoolWorkerResponseItem `json:"items"`
-				PageNum     int                               `json:"pageNum"`
-				TotalPage   int                               `json:"totalPage"`
-				TotalRecord int                               `json:"totalRecord"`
-			} `json:"data"`
+	// Removal can only be detected against a complete pass, so a resumed run skips the diff
+	var previous []*model.Worker
+	if startPage == 1 {
+		previous, err = uc.workerRepo.FindByAccount(ctx, account.ObserverUserID, account.CoinType)
+		if err != nil {
+			return nil, fmt.Errorf("load stored workers: %w", err)
 		}
+	}
+	seen := map[string]*model.Worker{}
+	site := uc.cfg.SiteOf(account.ObserverUserID)
+	unmapped := 0
+	duplicates := 0
+	// Without a pool-side summary the counters are taken from the list itself
+	var counted model.PoolWorkerStatus
+	poolStatus := false
 
-		if err := json.Unmarshal(body, &result); err != nil {
-			logger.Log.Error("Failed to parse response", zap.Error(err))
-			return err
-		}
+	run := &model.WorkerSyncRun{
+		ObserverUserID: account.ObserverUserID,
+		CoinType:       account.CoinType,
+		Status:         model.SyncRunRunning,
+		StartPage:      startPage,
+		StartedAt:      time.Now(),
+	}
+	if err := uc.syncRunRepo.Create(ctx, run); err != nil {
+		return nil, fmt.Errorf("create sync run: %w", err)
+	}
+	defer func() {
+		uc.finishSyncRun(ctx, log, run, err)
+	}()
 
-		if result.Code != "000000" {
-			logger.Log.Error("API returned error", zap.String("code", result.Code), zap.String("msg", result.Msg))
-			return fmt.Errorf("api error: %s", result.Msg)
+	err = uc.poolClient.ListWorkers(ctx, account, startPage, func(page *model.PoolWorkerPage) error {
+		run.PagesFetched++
+		run.TotalRecord = page.TotalRecord
+		if page.Status != nil && run.PagesFetched == 1 {
+			poolStatus = true
+			run.TotalWorkerNum = page.Status.Total
+			run.OnlineWorkerNum = page.Status.Online
+			run.OfflineWorkerNum = page.Status.Offline
+			run.DisableWorkerNum = page.Status.Disable
 		}
 
-		// Convert and Save
-		var workers []*model.Worker
-		for _, item := range result.Data.Items {
-			val10m, unit10m := utils.ParseHashrate(item.HsLast10Min)
-			val1h, unit1h := utils.ParseHashrate(item.HsLast1H)
-			val1d, unit1d := utils.ParseHashrate(item.HsLast1D)
-
-			ip := utils.GenerateIP(item.WorkerID)
-
-			worker := &model.Worker{
-				WorkerID:          item.WorkerID,
-				IP:                ip,
-				UserWorkerID:      item.UserWorkerID,
-				WorkerStatus:      item.WorkerStatus,
-				HsLast10Min:       val10m,
-				HsLast10MinUnit:   unit10m,
-				HsLast1H:          val1h,
-				HsLast1HUnit:      unit1h,
-				HsLast1D:          val1d,
-				HsLast1DUnit:      unit1d,
-				RejectRatio:       item.RejectRatio,
-				OnlineTimeLast24h: item.OnlineTimeLast24h,
-				CreatedAt:         time.Unix(item.CreateTime/1000, 0), // Assuming ms timestamp
+		workers := make([]*model.Worker, 0, len(page.Workers))
+		for _, pw := range page.Workers {
+			// The list can shift while it is paged, so a worker may show up on two pages;
+			// the first copy is kept since a run stores one snapshot per worker
+			if _, dup := seen[pw.WorkerID]; dup {
+				duplicates++
+				continue
+			}
+			ip, ok := uc.ipMapper.Resolve(account.ObserverUserID, site, pw.WorkerID)
+			if !ok {
+				unmapped++
+			}
+			w := toWorker(account, pw, ip, run.StartedAt)
+			workers = append(workers, w)
+			seen[w.WorkerID] = w
+			counted.Total++
+			if pw.WorkerStatus == model.PoolWorkerOnline {
+				counted.Online++
+			} else {
+				counted.Offline++
 			}
-			workers = append(workers, worker)
 		}
 
 		if len(workers) > 0 {
 			if err := uc.workerRepo.SaveBatch(ctx, workers); err != nil {
-				logger.Log.Error("Failed to save workers batch", zap.Error(err))
+				log.Error("Failed to save workers batch", zap.Error(err))
+				return err
+			}
+			log.Info("Saved workers batch", zap.Int("page", page.PageNum), zap.Int("count", len(workers)))
+			run.RecordsStored += len(workers)
+
+			snapshots := make([]*model.WorkerHashrateSnapshot, 0, len(workers))
+			for _, w := range workers {
+				snapshots = append(snapshots, toSnapshot(run.ID, w))
+			}
+			if err := uc.snapshotRepo.SaveBatch(ctx, snapshots); err != nil {
+				log.Error("Failed to save hashrate snapshots", zap.Error(err))
 				return err
 			}
-			logger.Log.Info("Saved workers batch", zap.Int("count", len(workers)))
 		}
 
-		if page >= result.Data.TotalPage {
-			break
+		// Only a stored page counts as done, so a resumed run never skips data
+		return cp.set(account, page.PageNum+1)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := cp.clear(account); err != nil {
+		return nil, err
+	}
+	if duplicates > 0 {
+		log.Warn("Workers listed on more than one page were stored once", zap.Int("count", duplicates))
+	}
+	if unmapped > 0 {
+		log.Warn("Workers without an IP mapping will not be scanned, see resolve-ips", zap.Int("count", unmapped))
+	}
+
+	if startPage != 1 {
+		return &workerDiff{Account: account, Skipped: true}, nil
+	}
+	// Only a full pass counts every worker; disabled workers cannot be told apart
+	if !poolStatus {
+		run.TotalWorkerNum = counted.Total
+		run.OnlineWorkerNum = counted.Online
+		run.OfflineWorkerNum = counted.Offline
+	}
+	diff = diffWorkers(account, previous, seen)
+	ids := make([]uint, 0, len(diff.Inactive))
+	for _, w := range diff.Inactive {
+		ids = append(ids, w.ID)
+	}
+	if err := uc.workerRepo.MarkInactive(ctx, ids); err != nil {
+		return nil, fmt.Errorf("mark removed workers inactive: %w", err)
+	}
+	run.NewWorkers = len(diff.New)
+	run.RemovedWorkers = len(diff.Removed)
+	run.RenamedWorkers = len(diff.Renamed)
+	return diff, nil
+}
+
+// finishSyncRun stores the outcome of a sync run; it must succeed even when ctx was cancelled
+func (uc *ScanWorkersUseCase) finishSyncRun(ctx context.Context, log *zap.Logger, run *model.WorkerSyncRun, runErr error) {
+	finished := time.Now()
+	run.FinishedAt = &finished
+	run.DurationMs = finished.Sub(run.StartedAt).Milliseconds()
+	run.Status = model.SyncRunSuccess
+	if runErr != nil {
+		run.Status = model.SyncRunFailed
+		run.Error = runErr.Error()
+	}
+
+	// A resumed run only covers part of the list, so the count can only be checked on a full pass
+	if runErr == nil && run.StartPage == 1 && run.RecordsStored != run.TotalRecord {
+		run.CountMismatch = true
+		log.Warn("Stored worker count does not match pool totalRecord",
+			zap.Int("stored", run.RecordsStored), zap.Int("totalRecord", run.TotalRecord))
+	}
+
+	if err := uc.syncRunRepo.Update(context.WithoutCancel(ctx), run); err != nil {
+		log.Error("Failed to save sync run", zap.Uint("syncRunId", run.ID), zap.Error(err))
+		return
+	}
+	log.Info("Sync run finished",
+		zap.Uint("syncRunId", run.ID),
+		zap.String("status", run.Status),
+		zap.Int("pages", run.PagesFetched),
+		zap.Int("stored", run.RecordsStored),
+		zap.Int("online", run.OnlineWorkerNum),
+		zap.Int64("durationMs", run.DurationMs))
+}
+
+// toWorker maps a pool-side worker onto the row stored in the workers table
+func toWorker(account model.PoolAccount, pw model.PoolWorker, ip string, seenAt time.Time) *model.Worker {
+	return &model.Worker{
+		PoolID:            pw.PoolID,
+		WorkerID:          pw.WorkerID,
+		IP:                ip,
+		UserWorkerID:      pw.UserWorkerID,
+		ObserverUserID:    account.ObserverUserID,
+		CoinType:          account.CoinType,
+		WorkerStatus:      pw.WorkerStatus,
+		HsLast10Min:       pw.HsLast10Min.Value,
+		HsLast10MinUnit:   pw.HsLast10Min.Unit,
+		HsLast1H:          pw.HsLast1H.Value,
+		HsLast1HUnit:      pw.HsLast1H.Unit,
+		HsLast1Hour:       pw.HsLast1Hour.Value,
+		HsLast1HourUnit:   pw.HsLast1Hour.Unit,
+		HsLast1D:          pw.HsLast1D.Value,
+		HsLast1DUnit:      pw.HsLast1D.Unit,
+		RejectRatio:       pw.RejectRatio,
+		OnlineTimeLast24h: pw.OnlineTimeLast24h,
+		ReconnectLast24h:  pw.ReconnectLast24h,
+		BurstBlock72h:     pw.BurstBlock72h,
+		ShareLastTime:     pw.ShareLastTime,
+		PoolUpdateTime:    pw.UpdateTime,
+		GroupID:           pw.GroupID,
+		GroupName:         pw.GroupName,
+		FanCode:           pw.FanCode,
+		FanValue:          pw.FanValue,
+		HashCode:          pw.HashCode,
+		HashValue:         pw.HashValue,
+		NetworkCode:       pw.NetworkCode,
+		NetworkValue:      pw.NetworkValue,
+		TemperatureCode:   pw.TemperatureCode,
+		TemperatureValue:  pw.TemperatureValue,
+		Active:            true,
+		LastSeenAt:        &seenAt,
+		CreatedAt:         pw.CreateTime,
+	}
+}
+
+// toSnapshot copies the pool-side figures of a stored worker into a history row
+func toSnapshot(syncRunID uint, w *model.Worker) *model.WorkerHashrateSnapshot {
+	return &model.WorkerHashrateSnapshot{
+		SyncRunID:         syncRunID,
+		WorkerID:          w.WorkerID,
+		ObserverUserID:    w.ObserverUserID,
+		CoinType:          w.CoinType,
+		WorkerStatus:      w.WorkerStatus,
+		HsLast10Min:       w.HsLast10Min,
+		HsLast10MinUnit:   w.HsLast10MinUnit,
+		HsLast1H:          w.HsLast1H,
+		HsLast1HUnit:      w.HsLast1HUnit,
+		HsLast1Hour:       w.HsLast1Hour,
+		HsLast1HourUnit:   w.HsLast1HourUnit,
+		HsLast1D:          w.HsLast1D,
+		HsLast1DUnit:      w.HsLast1DUnit,
+		RejectRatio:       w.RejectRatio,
+		OnlineTimeLast24h: w.OnlineTimeLast24h,
+		ReconnectLast24h:  w.ReconnectLast24h,
+		ShareLastTime:     w.ShareLastTime,
+	}
+}
+
+// checkpoint persists the next page to fetch per account, keyed "<observerUserId>/<coinType>"
+type checkpoint struct {
+	path  string
+	pages map[string]int
+}
+
+func checkpointKey(account model.PoolAccount) string {
+	return account.ObserverUserID + "/" + account.CoinType
+}
+
+func loadCheckpoint(path string) (*checkpoint, error) {
+	cp := &checkpoint{path: path, pages: map[string]int{}}
+	if path == "" {
+		return cp, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read checkpoint: %w", err)
+	}
+	if err := json.Unmarshal(data, &cp.pages); err != nil {
+		return nil, fmt.Errorf("parse checkpoint %s: %w", path, err)
+	}
+	return cp, nil
+}
+
+func (cp *checkpoint) next(account model.PoolAccount) int {
+	if page, ok := cp.pages[checkpointKey(account)]; ok {
+		return page
+	}
+	return 1
+}
+
+func (cp *checkpoint) set(account model.PoolAccount, nextPage int) error {
+	cp.pages[checkpointKey(account)] = nextPage
+	return cp.save()
+}
+
+func (cp *checkpoint) clear(account model.PoolAccount) error {
+	delete(cp.pages, checkpointKey(account))
+	return cp.save()
+}
+
+func (cp *checkpoint) save() error {
+	if cp.path == "" {
+		return nil
+	}
+	if len(cp.pages) == 0 {
+		if err := os.Remove(cp.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
 		}
-		page++
-		time.Sleep(500 * time.Millisecond) // Polite delay
+		return nil
 	}
 
-	logger.Log.Info("Finished scanning workers")
-	return nil
+	data, err := json.MarshalIndent(cp.pages, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write-then-rename so a crash never leaves a truncated checkpoint
+	tmp := cp.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, cp.path)
 }
@@ -0,0 +1,208 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/beatyman/scan-miners/config"
+	"github.com/beatyman/scan-miners/internal/domain/model"
+	"github.com/beatyman/scan-miners/pkg/ipmap"
+)
+
+func poolWorkers(ids ...string) []model.PoolWorker {
+	workers := make([]model.PoolWorker, 0, len(ids))
+	for _, id := range ids {
+		workers = append(workers, model.PoolWorker{WorkerID: id})
+	}
+	return workers
+}
+
+type scanWorkersFixture struct {
+	uc        *ScanWorkersUseCase
+	pool      *fakePool
+	workers   *fakeWorkerRepo
+	runs      *fakeSyncRunRepo
+	snapshots *fakeSnapshotRepo
+	cfg       *config.Config
+}
+
+func newScanWorkersFixture(t *testing.T, pool *fakePool) *scanWorkersFixture {
+	t.Helper()
+	cfg := config.Default()
+	cfg.App.AntpoolAccounts = []config.AntpoolAccount{{AccessKey: "key", ObserverUserID: "observer", CoinType: "BTC"}}
+	cfg.App.CheckpointFile = filepath.Join(t.TempDir(), "checkpoint.json")
+	mapper, err := ipmap.New([]ipmap.Rule{{Pattern: `^(\d{1,3})x(\d{1,3})$`, Template: "172.16.$1.$2"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &scanWorkersFixture{pool: pool, workers: &fakeWorkerRepo{}, runs: &fakeSyncRunRepo{}, snapshots: &fakeSnapshotRepo{}, cfg: cfg}
+	f.uc = NewScanWorkersUseCase(cfg, f.workers, f.runs, f.snapshots, pool, mapper)
+	return f
+}
+
+func (f *scanWorkersFixture) lastRun() *model.WorkerSyncRun {
+	return f.runs.runs[len(f.runs.runs)-1]
+}
+
+func TestScanWorkersPagination(t *testing.T) {
+	pool := &fakePool{
+		pages:  [][]model.PoolWorker{poolWorkers("1x1", "1x2"), poolWorkers("1x3", "miner-a"), poolWorkers("1x5")},
+		status: &model.PoolWorkerStatus{Total: 5, Online: 4, Offline: 1},
+	}
+	f := newScanWorkersFixture(t, pool)
+
+	if err := f.uc.Execute(context.Background(), "", false); err != nil {
+		t.Fatal(err)
+	}
+
+	workers, _ := f.workers.FindAll(context.Background())
+	if len(workers) != 5 {
+		t.Fatalf("stored %d workers, want 5", len(workers))
+	}
+	for _, w := range workers {
+		if w.ObserverUserID != "observer" || w.CoinType != "BTC" || !w.Active {
+			t.Errorf("worker %s not tagged with its account: %+v", w.WorkerID, w)
+		}
+	}
+	if w := f.workers.byWorkerID("1x3"); w.IP != "172.16.1.3" {
+		t.Errorf("1x3 mapped to %q, want 172.16.1.3", w.IP)
+	}
+	if w := f.workers.byWorkerID("miner-a"); w.IP != "" {
+		t.Errorf("unmappable worker got IP %q", w.IP)
+	}
+	if len(f.snapshots.snapshots) != 5 {
+		t.Errorf("stored %d snapshots, want 5", len(f.snapshots.snapshots))
+	}
+
+	run := f.lastRun()
+	if run.Status != model.SyncRunSuccess || run.PagesFetched != 3 || run.RecordsStored != 5 || run.CountMismatch {
+		t.Errorf("unexpected sync run: %+v", run)
+	}
+	if run.OnlineWorkerNum != 4 || run.OfflineWorkerNum != 1 {
+		t.Errorf("status counters not recorded: %+v", run)
+	}
+	if _, err := os.Stat(f.cfg.App.CheckpointFile); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("checkpoint left behind after a complete run: %v", err)
+	}
+}
+
+func TestScanWorkersResume(t *testing.T) {
+	pool := &fakePool{
+		pages:  [][]model.PoolWorker{poolWorkers("1x1"), poolWorkers("1x2"), poolWorkers("1x3")},
+		failAt: 3,
+	}
+	f := newScanWorkersFixture(t, pool)
+	ctx := context.Background()
+
+	if err := f.uc.Execute(ctx, "", false); !errors.Is(err, errPoolDown) {
+		t.Fatalf("Execute() = %v, want the pool error", err)
+	}
+	if run := f.lastRun(); run.Status != model.SyncRunFailed || run.PagesFetched != 2 {
+		t.Errorf("unexpected failed run: %+v", run)
+	}
+	cp, err := loadCheckpoint(f.cfg.App.CheckpointFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account := model.PoolAccount{ObserverUserID: "observer", CoinType: "BTC"}
+	if next := cp.next(account); next != 3 {
+		t.Fatalf("checkpoint next page = %d, want 3", next)
+	}
+
+	pool.failAt = 0
+	if err := f.uc.Execute(ctx, "", true); err != nil {
+		t.Fatal(err)
+	}
+	if got := pool.startPages[len(pool.startPages)-1]; got != 3 {
+		t.Errorf("resumed at page %d, want 3", got)
+	}
+	run := f.lastRun()
+	if run.StartPage != 3 || run.PagesFetched != 1 || run.CountMismatch {
+		t.Errorf("unexpected resumed run: %+v", run)
+	}
+	workers, _ := f.workers.FindAll(ctx)
+	if len(workers) != 3 {
+		t.Errorf("stored %d workers, want 3", len(workers))
+	}
+	if _, err := os.Stat(f.cfg.App.CheckpointFile); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("checkpoint left behind after the resumed run: %v", err)
+	}
+}
+
+func TestScanWorkersWithoutResumeStartsOver(t *testing.T) {
+	pool := &fakePool{pages: [][]model.PoolWorker{poolWorkers("1x1"), poolWorkers("1x2")}}
+	f := newScanWorkersFixture(t, pool)
+	cp, _ := loadCheckpoint(f.cfg.App.CheckpointFile)
+	if err := cp.set(model.PoolAccount{ObserverUserID: "observer", CoinType: "BTC"}, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.uc.Execute(context.Background(), "", false); err != nil {
+		t.Fatal(err)
+	}
+	if pool.startPages[0] != 1 {
+		t.Errorf("started at page %d, want 1", pool.startPages[0])
+	}
+}
+
+func TestScanWorkersMarksRemovedInactive(t *testing.T) {
+	pool := &fakePool{pages: [][]model.PoolWorker{poolWorkers("1x1", "1x2")}}
+	f := newScanWorkersFixture(t, pool)
+	ctx := context.Background()
+	if err := f.uc.Execute(ctx, "", false); err != nil {
+		t.Fatal(err)
+	}
+
+	pool.pages = [][]model.PoolWorker{poolWorkers("1x1")}
+	if err := f.uc.Execute(ctx, "", false); err != nil {
+		t.Fatal(err)
+	}
+	if w := f.workers.byWorkerID("1x2"); w.Active {
+		t.Error("worker missing from the pool list is still active")
+	}
+	if run := f.lastRun(); run.RemovedWorkers != 1 {
+		t.Errorf("RemovedWorkers = %d, want 1", run.RemovedWorkers)
+	}
+}
+
+func TestScanWorkersUnknownAccount(t *testing.T) {
+	f := newScanWorkersFixture(t, &fakePool{})
+	if err := f.uc.Execute(context.Background(), "someone-else", false); err == nil {
+		t.Fatal("Execute() with an unconfigured account should fail")
+	}
+}
+
+func TestScanWorkersCountsWithoutPoolSummary(t *testing.T) {
+	workers := poolWorkers("1x1", "1x2", "1x3")
+	workers[0].WorkerStatus = model.PoolWorkerOnline
+	workers[2].WorkerStatus = model.PoolWorkerOnline
+	f := newScanWorkersFixture(t, &fakePool{pages: [][]model.PoolWorker{workers[:2], workers[2:]}})
+
+	if err := f.uc.Execute(context.Background(), "", false); err != nil {
+		t.Fatal(err)
+	}
+	run := f.lastRun()
+	if run.TotalWorkerNum != 3 || run.OnlineWorkerNum != 2 || run.OfflineWorkerNum != 1 || run.DisableWorkerNum != 0 {
+		t.Errorf("counters not taken from the list: %+v", run)
+	}
+}
+
+func TestScanWorkersWorkerOnTwoPages(t *testing.T) {
+	// 1x2 slides from page 1 to page 2 while the list is paged
+	pool := &fakePool{pages: [][]model.PoolWorker{poolWorkers("1x1", "1x2"), poolWorkers("1x2", "1x3")}}
+	f := newScanWorkersFixture(t, pool)
+
+	if err := f.uc.Execute(context.Background(), "", false); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.snapshots.snapshots) != 3 {
+		t.Errorf("stored %d snapshots, want one per worker", len(f.snapshots.snapshots))
+	}
+	run := f.lastRun()
+	if run.Status != model.SyncRunSuccess || run.RecordsStored != 3 || run.TotalWorkerNum != 3 {
+		t.Errorf("unexpected sync run: %+v", run)
+	}
+}
@@ -0,0 +1,127 @@
+package usecase
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/beatyman/scan-miners/internal/domain/model"
+)
+
+// workerRename is a worker that kept its pool identity but changed its name
+type workerRename struct {
+	From, To string
+}
+
+// workerDiff compares the workers stored before a sync with the workers the pool returned
+type workerDiff struct {
+	Account model.PoolAccount
+	// Skipped is set when the run did not cover the whole list (resume or failure)
+	Skipped bool
+	// FirstSync is set when the account had no stored workers, every worker is new
+	FirstSync bool
+
+	New     []string
+	Removed []*model.Worker
+	Renamed []workerRename
+
+	// Inactive are the stored rows no longer in the pool list: Removed plus the old side of renames
+	Inactive []*model.Worker
+}
+
+// diffWorkers computes what changed between the stored workers of an account
+// and the workers seen in a complete pass over the pool list, keyed by worker ID.
+// A removed worker whose pool ID reappears under another worker ID is reported
+// as a rename of workerId; a changed userWorkerId is reported as a rename too.
+func diffWorkers(account model.PoolAccount, previous []*model.Worker, seen map[string]*model.Worker) *workerDiff {
+	diff := &workerDiff{Account: account, FirstSync: len(previous) == 0}
+
+	prevByID := make(map[string]*model.Worker, len(previous))
+	for _, w := range previous {
+		prevByID[w.WorkerID] = w
+	}
+
+	// Pool IDs of workers that vanished, to pair them with new worker IDs
+	removedByPoolID := map[int64]*model.Worker{}
+	for _, w := range previous {
+		if _, ok := seen[w.WorkerID]; !ok && w.Active {
+			diff.Inactive = append(diff.Inactive, w)
+			if w.PoolID != 0 {
+				removedByPoolID[w.PoolID] = w
+			}
+		}
+	}
+
+	renamedFrom := map[string]bool{}
+	for id, w := range seen {
+		prev, existed := prevByID[id]
+		switch {
+		case !existed || !prev.Active:
+			if old, ok := removedByPoolID[w.PoolID]; ok && w.PoolID != 0 {
+				diff.Renamed = append(diff.Renamed, workerRename{From: old.WorkerID, To: id})
+				renamedFrom[old.WorkerID] = true
+				continue
+			}
+			diff.New = append(diff.New, id)
+		case prev.UserWorkerID != "" && prev.UserWorkerID != w.UserWorkerID:
+			diff.Renamed = append(diff.Renamed, workerRename{From: prev.UserWorkerID, To: w.UserWorkerID})
+		}
+	}
+
+	for _, w := range diff.Inactive {
+		if !renamedFrom[w.WorkerID] {
+			diff.Removed = append(diff.Removed, w)
+		}
+	}
+
+	sort.Strings(diff.New)
+	sort.Slice(diff.Removed, func(i, j int) bool { return diff.Removed[i].WorkerID < diff.Removed[j].WorkerID })
+	sort.Slice(diff.Renamed, func(i, j int) bool { return diff.Renamed[i].From < diff.Renamed[j].From })
+	return diff
+}
+
+// summaryListLimit caps how many worker IDs are listed per category in the summary
+const summaryListLimit = 20
+
+func (d *workerDiff) print(w io.Writer) {
+	name := d.Account.ObserverUserID + "/" + d.Account.CoinType
+	if d.Skipped {
+		fmt.Fprintf(w, "%s: change detection skipped (partial run)\n", name)
+		return
+	}
+
+	fmt.Fprintf(w, "%s: %d new, %d removed, %d renamed\n", name, len(d.New), len(d.Removed), len(d.Renamed))
+	if d.FirstSync {
+		return // Listing every worker of a first sync is noise
+	}
+
+	printList(w, "new", d.New)
+
+	removed := make([]string, 0, len(d.Removed))
+	for _, rw := range d.Removed {
+		removed = append(removed, rw.WorkerID)
+	}
+	printList(w, "removed", removed)
+
+	renamed := make([]string, 0, len(d.Renamed))
+	for _, r := range d.Renamed {
+		renamed = append(renamed, r.From+" -> "+r.To)
+	}
+	printList(w, "renamed", renamed)
+}
+
+func printList(w io.Writer, label string, items []string) {
+	if len(items) == 0 {
+		return
+	}
+	shown := items
+	if len(shown) > summaryListLimit {
+		shown = shown[:summaryListLimit]
+	}
+	line := strings.Join(shown, ", ")
+	if more := len(items) - len(shown); more > 0 {
+		line += fmt.Sprintf(" ... and %d more", more)
+	}
+	fmt.Fprintf(w, "  %-8s %s\n", label+":", line)
+}
@@ -0,0 +1,134 @@
+// Package ipmap derives a miner's LAN IP from its pool worker ID using
+// configurable regex rules and an explicit per-worker override table.
+package ipmap
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Rule maps worker IDs matching Pattern to the IP built from Template, where
+// $1, ${2} or ${name} refer to capture groups. Account and Site restrict the
+// rule to one observer account or site; empty matches any.
+type Rule struct {
+	Account  string
+	Site     string
+	Pattern  string
+	Template string
+}
+
+// Override pins a worker to an IP. An empty Account applies to every account.
+type Override struct {
+	Account  string
+	WorkerID string
+	IP       string
+}
+
+type compiledRule struct {
+	Rule
+	re *regexp.Regexp
+}
+
+// Mapper resolves worker IDs to IPs. Overrides win over rules; rules are tried in order.
+type Mapper struct {
+	rules     []compiledRule
+	overrides map[string]string
+}
+
+func New(rules []Rule, overrides []Override) (*Mapper, error) {
+	m := &Mapper{overrides: map[string]string{}}
+
+	for i, r := range rules {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("ip mapping rule %d: invalid pattern %q: %w", i, r.Pattern, err)
+		}
+		if r.Template == "" {
+			return nil, fmt.Errorf("ip mapping rule %d: template is required", i)
+		}
+		m.rules = append(m.rules, compiledRule{Rule: r, re: re})
+	}
+
+	for _, o := range overrides {
+		if net.ParseIP(o.IP) == nil {
+			return nil, fmt.Errorf("ip override for worker %q: invalid IP %q", o.WorkerID, o.IP)
+		}
+		m.overrides[overrideKey(o.Account, o.WorkerID)] = o.IP
+	}
+	return m, nil
+}
+
+// LoadOverridesCSV reads "worker_id,ip[,account]" rows. A header row and blank
+// lines are skipped.
+func LoadOverridesCSV(path string) ([]Override, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open ip overrides: %w", err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	r.Comment = '#'
+
+	var overrides []Override
+	for line := 1; ; line++ {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if len(rec) < 2 {
+			return nil, fmt.Errorf("%s:%d: want worker_id,ip[,account], got %d fields", path, line, len(rec))
+		}
+		if line == 1 && strings.EqualFold(strings.TrimSpace(rec[0]), "worker_id") {
+			continue
+		}
+
+		o := Override{WorkerID: strings.TrimSpace(rec[0]), IP: strings.TrimSpace(rec[1])}
+		if len(rec) > 2 {
+			o.Account = strings.TrimSpace(rec[2])
+		}
+		overrides = append(overrides, o)
+	}
+	return overrides, nil
+}
+
+func overrideKey(account, workerID string) string {
+	return account + "\x00" + workerID
+}
+
+// Resolve returns the IP of a worker of the given account and site, or false
+// when neither an override nor a rule produces a valid IP.
+func (m *Mapper) Resolve(account, site, workerID string) (string, bool) {
+	if ip, ok := m.overrides[overrideKey(account, workerID)]; ok {
+		return ip, true
+	}
+	if ip, ok := m.overrides[overrideKey("", workerID)]; ok {
+		return ip, true
+	}
+
+	for _, r := range m.rules {
+		if (r.Account != "" && r.Account != account) || (r.Site != "" && r.Site != site) {
+			continue
+		}
+		match := r.re.FindStringSubmatchIndex(workerID)
+		if match == nil {
+			continue
+		}
+		ip := string(r.re.ExpandString(nil, r.Template, workerID, match))
+		if net.ParseIP(ip) != nil {
+			return ip, true
+		}
+	}
+	return "", false
+}
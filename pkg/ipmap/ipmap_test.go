@@ -0,0 +1,100 @@
+package ipmap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	rules := []Rule{
+		{Account: "site-a", Pattern: `^a(\d+)x(\d+)$`, Template: "10.1.$1.$2"},
+		{Site: "north", Pattern: `^(?P<rack>\d+)x(?P<slot>\d+)$`, Template: "10.2.${rack}.${slot}"},
+		{Pattern: `^(\d+)x(\d+)$`, Template: "10.3.$1.$2"},
+	}
+	overrides := []Override{
+		{WorkerID: "1x1", IP: "192.168.0.1"},
+		{Account: "site-b", WorkerID: "1x1", IP: "192.168.0.2"},
+	}
+	m, err := New(rules, overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name                  string
+		account, site, worker string
+		wantIP                string
+		wantOK                bool
+	}{
+		{"account override wins", "site-b", "", "1x1", "192.168.0.2", true},
+		{"global override", "site-c", "", "1x1", "192.168.0.1", true},
+		{"account rule", "site-a", "", "a4x7", "10.1.4.7", true},
+		{"account rule skipped for other accounts", "site-c", "", "a4x7", "", false},
+		{"site rule with named groups", "site-c", "north", "4x7", "10.2.4.7", true},
+		{"first matching rule wins", "site-c", "south", "4x7", "10.3.4.7", true},
+		{"invalid IP falls through", "site-c", "south", "4x300", "", false},
+		{"no match", "site-c", "", "miner-a", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ip, ok := m.Resolve(tt.account, tt.site, tt.worker)
+			if ip != tt.wantIP || ok != tt.wantOK {
+				t.Errorf("Resolve(%q, %q, %q) = %q, %v, want %q, %v", tt.account, tt.site, tt.worker, ip, ok, tt.wantIP, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestNewRejects(t *testing.T) {
+	tests := []struct {
+		name      string
+		rules     []Rule
+		overrides []Override
+		wantErr   string
+	}{
+		{"bad pattern", []Rule{{Pattern: "(", Template: "10.0.0.1"}}, nil, "invalid pattern"},
+		{"missing template", []Rule{{Pattern: ".*"}}, nil, "template is required"},
+		{"bad override IP", nil, []Override{{WorkerID: "w1", IP: "10.0.0"}}, "invalid IP"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.rules, tt.overrides); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("New() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadOverridesCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "overrides.csv")
+	content := "worker_id,ip,account\n# spare\n1x1, 10.0.0.1\n\n1x2,10.0.0.2,site-a\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	overrides, err := LoadOverridesCSV(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Override{
+		{WorkerID: "1x1", IP: "10.0.0.1"},
+		{Account: "site-a", WorkerID: "1x2", IP: "10.0.0.2"},
+	}
+	if len(overrides) != len(want) {
+		t.Fatalf("got %d overrides, want %d: %+v", len(overrides), len(want), overrides)
+	}
+	for i := range want {
+		if overrides[i] != want[i] {
+			t.Errorf("override %d = %+v, want %+v", i, overrides[i], want[i])
+		}
+	}
+
+	if err := os.WriteFile(path, []byte("1x1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadOverridesCSV(path); err == nil {
+		t.Error("a row without an IP should be rejected")
+	}
+}
@@ -0,0 +1,98 @@
+// Package transcript records the raw responses a miner driver reads while
+// taking one snapshot, and replays them so archived snapshots can be parsed
+// again without talking to the miner.
+package transcript
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+// ErrNotRecorded is returned on replay for a request the scan never got an answer to
+var ErrNotRecorded = errors.New("transcript: response not recorded")
+
+// Transcript maps a request key (cgminer command, CGI path) to the raw body
+// the miner sent, before any sanitizing
+type Transcript struct {
+	mu        sync.Mutex
+	responses map[string]string
+	replay    bool
+}
+
+type contextKey struct{}
+
+// Record returns a context under which clients add every response they read to t
+func Record(ctx context.Context) (context.Context, *Transcript) {
+	t := &Transcript{responses: make(map[string]string)}
+	return context.WithValue(ctx, contextKey{}, t), t
+}
+
+// Replay returns a context under which clients answer from t instead of the network
+func Replay(ctx context.Context, t *Transcript) context.Context {
+	t.replay = true
+	return context.WithValue(ctx, contextKey{}, t)
+}
+
+// FromContext returns the transcript attached to ctx, or nil
+func FromContext(ctx context.Context) *Transcript {
+	t, _ := ctx.Value(contextKey{}).(*Transcript)
+	return t
+}
+
+// Replaying reports whether responses must come from the transcript
+func (t *Transcript) Replaying() bool {
+	return t != nil && t.replay
+}
+
+// Add records the body read for key; it is a no-op on a nil transcript
+func (t *Transcript) Add(key string, body []byte) {
+	if t == nil || t.replay {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.responses[key] = string(body)
+}
+
+// Get returns the recorded body for key
+func (t *Transcript) Get(key string) ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	body, ok := t.responses[key]
+	if !ok {
+		return nil, ErrNotRecorded
+	}
+	return []byte(body), nil
+}
+
+// Len is the number of recorded responses
+func (t *Transcript) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.responses)
+}
+
+// MarshalJSON stores the transcript as {"responses": {key: body}}
+func (t *Transcript) MarshalJSON() ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return json.Marshal(struct {
+		Responses map[string]string `json:"responses"`
+	}{t.responses})
+}
+
+// Parse reads a transcript written by MarshalJSON
+func Parse(data []byte) (*Transcript, error) {
+	var v struct {
+		Responses map[string]string `json:"responses"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	if v.Responses == nil {
+		v.Responses = make(map[string]string)
+	}
+	return &Transcript{responses: v.Responses}, nil
+}
@@ -1,7 +1,6 @@
 package utils
 
 import (
-	"fmt"
 	"strconv"
 	"strings"
 )
@@ -27,13 +26,3 @@ func ParseHashrate(hs string) (float64, string) {
 	}
 	return val, parts[1]
 }
-
-// GenerateIP generates IP from workerId like "30x182" -> "172.16.30.182"
-func GenerateIP(workerID string) string {
-	// Rule: 172.16.{x}.{y} where workerId is {x}x{y}
-	parts := strings.Split(workerID, "x")
-	if len(parts) != 2 {
-		return "" // Invalid format
-	}
-	return fmt.Sprintf("172.16.%s.%s", parts[0], parts[1])
-}